@@ -0,0 +1,51 @@
+package api
+
+import (
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/jenpaff/golang-microservices/errors"
+	"io"
+)
+
+// limitBodySize rejects requests announcing a body larger than maxBytes with 413,
+// bodies without a Content-Length fail with a RequestTooLarge error once more than maxBytes are read
+func limitBodySize(maxBytes int64) restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		if req.Request.ContentLength > maxBytes {
+			errors.WriteError(req, resp, fmt.Errorf("request body of %d bytes is larger than %d bytes: %w", req.Request.ContentLength, maxBytes, errors.RequestTooLarge))
+			return
+		}
+		if req.Request.Body != nil {
+			req.Request.Body = &limitedBody{ReadCloser: req.Request.Body, limit: maxBytes, remaining: maxBytes}
+		}
+		chain.ProcessFilter(req, resp)
+	}
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, b.tooLarge()
+	}
+	// read one byte more than allowed to tell a body of exactly the limit from a larger one
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		return n, err
+	}
+	n = int(b.remaining)
+	b.remaining = -1
+	return n, b.tooLarge()
+}
+
+func (b *limitedBody) tooLarge() error {
+	return fmt.Errorf("request body is larger than %d bytes: %w", b.limit, errors.RequestTooLarge)
+}
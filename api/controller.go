@@ -3,15 +3,27 @@ package api
 import (
 	"github.com/go-playground/validator/v10"
 	"github.com/jenpaff/golang-microservices/config"
+	"github.com/jenpaff/golang-microservices/featuretoggles"
+	"github.com/jenpaff/golang-microservices/idempotency"
+	"github.com/jenpaff/golang-microservices/metrics"
 	"github.com/jenpaff/golang-microservices/users"
 )
 
 type Controller struct {
-	Cfg         config.Config
-	userService users.Service
-	validator   *validator.Validate
+	configStore        *config.Store
+	userService        users.Service
+	validator          *validator.Validate
+	idempotencyStorage idempotency.Storage
+	toggleStore        *featuretoggles.Store
+	toggles            featuretoggles.Client
+	metrics            *metrics.Metrics
 }
 
-func NewController(cfg config.Config, userService users.Service, validator *validator.Validate) *Controller {
-	return &Controller{Cfg: cfg, userService: userService, validator: validator}
+func NewController(configStore *config.Store, userService users.Service, validator *validator.Validate, idempotencyStorage idempotency.Storage, toggleStore *featuretoggles.Store, toggles featuretoggles.Client, metrics *metrics.Metrics) *Controller {
+	return &Controller{configStore: configStore, userService: userService, validator: validator, idempotencyStorage: idempotencyStorage, toggleStore: toggleStore, toggles: toggles, metrics: metrics}
+}
+
+// Cfg returns the current config, it changes when the reloadable sections of the config are reloaded
+func (c *Controller) Cfg() config.Config {
+	return c.configStore.Get()
 }
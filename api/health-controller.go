@@ -10,12 +10,12 @@ type Health struct {
 	Name   string `json:"name"`
 }
 
-func (c *Controller) Health(_ *restful.Request, resp *restful.Response) {
-	log.Info("health endpoint was invoked")
-	health := &Health{Status: "up", Name: c.Cfg.Name}
+func (c *Controller) Health(req *restful.Request, resp *restful.Response) {
+	log.GetContext(req.Request.Context()).Info("health endpoint was invoked")
+	health := &Health{Status: "up", Name: c.Cfg().Name}
 	err := resp.WriteEntity(health)
 	if err != nil {
-		log.Warn("service is down, cannot write health status")
+		log.GetContext(req.Request.Context()).Warn("service is down, cannot write health status")
 	}
-	log.Info("health endpoint ran successfully")
+	log.GetContext(req.Request.Context()).Info("health endpoint ran successfully")
 }
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"github.com/jenpaff/golang-microservices/api"
 	"github.com/jenpaff/golang-microservices/config"
+	"github.com/jenpaff/golang-microservices/metrics"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"net/http"
@@ -17,7 +18,7 @@ var _ = Describe("Health Controller", func() {
 	var controller *api.Controller
 
 	BeforeSuite(func() {
-		controller = api.NewController(config.Config{}, nil, nil)
+		controller = api.NewController(config.NewStore(config.Config{}), nil, nil, nil, nil, nil, metrics.NewMetrics())
 	})
 
 	Context("service is up", func() {
@@ -37,4 +38,22 @@ var _ = Describe("Health Controller", func() {
 			Expect(health.Status).To(Equal("up"))
 		})
 	})
+
+	Context("metrics", func() {
+
+		It("calling /metrics returns the recorded metrics", func() {
+			router := api.NewRouter(controller)
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/health", nil)
+			router.ServeHTTP(rr, req)
+
+			rr = httptest.NewRecorder()
+			req, _ = http.NewRequest(http.MethodGet, "/metrics", nil)
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			Expect(rr.Body.String()).To(ContainSubstring(`route="/health"`))
+		})
+	})
 })
@@ -0,0 +1,44 @@
+package api
+
+import "encoding/json"
+
+const MIME_MERGE_PATCH_JSON = "application/merge-patch+json"
+
+// applyMergePatch applies a JSON merge patch (RFC 7386) to the given JSON document
+func applyMergePatch(document []byte, patch []byte) ([]byte, error) {
+	var target interface{}
+	err := json.Unmarshal(document, &target)
+	if err != nil {
+		return nil, err
+	}
+
+	var patchValue interface{}
+	err = json.Unmarshal(patch, &patchValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(mergePatch(target, patchValue))
+}
+
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		// a patch that is not an object replaces the whole target
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergePatch(targetObject[name], value)
+	}
+	return targetObject
+}
@@ -6,20 +6,37 @@ import (
 	"github.com/go-openapi/spec"
 	"github.com/jenpaff/golang-microservices/common"
 	"github.com/jenpaff/golang-microservices/errors"
+	"github.com/jenpaff/golang-microservices/idempotency"
+	"github.com/jenpaff/golang-microservices/requestid"
+	"github.com/jenpaff/golang-microservices/tracing"
 	"net/http"
+	"time"
 )
 
 var (
-	tagsUser = []string{"user"}
+	tagsUser    = []string{"user"}
+	tagsToggles = []string{"toggles"}
 )
 
 func NewRouter(controller *Controller) http.Handler {
 	wsContainer := restful.NewContainer()
+	// the metrics filter comes first such that the latency includes all other filters
+	wsContainer.Filter(controller.metrics.Filter)
+	wsContainer.Filter(tracing.Filter)
+	wsContainer.Filter(requestid.Filter)
+	if controller.Cfg().Server.MaxBodyBytes > 0 {
+		wsContainer.Filter(limitBodySize(controller.Cfg().Server.MaxBodyBytes))
+	}
 	registerCorsFilter(wsContainer)
 
 	ws := newService(controller)
 	wsContainer.Add(ws)
 
+	// without a token anybody could flip toggles, so the admin API is only served with one
+	if len(controller.Cfg().ToggleStore.AdminTokens) > 0 {
+		wsContainer.Add(newToggleAdminService(controller))
+	}
+
 	swaggerConfig := restfulspec.Config{
 		WebServices: wsContainer.RegisteredWebServices(),
 		APIPath:     "/swagger.json",
@@ -37,6 +54,10 @@ func NewRouter(controller *Controller) http.Handler {
 	swaggerWs := restfulspec.NewOpenAPIService(swaggerConfig)
 	wsContainer.Add(swaggerWs)
 
+	if controller.Cfg().Metrics.AdminPort == "" {
+		wsContainer.Handle("/metrics", controller.metrics.Handler())
+	}
+
 	return wsContainer
 }
 
@@ -60,23 +81,159 @@ func newService(controller *Controller) *restful.WebService {
 			Produces(restful.MIME_JSON).
 			Returns(http.StatusOK, http.StatusText(http.StatusOK), common.User{}))
 
+	ws.Route(
+		ws.GET("/users/by-id/{id}").
+			To(errors.ErrorHandler(controller.GetUserByID)).
+			Doc("get users by id endpoint").
+			Param(ws.PathParameter("id", "id (uuid) of the user").DataType("string")).
+			Writes(common.User{}).
+			Metadata(restfulspec.KeyOpenAPITags, tagsUser).
+			Produces(restful.MIME_JSON).
+			Returns(http.StatusOK, http.StatusText(http.StatusOK), common.User{}).
+			Returns(http.StatusNotFound, http.StatusText(http.StatusNotFound), errors.ErrorResponse{}))
+
+	ws.Route(
+		ws.GET("/users").
+			To(errors.ErrorHandler(controller.ListUsers)).
+			Doc("list users endpoint, paginated by an opaque cursor").
+			Param(ws.QueryParameter("limit", "maximum number of users per page (1-100)").DataType("integer").DefaultValue("20")).
+			Param(ws.QueryParameter("cursor", "next_cursor returned with the previous page").DataType("string")).
+			Param(ws.QueryParameter("username_prefix", "only return users whose name starts with this prefix").DataType("string")).
+			Param(ws.QueryParameter("email_domain", "only return users with an email address of this domain").DataType("string")).
+			Param(ws.QueryParameter("has_phone_number", "only return users with (true) or without (false) a phone number").DataType("boolean")).
+			Writes(common.UserPage{}).
+			Metadata(restfulspec.KeyOpenAPITags, tagsUser).
+			Produces(restful.MIME_JSON).
+			Returns(http.StatusOK, http.StatusText(http.StatusOK), common.UserPage{}).
+			Returns(http.StatusBadRequest, http.StatusText(http.StatusBadRequest), errors.ErrorResponse{}))
+
 	ws.Route(
 		ws.POST("/users").
+			Filter(idempotency.Filter(controller.idempotencyStorage, time.Duration(controller.Cfg().Idempotency.KeyTTL))).
 			To(errors.ErrorHandler(controller.CreateUser)).
 			Doc("create users endpoint").
+			Param(ws.HeaderParameter(idempotency.HeaderIdempotencyKey, "retries with the same key and body return the first response, or 409 while the first request is processed").DataType("string")).
 			Writes(common.User{}).
 			Produces(restful.MIME_JSON).
 			Reads(UserCreationRequest{}).
 			Metadata(restfulspec.KeyOpenAPITags, tagsUser).
-			Returns(http.StatusOK, http.StatusText(http.StatusOK), common.User{}))
+			Returns(http.StatusOK, http.StatusText(http.StatusOK), common.User{}).
+			Returns(http.StatusConflict, http.StatusText(http.StatusConflict), errors.ErrorResponse{}).
+			Returns(http.StatusUnprocessableEntity, http.StatusText(http.StatusUnprocessableEntity), errors.ErrorResponse{}))
+
+	ws.Route(
+		ws.PUT("/users/{userName}").
+			To(errors.ErrorHandler(controller.UpdateUser)).
+			Doc("replace users endpoint").
+			Param(ws.PathParameter("userName", "name of the user").DataType("string")).
+			Writes(common.User{}).
+			Produces(restful.MIME_JSON).
+			Reads(UserUpdateRequest{}).
+			Metadata(restfulspec.KeyOpenAPITags, tagsUser).
+			Returns(http.StatusOK, http.StatusText(http.StatusOK), common.User{}).
+			Returns(http.StatusNotFound, http.StatusText(http.StatusNotFound), errors.ErrorResponse{}).
+			Returns(http.StatusConflict, http.StatusText(http.StatusConflict), errors.ErrorResponse{}))
+
+	ws.Route(
+		ws.PATCH("/users/{userName}").
+			To(errors.ErrorHandler(controller.PatchUser)).
+			Doc("patch users endpoint, accepts a JSON merge patch (RFC 7386)").
+			Param(ws.PathParameter("userName", "name of the user").DataType("string")).
+			Writes(common.User{}).
+			Consumes(MIME_MERGE_PATCH_JSON, restful.MIME_JSON).
+			Produces(restful.MIME_JSON).
+			Reads(common.User{}).
+			Metadata(restfulspec.KeyOpenAPITags, tagsUser).
+			Returns(http.StatusOK, http.StatusText(http.StatusOK), common.User{}).
+			Returns(http.StatusNotFound, http.StatusText(http.StatusNotFound), errors.ErrorResponse{}).
+			Returns(http.StatusConflict, http.StatusText(http.StatusConflict), errors.ErrorResponse{}))
+
+	ws.Route(
+		ws.DELETE("/users/{userName}").
+			To(errors.ErrorHandler(controller.DeleteUser)).
+			Doc("delete users endpoint").
+			Param(ws.PathParameter("userName", "name of the user").DataType("string")).
+			Produces(restful.MIME_JSON).
+			Metadata(restfulspec.KeyOpenAPITags, tagsUser).
+			Returns(http.StatusNoContent, http.StatusText(http.StatusNoContent), nil).
+			Returns(http.StatusNotFound, http.StatusText(http.StatusNotFound), errors.ErrorResponse{}))
+	return ws
+}
+
+func newToggleAdminService(controller *Controller) *restful.WebService {
+	ws := new(restful.WebService)
+	ws.Path("/admin/toggles").
+		Filter(adminAuth(controller.Cfg().ToggleStore.AdminTokens)).
+		Produces(restful.MIME_JSON)
+
+	ws.Route(
+		ws.GET("/stale").
+			To(errors.ErrorHandler(controller.ListStaleToggles)).
+			Doc("list the toggles past their expiry date").
+			Writes([]StaleToggleResponse{}).
+			Metadata(restfulspec.KeyOpenAPITags, tagsToggles).
+			Returns(http.StatusOK, http.StatusText(http.StatusOK), []StaleToggleResponse{}))
+
+	// the stale report also covers the toggles of the config files, the other routes need the toggle store
+	if controller.toggleStore == nil {
+		return ws
+	}
+
+	ws.Route(
+		ws.GET("").
+			To(errors.ErrorHandler(controller.ListToggles)).
+			Doc("list the toggles of the config files and the stored toggles replacing them").
+			Writes([]ToggleResponse{}).
+			Metadata(restfulspec.KeyOpenAPITags, tagsToggles).
+			Returns(http.StatusOK, http.StatusText(http.StatusOK), []ToggleResponse{}).
+			Returns(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), errors.ErrorResponse{}))
+
+	ws.Route(
+		ws.POST("").
+			To(errors.ErrorHandler(controller.CreateToggle)).
+			Doc("store a new toggle").
+			Reads(ToggleCreationRequest{}).
+			Writes(ToggleResponse{}).
+			Metadata(restfulspec.KeyOpenAPITags, tagsToggles).
+			Returns(http.StatusCreated, http.StatusText(http.StatusCreated), ToggleResponse{}).
+			Returns(http.StatusConflict, http.StatusText(http.StatusConflict), errors.ErrorResponse{}))
+
+	ws.Route(
+		ws.PATCH("/{name}").
+			To(errors.ErrorHandler(controller.FlipToggle)).
+			Doc("enable or disable a toggle").
+			Param(ws.PathParameter("name", "name of the toggle").DataType("string")).
+			Reads(ToggleFlipRequest{}).
+			Writes(ToggleResponse{}).
+			Metadata(restfulspec.KeyOpenAPITags, tagsToggles).
+			Returns(http.StatusOK, http.StatusText(http.StatusOK), ToggleResponse{}).
+			Returns(http.StatusNotFound, http.StatusText(http.StatusNotFound), errors.ErrorResponse{}))
+
+	ws.Route(
+		ws.DELETE("/{name}").
+			To(errors.ErrorHandler(controller.DeleteToggle)).
+			Doc("delete a stored toggle, the toggle of the config files applies again").
+			Param(ws.PathParameter("name", "name of the toggle").DataType("string")).
+			Metadata(restfulspec.KeyOpenAPITags, tagsToggles).
+			Returns(http.StatusNoContent, http.StatusText(http.StatusNoContent), nil).
+			Returns(http.StatusNotFound, http.StatusText(http.StatusNotFound), errors.ErrorResponse{}))
+
+	ws.Route(
+		ws.GET("/{name}/changes").
+			To(errors.ErrorHandler(controller.ListToggleChanges)).
+			Doc("list who changed a stored toggle and when").
+			Param(ws.PathParameter("name", "name of the toggle").DataType("string")).
+			Writes([]ToggleChangeResponse{}).
+			Metadata(restfulspec.KeyOpenAPITags, tagsToggles).
+			Returns(http.StatusOK, http.StatusText(http.StatusOK), []ToggleChangeResponse{}))
 	return ws
 }
 
 func registerCorsFilter(wsContainer *restful.Container) {
 	cors := restful.CrossOriginResourceSharing{
-		ExposeHeaders:  []string{"X-My-Header"},
-		AllowedHeaders: []string{"Content-Type", "Accept"},
-		AllowedMethods: []string{"HEAD", "GET", "POST", "PUT", "DELETE"},
+		ExposeHeaders:  []string{"X-My-Header", requestid.Header},
+		AllowedHeaders: []string{"Content-Type", "Accept", "Authorization", idempotency.HeaderIdempotencyKey, requestid.Header, "traceparent", "tracestate"},
+		AllowedMethods: []string{"HEAD", "GET", "POST", "PUT", "PATCH", "DELETE"},
 		CookiesAllowed: false,
 		Container:      wsContainer}
 	wsContainer.Filter(cors.Filter)
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/go-playground/log"
+	"github.com/jenpaff/golang-microservices/config"
+	"github.com/jenpaff/golang-microservices/errors"
+	"github.com/jenpaff/golang-microservices/featuretoggles"
+	"github.com/jenpaff/golang-microservices/validation"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	// operatorAttribute is the request attribute adminAuth stores the operator of the admin token in
+	operatorAttribute = "toggleOperator"
+
+	toggleSourceConfig = "config"
+	toggleSourceStore  = "store"
+)
+
+// adminAuth only lets requests with the bearer token of an operator through, the operator is the author of the
+// changes made by the request
+func adminAuth(tokens map[string]string) restful.FilterFunction {
+	expected := make(map[string][]byte, len(tokens))
+	for operator, token := range tokens {
+		expected[operator] = []byte("Bearer " + token)
+	}
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		authorization := []byte(req.HeaderParameter("Authorization"))
+		operator := ""
+		// every token is compared such that the time taken does not tell how many tokens were tried
+		for name, token := range expected {
+			if subtle.ConstantTimeCompare(authorization, token) == 1 {
+				operator = name
+			}
+		}
+		if operator == "" {
+			errors.WriteError(req, resp, fmt.Errorf("missing or invalid admin token: %w", errors.Unauthorized))
+			return
+		}
+		req.SetAttribute(operatorAttribute, operator)
+		chain.ProcessFilter(req, resp)
+	}
+}
+
+func (c *Controller) ListToggles(req *restful.Request, resp *restful.Response) error {
+
+	log.GetContext(req.Request.Context()).Info("list toggles endpoint was invoked")
+
+	toggles := make(map[string]ToggleResponse)
+	for name, toggle := range c.Cfg().FeatureToggles {
+		toggles[name] = ToggleResponse{Name: name, Toggle: toggle, Source: toggleSourceConfig}
+	}
+	for _, stored := range c.toggleStore.List() {
+		updatedAt := stored.UpdatedAt
+		toggles[stored.Name] = ToggleResponse{Name: stored.Name, Toggle: stored.Toggle, Source: toggleSourceStore, UpdatedBy: stored.UpdatedBy, UpdatedAt: &updatedAt}
+	}
+
+	response := make([]ToggleResponse, 0, len(toggles))
+	for _, toggle := range toggles {
+		response = append(response, toggle)
+	}
+	sort.Slice(response, func(i, j int) bool { return response[i].Name < response[j].Name })
+
+	err := resp.WriteEntity(response)
+	if err != nil {
+		return fmt.Errorf("error writing response: %w", err)
+	}
+	return nil
+}
+
+// ListStaleToggles reports the expired toggles, the stored toggles replace the ones of the config files
+func (c *Controller) ListStaleToggles(req *restful.Request, resp *restful.Response) error {
+
+	log.GetContext(req.Request.Context()).Info("list stale toggles endpoint was invoked")
+
+	toggles := featuretoggles.EffectiveToggles(c.Cfg().FeatureToggles, c.toggleStore)
+	stale := featuretoggles.StaleToggles(toggles, time.Now().UTC())
+
+	response := make([]StaleToggleResponse, len(stale))
+	for i, toggle := range stale {
+		response[i] = StaleToggleResponse{Name: toggle.Name, Owner: toggle.Owner, Description: toggle.Description, Expires: toggle.Expires, DaysOverdue: toggle.DaysOverdue}
+	}
+
+	err := resp.WriteEntity(response)
+	if err != nil {
+		return fmt.Errorf("error writing response: %w", err)
+	}
+	return nil
+}
+
+func (c *Controller) CreateToggle(req *restful.Request, resp *restful.Response) error {
+
+	log.GetContext(req.Request.Context()).Info("create toggle endpoint was invoked")
+
+	author := getAuthor(req)
+
+	bytes, err := ioutil.ReadAll(req.Request.Body)
+	if err != nil {
+		return fmt.Errorf("could read request body: %w", err)
+	}
+
+	var creationRequest ToggleCreationRequest
+	err = json.Unmarshal(bytes, &creationRequest)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal the toggle request: %s: %w", err.Error(), errors.InvalidInput)
+	}
+
+	err = c.validator.Struct(creationRequest)
+	if err != nil {
+		return validation.GetValidationError(err)
+	}
+	err = config.ValidateToggle(creationRequest.Toggle)
+	if err != nil {
+		return fmt.Errorf("invalid toggle %s: %w", creationRequest.Name, err)
+	}
+
+	err = c.toggleStore.Create(req.Request.Context(), creationRequest.Name, creationRequest.Toggle, author)
+	if err != nil {
+		return fmt.Errorf("could not create toggle %s: %w", creationRequest.Name, err)
+	}
+	log.GetContext(req.Request.Context()).Noticef("toggle '%v' created by %v", creationRequest.Name, author)
+
+	return c.writeStoredToggle(req, resp, creationRequest.Name, http.StatusCreated)
+}
+
+// FlipToggle enables or disables a toggle, a toggle only defined in the config files is stored with the new state
+func (c *Controller) FlipToggle(req *restful.Request, resp *restful.Response) error {
+
+	log.GetContext(req.Request.Context()).Info("flip toggle endpoint was invoked")
+
+	author := getAuthor(req)
+
+	name := req.PathParameter("name")
+
+	bytes, err := ioutil.ReadAll(req.Request.Body)
+	if err != nil {
+		return fmt.Errorf("could read request body: %w", err)
+	}
+
+	var flipRequest ToggleFlipRequest
+	err = json.Unmarshal(bytes, &flipRequest)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal the toggle request: %s: %w", err.Error(), errors.InvalidInput)
+	}
+
+	err = c.validator.Struct(flipRequest)
+	if err != nil {
+		return validation.GetValidationError(err)
+	}
+
+	toggle, ok := c.toggleStore.Toggles()[name]
+	if !ok {
+		toggle, ok = c.Cfg().FeatureToggles[name]
+	}
+	if !ok {
+		return fmt.Errorf("toggle %s is not defined: %w", name, errors.ToggleNotFound)
+	}
+	toggle.Enabled = *flipRequest.Enabled
+	err = config.ValidateToggle(toggle)
+	if err != nil {
+		return fmt.Errorf("invalid toggle %s: %w", name, err)
+	}
+
+	err = c.toggleStore.Save(req.Request.Context(), name, toggle, author)
+	if err != nil {
+		return fmt.Errorf("could not flip toggle %s: %w", name, err)
+	}
+	log.GetContext(req.Request.Context()).Noticef("toggle '%v' switched to '%v' by %v", name, toggle.Enabled, author)
+
+	return c.writeStoredToggle(req, resp, name, http.StatusOK)
+}
+
+// DeleteToggle removes a stored toggle, the toggle of the config files applies again if there is one
+func (c *Controller) DeleteToggle(req *restful.Request, resp *restful.Response) error {
+
+	log.GetContext(req.Request.Context()).Info("delete toggle endpoint was invoked")
+
+	author := getAuthor(req)
+
+	name := req.PathParameter("name")
+	err := c.toggleStore.Delete(req.Request.Context(), name, author)
+	if err != nil {
+		return fmt.Errorf("could not delete toggle %s: %w", name, err)
+	}
+	log.GetContext(req.Request.Context()).Noticef("toggle '%v' deleted by %v", name, author)
+
+	resp.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (c *Controller) ListToggleChanges(req *restful.Request, resp *restful.Response) error {
+
+	log.GetContext(req.Request.Context()).Info("list toggle changes endpoint was invoked")
+
+	name := req.PathParameter("name")
+	changes, err := c.toggleStore.Changes(req.Request.Context(), name)
+	if err != nil {
+		return fmt.Errorf("could not list changes of toggle %s: %w", name, err)
+	}
+
+	response := make([]ToggleChangeResponse, len(changes))
+	for i, change := range changes {
+		response[i] = ToggleChangeResponse{Action: change.Action, Toggle: change.Toggle, ChangedBy: change.ChangedBy, ChangedAt: change.ChangedAt}
+	}
+
+	err = resp.WriteEntity(response)
+	if err != nil {
+		return fmt.Errorf("error writing response: %w", err)
+	}
+	return nil
+}
+
+func (c *Controller) writeStoredToggle(req *restful.Request, resp *restful.Response, name string, status int) error {
+	for _, stored := range c.toggleStore.List() {
+		if stored.Name == name {
+			updatedAt := stored.UpdatedAt
+			err := resp.WriteHeaderAndEntity(status, ToggleResponse{Name: name, Toggle: stored.Toggle, Source: toggleSourceStore, UpdatedBy: stored.UpdatedBy, UpdatedAt: &updatedAt})
+			if err != nil {
+				log.GetContext(req.Request.Context()).Errorf("could not write response: %s", err.Error())
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("toggle %s was saved but is not in the store: %w", name, errors.InternalServerError)
+}
+
+// getAuthor returns the operator whose admin token authorized the request
+func getAuthor(req *restful.Request) string {
+	return req.Attribute(operatorAttribute).(string)
+}
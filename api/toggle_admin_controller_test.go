@@ -0,0 +1,239 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"github.com/jenpaff/golang-microservices/api"
+	"github.com/jenpaff/golang-microservices/config"
+	custom_errors "github.com/jenpaff/golang-microservices/errors"
+	"github.com/jenpaff/golang-microservices/featuretoggles"
+	"github.com/jenpaff/golang-microservices/metrics"
+	test_helper "github.com/jenpaff/golang-microservices/test-helper"
+	"github.com/jenpaff/golang-microservices/validation"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+var _ = Describe("ToggleAdminController", func() {
+
+	var mockController *gomock.Controller
+	var router http.Handler
+	var storageMock *featuretoggles.MockStorage
+	var cfg config.Config
+	var toggleStore *featuretoggles.Store
+	updatedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	BeforeEach(func() {
+		mockController = gomock.NewController(test_helper.GinkgoTestReporter{})
+		storageMock = featuretoggles.NewMockStorage(mockController)
+		toggleStore = featuretoggles.NewStore(storageMock)
+		validator, _ := validation.NewValidate("DE")
+		cfg = config.Config{
+			FeatureToggles: config.FeatureToggles{"enableNewFeature": {Enabled: false}},
+			ToggleStore:    config.ToggleStoreConfig{Enabled: true, AdminTokens: map[string]string{"jane": "secret-token", "john": "other-token"}},
+		}
+		controller := api.NewController(config.NewStore(cfg), nil, validator, nil, toggleStore, nil, metrics.NewMetrics())
+		router = api.NewRouter(controller)
+	})
+
+	AfterEach(func() {
+		mockController.Finish()
+	})
+
+	adminRequest := func(method string, path string, body io.Reader) *http.Request {
+		req, _ := http.NewRequest(method, path, body)
+		req.Header.Set("Authorization", "Bearer secret-token")
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	expectReload := func(toggles ...featuretoggles.StoredToggle) {
+		storageMock.EXPECT().List(gomock.Any()).Return(toggles, nil)
+	}
+
+	It("rejects requests without the admin token", func() {
+		rr := httptest.NewRecorder()
+		req := adminRequest(http.MethodGet, "/admin/toggles", nil)
+		req.Header.Set("Authorization", "Bearer wrong-token")
+
+		router.ServeHTTP(rr, req)
+
+		Expect(rr.Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("does not serve the admin API without an admin token", func() {
+		controller := api.NewController(config.NewStore(config.Config{}), nil, nil, nil, toggleStore, nil, metrics.NewMetrics())
+		rr := httptest.NewRecorder()
+
+		api.NewRouter(controller).ServeHTTP(rr, adminRequest(http.MethodGet, "/admin/toggles", nil))
+
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("reports the expired toggles of the config files without a toggle store", func() {
+		expired := config.NewDate(time.Now().AddDate(0, 0, -3))
+		cfg := config.Config{
+			FeatureToggles: config.FeatureToggles{"oldToggle": {Enabled: true, Expires: &expired}},
+			ToggleStore:    config.ToggleStoreConfig{AdminTokens: map[string]string{"jane": "secret-token"}},
+		}
+		router := api.NewRouter(api.NewController(config.NewStore(cfg), nil, nil, nil, nil, nil, metrics.NewMetrics()))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, adminRequest(http.MethodGet, "/admin/toggles/stale", nil))
+
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		var stale []api.StaleToggleResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &stale)).To(Succeed())
+		Expect(stale).To(HaveLen(1))
+		Expect(stale[0].Name).To(Equal("oldToggle"))
+
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, adminRequest(http.MethodGet, "/admin/toggles", nil))
+
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("lists the config toggles replaced by the stored toggles", func() {
+		expectReload(featuretoggles.StoredToggle{Name: "enableNewFeature", Toggle: config.FeatureToggle{Enabled: true}, UpdatedBy: "john", UpdatedAt: updatedAt})
+		Expect(toggleStore.Refresh(context.Background())).To(Succeed())
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, adminRequest(http.MethodGet, "/admin/toggles", nil))
+
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		var toggles []api.ToggleResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &toggles)).To(Succeed())
+		Expect(toggles).To(HaveLen(1))
+		Expect(toggles[0].Toggle.Enabled).To(BeTrue())
+		Expect(toggles[0].Source).To(Equal("store"))
+		Expect(toggles[0].UpdatedBy).To(Equal("john"))
+	})
+
+	It("reports the expired toggles", func() {
+		expired := config.NewDate(time.Now().AddDate(0, 0, -3))
+		expectReload(featuretoggles.StoredToggle{Name: "oldToggle", Toggle: config.FeatureToggle{Enabled: true, Owner: "checkout-team", Expires: &expired}, UpdatedBy: "john", UpdatedAt: updatedAt})
+		Expect(toggleStore.Refresh(context.Background())).To(Succeed())
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, adminRequest(http.MethodGet, "/admin/toggles/stale", nil))
+
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		var stale []api.StaleToggleResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &stale)).To(Succeed())
+		Expect(stale).To(HaveLen(1))
+		Expect(stale[0].Name).To(Equal("oldToggle"))
+		Expect(stale[0].Owner).To(Equal("checkout-team"))
+		Expect(stale[0].Expires).To(Equal(expired))
+		Expect(stale[0].DaysOverdue).To(Equal(3))
+	})
+
+	It("creates a toggle with the operator of the admin token as author", func() {
+		storageMock.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, toggle featuretoggles.StoredToggle) error {
+			Expect(toggle.Name).To(Equal("newToggle"))
+			Expect(toggle.Toggle.Enabled).To(BeTrue())
+			Expect(toggle.UpdatedBy).To(Equal("jane"))
+			return nil
+		})
+		expectReload(featuretoggles.StoredToggle{Name: "newToggle", Toggle: config.FeatureToggle{Enabled: true}, UpdatedBy: "jane", UpdatedAt: updatedAt})
+
+		rr := httptest.NewRecorder()
+		body := bytes.NewBufferString(`{"name": "newToggle", "toggle": true}`)
+		router.ServeHTTP(rr, adminRequest(http.MethodPost, "/admin/toggles", body))
+
+		Expect(rr.Code).To(Equal(http.StatusCreated))
+		var toggle api.ToggleResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &toggle)).To(Succeed())
+		Expect(toggle.Name).To(Equal("newToggle"))
+		Expect(toggle.UpdatedBy).To(Equal("jane"))
+		Expect(*toggle.UpdatedAt).To(Equal(updatedAt))
+	})
+
+	It("returns conflict when the toggle is stored already", func() {
+		storageMock.EXPECT().Create(gomock.Any(), gomock.Any()).Return(custom_errors.NewConflictError("name"))
+
+		rr := httptest.NewRecorder()
+		body := bytes.NewBufferString(`{"name": "newToggle", "toggle": true}`)
+		router.ServeHTTP(rr, adminRequest(http.MethodPost, "/admin/toggles", body))
+
+		Expect(rr.Code).To(Equal(http.StatusConflict))
+	})
+
+	It("rejects toggles the config files would reject", func() {
+		rr := httptest.NewRecorder()
+		body := bytes.NewBufferString(`{"name": "newToggle", "toggle": {"enabled": true, "permanent": true, "expires": "2021-06-01"}}`)
+		router.ServeHTTP(rr, adminRequest(http.MethodPost, "/admin/toggles", body))
+
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		var errorResponse custom_errors.ErrorResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &errorResponse)).To(Succeed())
+		Expect(errorResponse.Errors).To(HaveLen(1))
+		Expect(errorResponse.Errors[0].Field).To(Equal("expires"))
+	})
+
+	It("records the operator of the admin token as author", func() {
+		storageMock.EXPECT().Delete(gomock.Any(), "enableNewFeature", "john", gomock.Any()).Return(nil)
+		expectReload()
+
+		rr := httptest.NewRecorder()
+		req := adminRequest(http.MethodDelete, "/admin/toggles/enableNewFeature", nil)
+		req.Header.Set("Authorization", "Bearer other-token")
+
+		router.ServeHTTP(rr, req)
+
+		Expect(rr.Code).To(Equal(http.StatusNoContent))
+	})
+
+	It("flips a toggle of the config files by storing it", func() {
+		storageMock.EXPECT().Save(gomock.Any(), gomock.Any()).DoAndReturn(func(_ interface{}, toggle featuretoggles.StoredToggle) error {
+			Expect(toggle.Name).To(Equal("enableNewFeature"))
+			Expect(toggle.Toggle.Enabled).To(BeTrue())
+			return nil
+		})
+		expectReload(featuretoggles.StoredToggle{Name: "enableNewFeature", Toggle: config.FeatureToggle{Enabled: true}, UpdatedBy: "jane", UpdatedAt: updatedAt})
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, adminRequest(http.MethodPatch, "/admin/toggles/enableNewFeature", bytes.NewBufferString(`{"enabled": true}`)))
+
+		Expect(rr.Code).To(Equal(http.StatusOK))
+	})
+
+	It("returns not found when flipping an unknown toggle", func() {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, adminRequest(http.MethodPatch, "/admin/toggles/unknown", bytes.NewBufferString(`{"enabled": true}`)))
+
+		Expect(rr.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("deletes a stored toggle", func() {
+		storageMock.EXPECT().Delete(gomock.Any(), "enableNewFeature", "jane", gomock.Any()).Return(nil)
+		expectReload()
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, adminRequest(http.MethodDelete, "/admin/toggles/enableNewFeature", nil))
+
+		Expect(rr.Code).To(Equal(http.StatusNoContent))
+	})
+
+	It("lists the changes of a toggle", func() {
+		storageMock.EXPECT().Changes(gomock.Any(), "enableNewFeature").Return([]featuretoggles.Change{
+			{ID: 1, Name: "enableNewFeature", Action: featuretoggles.ActionCreated, Toggle: &config.FeatureToggle{Enabled: true}, ChangedBy: "jane", ChangedAt: updatedAt},
+			{ID: 2, Name: "enableNewFeature", Action: featuretoggles.ActionDeleted, ChangedBy: "john", ChangedAt: updatedAt},
+		}, nil)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, adminRequest(http.MethodGet, "/admin/toggles/enableNewFeature/changes", nil))
+
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		var changes []api.ToggleChangeResponse
+		Expect(json.Unmarshal(rr.Body.Bytes(), &changes)).To(Succeed())
+		Expect(changes).To(HaveLen(2))
+		Expect(changes[1].Action).To(Equal(featuretoggles.ActionDeleted))
+		Expect(changes[1].ChangedBy).To(Equal("john"))
+	})
+})
@@ -1,7 +1,51 @@
 package api
 
+import (
+	"github.com/jenpaff/golang-microservices/config"
+	"time"
+)
+
 type UserCreationRequest struct {
 	UserName    string `json:"name" validate:"required,validRegexInput"`
-	Email       string `json:"email" validate:"required"`
-	PhoneNumber string `json:"phone_number" validate:"required"`
+	Email       string `json:"email" validate:"required,emailAddress"`
+	PhoneNumber string `json:"phone_number" validate:"required,phoneNumber"`
+}
+
+type UserUpdateRequest struct {
+	Email       string `json:"email" validate:"required,emailAddress"`
+	PhoneNumber string `json:"phone_number" validate:"required,phoneNumber"`
+}
+
+type ToggleCreationRequest struct {
+	Name   string               `json:"name" validate:"required,max=255,validRegexInput"`
+	Toggle config.FeatureToggle `json:"toggle"`
+}
+
+type ToggleFlipRequest struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+}
+
+// ToggleResponse is a toggle as it is evaluated, Source tells whether it is stored or comes from the config files
+type ToggleResponse struct {
+	Name      string               `json:"name"`
+	Toggle    config.FeatureToggle `json:"toggle"`
+	Source    string               `json:"source"`
+	UpdatedBy string               `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time           `json:"updated_at,omitempty"`
+}
+
+// StaleToggleResponse is a toggle past its expiry date, it should be removed from the code
+type StaleToggleResponse struct {
+	Name        string      `json:"name"`
+	Owner       string      `json:"owner,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Expires     config.Date `json:"expires"`
+	DaysOverdue int         `json:"days_overdue"`
+}
+
+type ToggleChangeResponse struct {
+	Action    string                `json:"action"`
+	Toggle    *config.FeatureToggle `json:"toggle,omitempty"`
+	ChangedBy string                `json:"changed_by"`
+	ChangedAt time.Time             `json:"changed_at"`
 }
@@ -5,17 +5,25 @@ import (
 	"fmt"
 	"github.com/emicklei/go-restful/v3"
 	"github.com/go-playground/log"
+	"github.com/google/uuid"
 	"github.com/jenpaff/golang-microservices/common"
 	"github.com/jenpaff/golang-microservices/errors"
 	"github.com/jenpaff/golang-microservices/featuretoggles"
 	"github.com/jenpaff/golang-microservices/validation"
 	"io/ioutil"
+	"net/http"
+	"strconv"
 	"strings"
 )
 
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
 func (c *Controller) GetUser(req *restful.Request, resp *restful.Response) error {
 
-	log.Info("user endpoint was invoked")
+	log.GetContext(req.Request.Context()).Info("user endpoint was invoked")
 
 	userName := req.PathParameter("userName")
 	if strings.TrimSpace(userName) == "" {
@@ -30,14 +38,84 @@ func (c *Controller) GetUser(req *restful.Request, resp *restful.Response) error
 	if err != nil {
 		return fmt.Errorf("error writing response: %w", err)
 	}
-	log.Info("health endpoint ran successfully")
+	log.GetContext(req.Request.Context()).Info("health endpoint ran successfully")
 
 	return nil
 }
 
+func (c *Controller) GetUserByID(req *restful.Request, resp *restful.Response) error {
+
+	log.GetContext(req.Request.Context()).Info("user by id endpoint was invoked")
+
+	id := req.PathParameter("id")
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("you must provide a valid user id: %s: %w", err.Error(), errors.BadRequest)
+	}
+
+	user, err := c.userService.GetUserByID(req.Request.Context(), id)
+	if err != nil {
+		return fmt.Errorf("error retrieving user with id %s: %w", id, err)
+	}
+	err = resp.WriteEntity(user)
+	if err != nil {
+		return fmt.Errorf("error writing response: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Controller) ListUsers(req *restful.Request, resp *restful.Response) error {
+
+	log.GetContext(req.Request.Context()).Info("list users endpoint was invoked")
+
+	filter, err := userFilterFromRequest(req)
+	if err != nil {
+		return err
+	}
+
+	page, err := c.userService.ListUsers(req.Request.Context(), filter)
+	if err != nil {
+		return fmt.Errorf("error listing users: %w", err)
+	}
+
+	err = resp.WriteEntity(page)
+	if err != nil {
+		return fmt.Errorf("error writing response: %w", err)
+	}
+
+	return nil
+}
+
+func userFilterFromRequest(req *restful.Request) (common.UserFilter, error) {
+	filter := common.UserFilter{
+		Limit:          defaultPageSize,
+		Cursor:         req.QueryParameter("cursor"),
+		UserNamePrefix: req.QueryParameter("username_prefix"),
+		EmailDomain:    req.QueryParameter("email_domain"),
+	}
+
+	if limit := req.QueryParameter("limit"); limit != "" {
+		parsedLimit, err := strconv.Atoi(limit)
+		if err != nil || parsedLimit < 1 || parsedLimit > maxPageSize {
+			return filter, fmt.Errorf("limit must be a number between 1 and %d but was %s: %w", maxPageSize, limit, errors.BadRequest)
+		}
+		filter.Limit = parsedLimit
+	}
+
+	if hasPhoneNumber := req.QueryParameter("has_phone_number"); hasPhoneNumber != "" {
+		parsedHasPhoneNumber, err := strconv.ParseBool(hasPhoneNumber)
+		if err != nil {
+			return filter, fmt.Errorf("has_phone_number must be a boolean but was %s: %w", hasPhoneNumber, errors.BadRequest)
+		}
+		filter.HasPhoneNumber = &parsedHasPhoneNumber
+	}
+
+	return filter, nil
+}
+
 func (c *Controller) CreateUser(req *restful.Request, resp *restful.Response) error {
 
-	log.Info("save user endpoint was invoked")
+	log.GetContext(req.Request.Context()).Info("save user endpoint was invoked")
 
 	var err error
 
@@ -59,8 +137,12 @@ func (c *Controller) CreateUser(req *restful.Request, resp *restful.Response) er
 
 	var createdUser *common.User
 
-	ft := featuretoggles.NewFeatureToggles(&c.Cfg, req)
-	if ft.IsEnabled("enableNewFeature") {
+	ft, err := c.toggles.ForRequest(req)
+	if err != nil {
+		return fmt.Errorf("could not evaluate feature toggles: %w", err)
+	}
+	// enableNewFeature is rolled out by username, see the percentage of the toggle in the config files
+	if ft.IsEnabled(featuretoggles.EvaluationContext{UserKey: creationRequest.UserName}, "enableNewFeature") {
 		createdUser, err = c.userService.CreateUserWithNewFeature(req.Request.Context(), creationRequest.UserName, creationRequest.Email, creationRequest.PhoneNumber)
 		if err != nil {
 			return fmt.Errorf("could not create user: %w", err)
@@ -74,9 +156,114 @@ func (c *Controller) CreateUser(req *restful.Request, resp *restful.Response) er
 
 	err = resp.WriteEntity(createdUser)
 	if err != nil {
-		log.Errorf("could not write response: %s", err.Error())
+		log.GetContext(req.Request.Context()).Errorf("could not write response: %s", err.Error())
+	}
+
+	return nil
+
+}
+
+func (c *Controller) UpdateUser(req *restful.Request, resp *restful.Response) error {
+
+	log.GetContext(req.Request.Context()).Info("update user endpoint was invoked")
+
+	userName := req.PathParameter("userName")
+	if strings.TrimSpace(userName) == "" {
+		return fmt.Errorf("you must provide a valid username: %w", errors.BadRequest)
+	}
+
+	bytes, err := ioutil.ReadAll(req.Request.Body)
+	if err != nil {
+		return fmt.Errorf("could not read request body: %w", err)
+	}
+
+	var updateRequest UserUpdateRequest
+	err = json.Unmarshal(bytes, &updateRequest)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal the user request: %s: %w", err.Error(), errors.BadRequest)
+	}
+
+	return c.updateUser(req, resp, userName, updateRequest)
+}
+
+func (c *Controller) PatchUser(req *restful.Request, resp *restful.Response) error {
+
+	log.GetContext(req.Request.Context()).Info("patch user endpoint was invoked")
+
+	userName := req.PathParameter("userName")
+	if strings.TrimSpace(userName) == "" {
+		return fmt.Errorf("you must provide a valid username: %w", errors.BadRequest)
+	}
+
+	patch, err := ioutil.ReadAll(req.Request.Body)
+	if err != nil {
+		return fmt.Errorf("could not read request body: %w", err)
+	}
+
+	user, err := c.userService.GetUser(req.Request.Context(), userName)
+	if err != nil {
+		return fmt.Errorf("error retrieving user with username %s: %w", userName, err)
+	}
+
+	document, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("could not marshal user with username %s: %w", userName, err)
+	}
+
+	patchedDocument, err := applyMergePatch(document, patch)
+	if err != nil {
+		return fmt.Errorf("could not apply merge patch: %s: %w", err.Error(), errors.BadRequest)
+	}
+
+	var patchedUser common.User
+	err = json.Unmarshal(patchedDocument, &patchedUser)
+	if err != nil {
+		return fmt.Errorf("could not unmarshal the patched user: %s: %w", err.Error(), errors.BadRequest)
+	}
+
+	if patchedUser.UserName != user.UserName {
+		return fmt.Errorf("the username of user %s cannot be changed: %w", userName, errors.BadRequest)
+	}
+
+	return c.updateUser(req, resp, userName, UserUpdateRequest{
+		Email:       patchedUser.Email,
+		PhoneNumber: patchedUser.PhoneNumber,
+	})
+}
+
+func (c *Controller) updateUser(req *restful.Request, resp *restful.Response, userName string, updateRequest UserUpdateRequest) error {
+	err := c.validator.Struct(updateRequest)
+	if err != nil {
+		return validation.GetValidationError(err)
+	}
+
+	updatedUser, err := c.userService.UpdateUser(req.Request.Context(), userName, updateRequest.Email, updateRequest.PhoneNumber)
+	if err != nil {
+		return fmt.Errorf("could not update user with username %s: %w", userName, err)
+	}
+
+	err = resp.WriteEntity(updatedUser)
+	if err != nil {
+		log.GetContext(req.Request.Context()).Errorf("could not write response: %s", err.Error())
 	}
 
 	return nil
+}
+
+func (c *Controller) DeleteUser(req *restful.Request, resp *restful.Response) error {
+
+	log.GetContext(req.Request.Context()).Info("delete user endpoint was invoked")
+
+	userName := req.PathParameter("userName")
+	if strings.TrimSpace(userName) == "" {
+		return fmt.Errorf("you must provide a valid username: %w", errors.BadRequest)
+	}
 
+	err := c.userService.DeleteUser(req.Request.Context(), userName)
+	if err != nil {
+		return fmt.Errorf("could not delete user with username %s: %w", userName, err)
+	}
+
+	resp.WriteHeader(http.StatusNoContent)
+	return nil
 }
@@ -8,12 +8,15 @@ import (
 	"github.com/jenpaff/golang-microservices/api"
 	"github.com/jenpaff/golang-microservices/common"
 	"github.com/jenpaff/golang-microservices/config"
+	"github.com/jenpaff/golang-microservices/featuretoggles"
+	"github.com/jenpaff/golang-microservices/metrics"
 	custom_errors "github.com/jenpaff/golang-microservices/errors"
 	test_helper "github.com/jenpaff/golang-microservices/test-helper"
 	"github.com/jenpaff/golang-microservices/users"
 	"github.com/jenpaff/golang-microservices/validation"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"io"
 	"net/http"
 	"net/http/httptest"
 )
@@ -28,8 +31,14 @@ var _ = Describe("UserController", func() {
 	BeforeEach(func() {
 		mockController = gomock.NewController(test_helper.GinkgoTestReporter{})
 		userServiceMock = users.NewMockService(mockController)
-		validator, _ := validation.NewValidate()
-		controller = api.NewController(config.Config{}, userServiceMock, validator)
+		validator, _ := validation.NewValidate("DE")
+		cfg := config.Config{
+			FeatureToggles:  config.FeatureToggles{"enableNewFeature": {Overridable: true}},
+			ToggleOverrides: config.ToggleOverridesConfig{Source: "query"},
+		}
+		configStore := config.NewStore(cfg)
+		toggles := featuretoggles.NewClient(featuretoggles.NewConfigProvider(configStore, nil), nil)
+		controller = api.NewController(configStore, userServiceMock, validator, nil, nil, toggles, metrics.NewMetrics())
 		router = api.NewRouter(controller)
 	})
 
@@ -58,6 +67,23 @@ var _ = Describe("UserController", func() {
 			Expect(errorResponse.ErrorID).To(Equal(custom_errors.UserNotFound.Error()))
 		})
 
+		It("returns the request id of the caller in the error response", func() {
+
+			userServiceMock.EXPECT().GetUser(gomock.Any(), "invalid-user").Return(nil, custom_errors.UserNotFound)
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/users/invalid-user", nil)
+			req.Header.Set("X-Request-ID", "request-1")
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Header().Get("X-Request-ID")).To(Equal("request-1"))
+			var errorResponse custom_errors.ErrorResponse
+			err := json.Unmarshal(rr.Body.Bytes(), &errorResponse)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(errorResponse.RequestID).To(Equal("request-1"))
+		})
+
 		It("returns user when calling /users/{userName}", func() {
 
 			userServiceMock.EXPECT().GetUser(gomock.Any(), "user-id-1").Return(&common.User{
@@ -82,6 +108,118 @@ var _ = Describe("UserController", func() {
 		})
 	})
 
+	Context("GetUserByID", func() {
+
+		It("returns user when calling /users/by-id/{id}", func() {
+
+			id := "2c0e5d8c-5b1b-4a47-9c7e-4f4f1c6b2e0a"
+			userServiceMock.EXPECT().GetUserByID(gomock.Any(), id).Return(&common.User{
+				ID:       id,
+				UserName: "user-1",
+			}, nil)
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/users/by-id/"+id, nil)
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			var user common.User
+			err := json.Unmarshal(rr.Body.Bytes(), &user)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(user.ID).To(Equal(id))
+			Expect(user.UserName).To(Equal("user-1"))
+		})
+
+		It("returns bad request when the id is not a uuid", func() {
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/users/by-id/not-a-uuid", nil)
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("returns not found when user does not exist", func() {
+
+			id := "2c0e5d8c-5b1b-4a47-9c7e-4f4f1c6b2e0a"
+			userServiceMock.EXPECT().GetUserByID(gomock.Any(), id).Return(nil, fmt.Errorf("error happened: %w", custom_errors.UserNotFound))
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/users/by-id/"+id, nil)
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("ListUsers", func() {
+
+		It("returns a page of users with the next cursor", func() {
+
+			hasPhoneNumber := true
+			userServiceMock.EXPECT().ListUsers(gomock.Any(), common.UserFilter{
+				Limit:          2,
+				Cursor:         "abc",
+				UserNamePrefix: "jen",
+				EmailDomain:    "test.com",
+				HasPhoneNumber: &hasPhoneNumber,
+			}).Return(&common.UserPage{
+				Users: []common.User{
+					{UserName: "jen1", Email: "jen1@test.com", PhoneNumber: "1"},
+					{UserName: "jen2", Email: "jen2@test.com", PhoneNumber: "2"},
+				},
+				NextCursor: "def",
+			}, nil)
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/users?limit=2&cursor=abc&username_prefix=jen&email_domain=test.com&has_phone_number=true", nil)
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			var page common.UserPage
+			err := json.Unmarshal(rr.Body.Bytes(), &page)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(page.Users).To(HaveLen(2))
+			Expect(page.NextCursor).To(Equal("def"))
+		})
+
+		It("uses the default page size if no limit is given", func() {
+
+			userServiceMock.EXPECT().ListUsers(gomock.Any(), common.UserFilter{Limit: 20}).Return(&common.UserPage{}, nil)
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/users", nil)
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+		})
+
+		It("returns bad request when the limit is out of bounds", func() {
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/users?limit=101", nil)
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("returns bad request when has_phone_number is not a boolean", func() {
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/users?has_phone_number=maybe", nil)
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
 	Context("CreateUser", func() {
 
 		Context("without new feature", func() {
@@ -90,7 +228,7 @@ var _ = Describe("UserController", func() {
 
 				username := "test"
 				email := "test@test.com"
-				phone := "1234"
+				phone := "030 123456"
 
 				errorMessage := fmt.Errorf("error happened: %w", custom_errors.DatabaseError)
 
@@ -116,11 +254,65 @@ var _ = Describe("UserController", func() {
 				Expect(errorResponse.ErrorID).To(Equal(custom_errors.DatabaseError.Error()))
 			})
 
+			It("returns bad request listing every invalid field", func() {
+
+				body, err := json.Marshal(&api.UserCreationRequest{
+					UserName: "invalid$",
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				rr := httptest.NewRecorder()
+				req, _ := http.NewRequest(http.MethodPost, "/users", bytes.NewReader(body))
+
+				router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusBadRequest))
+				var errorResponse custom_errors.ErrorResponse
+				err = json.Unmarshal(rr.Body.Bytes(), &errorResponse)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(errorResponse.ErrorID).To(Equal(custom_errors.InvalidInput.Error()))
+				fields := make([]string, len(errorResponse.Errors))
+				for i, fieldError := range errorResponse.Errors {
+					fields[i] = fieldError.Field
+				}
+				Expect(fields).To(ConsistOf("name", "email", "phone_number"))
+			})
+
+			It("returns conflict with the colliding field when the username is taken", func() {
+
+				username := "test"
+				email := "test@test.com"
+				phone := "030 123456"
+
+				errorMessage := fmt.Errorf("error happened: %w", custom_errors.NewConflictError("username"))
+
+				userServiceMock.EXPECT().CreateUser(gomock.Any(), username, email, phone).Return(nil, errorMessage)
+
+				body, err := json.Marshal(&api.UserCreationRequest{
+					UserName:    username,
+					Email:       email,
+					PhoneNumber: phone,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				rr := httptest.NewRecorder()
+				req, _ := http.NewRequest(http.MethodPost, "/users", bytes.NewReader(body))
+
+				router.ServeHTTP(rr, req)
+
+				Expect(rr.Code).To(Equal(http.StatusConflict))
+				var errorResponse custom_errors.ErrorResponse
+				err = json.Unmarshal(rr.Body.Bytes(), &errorResponse)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(errorResponse.ErrorID).To(Equal(custom_errors.Conflict.Error()))
+				Expect(errorResponse.Field).To(Equal("username"))
+			})
+
 			It("returns user when calling POST /users", func() {
 
 				username := "test"
 				email := "test@test.com"
-				phone := "1234"
+				phone := "030 123456"
 
 				userServiceMock.EXPECT().CreateUser(gomock.Any(), username, email, phone).Return(&common.User{
 					UserName:    username,
@@ -157,7 +349,7 @@ var _ = Describe("UserController", func() {
 
 				username := "test"
 				email := "test@test.com"
-				phone := "1234"
+				phone := "030 123456"
 
 				errorMessage := fmt.Errorf("error happened: %w", custom_errors.DatabaseError)
 
@@ -187,7 +379,7 @@ var _ = Describe("UserController", func() {
 
 				username := "test"
 				email := "test@test.com"
-				phone := "1234"
+				phone := "030 123456"
 
 				userServiceMock.EXPECT().CreateUserWithNewFeature(gomock.Any(), username, email, phone).Return(&common.User{
 					UserName:    username,
@@ -218,4 +410,255 @@ var _ = Describe("UserController", func() {
 			})
 		})
 	})
+
+	Context("feature toggle overrides", func() {
+
+		It("rejects invalid override values", func() {
+			body, err := json.Marshal(&api.UserCreationRequest{
+				UserName:    "test",
+				Email:       "test@test.com",
+				PhoneNumber: "030 123456",
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/users?enableNewFeature=yes", bytes.NewReader(body))
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+			var errorResponse custom_errors.ErrorResponse
+			err = json.Unmarshal(rr.Body.Bytes(), &errorResponse)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(errorResponse.ErrorID).To(Equal(custom_errors.InvalidToggleOverride.Error()))
+		})
+
+		It("evaluates the toggles with the injected client", func() {
+			provider := featuretoggles.NewInMemoryProvider(map[string]featuretoggles.InMemoryFlag{"enableNewFeature": {Value: true}})
+			validator, _ := validation.NewValidate("DE")
+			controller := api.NewController(config.NewStore(config.Config{}), userServiceMock, validator, nil, nil, featuretoggles.NewClient(provider, nil), metrics.NewMetrics())
+			userServiceMock.EXPECT().CreateUserWithNewFeature(gomock.Any(), "test", "test@test.com", "030 123456").Return(&common.User{UserName: "test"}, nil)
+
+			body, err := json.Marshal(&api.UserCreationRequest{
+				UserName:    "test",
+				Email:       "test@test.com",
+				PhoneNumber: "030 123456",
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/users", bytes.NewReader(body))
+
+			api.NewRouter(controller).ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Context("UpdateUser", func() {
+
+		It("returns not found when user does not exist", func() {
+
+			userNotFoundError := fmt.Errorf("error happened: %w", custom_errors.UserNotFound)
+
+			userServiceMock.EXPECT().UpdateUser(gomock.Any(), "invalid-user", "test@test.com", "030 123456").Return(nil, userNotFoundError)
+
+			body, err := json.Marshal(&api.UserUpdateRequest{
+				Email:       "test@test.com",
+				PhoneNumber: "030 123456",
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, "/users/invalid-user", bytes.NewReader(body))
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+			var errorResponse custom_errors.ErrorResponse
+			err = json.Unmarshal(rr.Body.Bytes(), &errorResponse)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(errorResponse.ErrorID).To(Equal(custom_errors.UserNotFound.Error()))
+		})
+
+		It("returns bad request when a field is missing", func() {
+
+			body, err := json.Marshal(&api.UserUpdateRequest{
+				Email: "test@test.com",
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, "/users/test", bytes.NewReader(body))
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("returns updated user when calling PUT /users/{userName}", func() {
+
+			userServiceMock.EXPECT().UpdateUser(gomock.Any(), "test", "new@test.com", "030 654321").Return(&common.User{
+				UserName:    "test",
+				PhoneNumber: "030 654321",
+				Email:       "new@test.com",
+			}, nil)
+
+			body, err := json.Marshal(&api.UserUpdateRequest{
+				Email:       "new@test.com",
+				PhoneNumber: "030 654321",
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, "/users/test", bytes.NewReader(body))
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			var user common.User
+			err = json.Unmarshal(rr.Body.Bytes(), &user)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(user.Email).To(Equal("new@test.com"))
+			Expect(user.PhoneNumber).To(Equal("030 654321"))
+		})
+	})
+
+	Context("PatchUser", func() {
+
+		existingUser := &common.User{
+			UserName:    "test",
+			PhoneNumber: "030 123456",
+			Email:       "test@test.com",
+		}
+
+		It("only replaces the fields contained in the merge patch", func() {
+
+			userServiceMock.EXPECT().GetUser(gomock.Any(), "test").Return(existingUser, nil)
+			userServiceMock.EXPECT().UpdateUser(gomock.Any(), "test", "new@test.com", "030 123456").Return(&common.User{
+				UserName:    "test",
+				PhoneNumber: "030 123456",
+				Email:       "new@test.com",
+			}, nil)
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPatch, "/users/test", bytes.NewReader([]byte(`{"email": "new@test.com"}`)))
+			req.Header.Set("Content-Type", api.MIME_MERGE_PATCH_JSON)
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusOK))
+			var user common.User
+			err := json.Unmarshal(rr.Body.Bytes(), &user)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(user.Email).To(Equal("new@test.com"))
+			Expect(user.PhoneNumber).To(Equal("030 123456"))
+		})
+
+		It("returns bad request when the patch removes a required field", func() {
+
+			userServiceMock.EXPECT().GetUser(gomock.Any(), "test").Return(existingUser, nil)
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPatch, "/users/test", bytes.NewReader([]byte(`{"phone_number": null}`)))
+			req.Header.Set("Content-Type", api.MIME_MERGE_PATCH_JSON)
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("returns bad request when the patch changes the username", func() {
+
+			userServiceMock.EXPECT().GetUser(gomock.Any(), "test").Return(existingUser, nil)
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPatch, "/users/test", bytes.NewReader([]byte(`{"user_name": "other"}`)))
+			req.Header.Set("Content-Type", api.MIME_MERGE_PATCH_JSON)
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("returns not found when user does not exist", func() {
+
+			userNotFoundError := fmt.Errorf("error happened: %w", custom_errors.UserNotFound)
+			userServiceMock.EXPECT().GetUser(gomock.Any(), "invalid-user").Return(nil, userNotFoundError)
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPatch, "/users/invalid-user", bytes.NewReader([]byte(`{"email": "new@test.com"}`)))
+			req.Header.Set("Content-Type", api.MIME_MERGE_PATCH_JSON)
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("DeleteUser", func() {
+
+		It("returns not found when user does not exist", func() {
+
+			userNotFoundError := fmt.Errorf("error happened: %w", custom_errors.UserNotFound)
+			userServiceMock.EXPECT().DeleteUser(gomock.Any(), "invalid-user").Return(userNotFoundError)
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodDelete, "/users/invalid-user", nil)
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("returns no content when calling DELETE /users/{userName}", func() {
+
+			userServiceMock.EXPECT().DeleteUser(gomock.Any(), "test").Return(nil)
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodDelete, "/users/test", nil)
+
+			router.ServeHTTP(rr, req)
+
+			Expect(rr.Code).To(Equal(http.StatusNoContent))
+		})
+	})
+
+	Context("request body size limit", func() {
+
+		var limitedRouter http.Handler
+
+		BeforeEach(func() {
+			validator, _ := validation.NewValidate("DE")
+			cfg := config.Config{Server: config.ServerConfig{MaxBodyBytes: 16}}
+			limitedRouter = api.NewRouter(api.NewController(config.NewStore(cfg), userServiceMock, validator, nil, nil, nil, metrics.NewMetrics()))
+		})
+
+		expectRequestTooLarge := func(rr *httptest.ResponseRecorder) {
+			Expect(rr.Code).To(Equal(http.StatusRequestEntityTooLarge))
+			var errorResponse custom_errors.ErrorResponse
+			err := json.Unmarshal(rr.Body.Bytes(), &errorResponse)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(errorResponse.ErrorID).To(Equal(custom_errors.RequestTooLarge.Error()))
+		}
+
+		It("rejects a request announcing a larger body", func() {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, "/users/test", bytes.NewReader([]byte(`{"email": "test@test.com"}`)))
+
+			limitedRouter.ServeHTTP(rr, req)
+
+			expectRequestTooLarge(rr)
+		})
+
+		It("rejects a larger body sent without content length", func() {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, "/users/test", struct{ io.Reader }{bytes.NewReader([]byte(`{"email": "test@test.com"}`))})
+			req.ContentLength = -1
+
+			limitedRouter.ServeHTTP(rr, req)
+
+			expectRequestTooLarge(rr)
+		})
+	})
 })
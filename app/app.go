@@ -2,27 +2,48 @@ package app
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"github.com/go-playground/log"
 	"github.com/jenpaff/golang-microservices/api"
 	"github.com/jenpaff/golang-microservices/config"
+	"github.com/jenpaff/golang-microservices/featuretoggles"
+	"github.com/jenpaff/golang-microservices/idempotency"
+	"github.com/jenpaff/golang-microservices/logging"
+	"github.com/jenpaff/golang-microservices/metrics"
 	"github.com/jenpaff/golang-microservices/persistence"
+	"github.com/jenpaff/golang-microservices/tracing"
 	"github.com/jenpaff/golang-microservices/users"
 	"github.com/jenpaff/golang-microservices/validation"
-	"golang.org/x/sync/errgroup"
+	"net"
 	"net/http"
 	"os"
 	"time"
 )
 
 type App struct {
-	server     *http.Server
-	port       string
-	controller *api.Controller
+	server             *http.Server
+	adminServer        *http.Server
+	listener           net.Listener
+	db                 *sql.DB
+	controller         *api.Controller
+	shutdownTracing    tracing.Shutdown
+	sources            config.Sources
+	configStore        *config.Store
+	toggleStore        *featuretoggles.Store
+	toggleProvider     featuretoggles.Provider
+	idempotencyStorage idempotency.Storage
+	stopWatching       context.CancelFunc
+	stopPolling        context.CancelFunc
+	stopPurging        context.CancelFunc
 }
 
-func NewApp(port, configPath, secretsPath, secretsEnv string) (*App, error) {
-	cfg, err := config.BuildConfig(configPath, secretsPath, secretsEnv)
+func NewApp(sources config.Sources) (*App, error) {
+	cfg, _, err := config.Load(sources)
+	if err != nil {
+		return nil, err
+	}
+	err = logging.Configure(cfg.Logging)
 	if err != nil {
 		return nil, err
 	}
@@ -32,77 +53,252 @@ func NewApp(port, configPath, secretsPath, secretsEnv string) (*App, error) {
 		return nil, err
 	}
 
-	userPersistence := users.NewStorage(db)
-	userService := users.NewService(userPersistence)
+	// releases what was set up so far if the app cannot be built
+	cleanup := func() { closeDatabase(db) }
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Name, cfg.Tracing)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	cleanup = func() {
+		closeDatabase(db)
+		flushTraces(shutdownTracing)
+	}
+	tracedDB := tracing.WrapDB(db, cfg.Persistence.DbName)
+
+	userPersistence := users.NewStorage(tracedDB)
+	userService := users.NewService(userPersistence, cfg.Validation.DefaultPhoneRegion)
+
+	validator, err := validation.NewValidate(cfg.Validation.DefaultPhoneRegion)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	idempotencyStorage := idempotency.NewStorage(tracedDB)
+
+	var toggleStore *featuretoggles.Store
+	if cfg.ToggleStore.Enabled {
+		toggleStore = featuretoggles.NewStore(featuretoggles.NewStorage(tracedDB))
+	}
+
+	serviceMetrics := metrics.NewMetrics()
+	err = serviceMetrics.RegisterDB(db, cfg.Persistence.DbName)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	configStore := config.NewStore(cfg)
+	configStore.OnChange(func(cfg config.Config) {
+		err := logging.Configure(cfg.Logging)
+		if err != nil {
+			log.WithError(err).Error("could not apply reloaded logging config")
+		}
+	})
 
-	validator, err := validation.NewValidate()
+	toggleProvider, err := featuretoggles.NewProvider(cfg.ToggleProvider, configStore, toggleStore)
 	if err != nil {
+		cleanup()
 		return nil, err
 	}
+	toggles := featuretoggles.NewClient(toggleProvider, featuretoggles.LogExposureSink{})
 
-	controller := api.NewController(cfg, userService, validator)
+	controller := api.NewController(configStore, userService, validator, idempotencyStorage, toggleStore, toggles, serviceMetrics)
 	router := api.NewRouter(controller)
-	server := &http.Server{Addr: ":" + port, Handler: router}
-	return &App{server: server, port: port, controller: controller}, nil
+	server := &http.Server{
+		Addr:              cfg.Server.Address,
+		Handler:           router,
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout),
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+
+	var adminServer *http.Server
+	if cfg.Metrics.AdminPort != "" {
+		adminRouter := http.NewServeMux()
+		adminRouter.Handle("/metrics", serviceMetrics.Handler())
+		adminServer = &http.Server{
+			Addr:              ":" + cfg.Metrics.AdminPort,
+			Handler:           adminRouter,
+			ReadTimeout:       time.Duration(cfg.Server.ReadTimeout),
+			ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+			WriteTimeout:      time.Duration(cfg.Server.WriteTimeout),
+			IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+			MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		}
+	}
+
+	return &App{
+		server:             server,
+		adminServer:        adminServer,
+		db:                 db,
+		controller:         controller,
+		shutdownTracing:    shutdownTracing,
+		sources:            sources,
+		configStore:        configStore,
+		toggleStore:        toggleStore,
+		toggleProvider:     toggleProvider,
+		idempotencyStorage: idempotencyStorage,
+		stopWatching:       func() {},
+		stopPolling:        func() {},
+		stopPurging:        func() {},
+	}, nil
 }
 
-func (a App) Start() error {
+func (a *App) Start() error {
 	ctx := context.Background()
 
 	log.Info("Starting...")
 
-	err := ensureDatabaseConnectivity(ctx, a.controller.Cfg.Persistence)
+	cfg := a.configStore.Get()
+
+	err := ensureDatabaseConnectivity(ctx, a.db, cfg.Persistence)
 	if err != nil {
 		return err
 	}
 
+	if a.toggleStore != nil {
+		// the toggles of the config files apply until the stored toggles could be loaded
+		err = a.toggleStore.Refresh(ctx)
+		if err != nil {
+			log.WithError(err).Error("could not load the stored feature toggles")
+		}
+		if cfg.ToggleStore.PollInterval > 0 {
+			var pollCtx context.Context
+			pollCtx, a.stopPolling = context.WithCancel(context.Background())
+			go a.toggleStore.Poll(pollCtx, time.Duration(cfg.ToggleStore.PollInterval))
+		}
+	}
+
+	if cfg.Idempotency.PurgeInterval > 0 {
+		var purgeCtx context.Context
+		purgeCtx, a.stopPurging = context.WithCancel(context.Background())
+		go idempotency.PurgeExpired(purgeCtx, a.idempotencyStorage, time.Duration(cfg.Idempotency.KeyTTL), time.Duration(cfg.Idempotency.PurgeInterval))
+	}
+
+	warnAboutStaleToggles(featuretoggles.EffectiveToggles(cfg.FeatureToggles, a.toggleStore))
+
+	// listening before serving in the background lets callers use Addr as soon as Start returns
+	a.listener, err = net.Listen("tcp", a.server.Addr)
+	if err != nil {
+		return fmt.Errorf("could not listen on %s: %s", a.server.Addr, err.Error())
+	}
+
 	// everything below enables graceful shutdown of our service without dropping any requests
 
 	go func() {
-		log.Infof("Listening on port %s", a.port)
-		err = a.server.ListenAndServe()
+		log.Infof("Listening on %s", a.Addr())
+		err := a.server.Serve(a.listener)
 		if err != nil && err != http.ErrServerClosed {
-			log.WithError(err).Errorf("Could not listen on port %s", a.port)
+			log.WithError(err).Errorf("Could not serve on %s", a.Addr())
 			os.Exit(1)
 		}
 	}()
 
+	if a.adminServer != nil {
+		go func() {
+			log.Infof("Serving metrics on admin port %s", cfg.Metrics.AdminPort)
+			err := a.adminServer.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				log.WithError(err).Errorf("Could not listen on admin port %s", cfg.Metrics.AdminPort)
+			}
+		}()
+	}
+
+	if cfg.Reload.Interval > 0 {
+		var watchCtx context.Context
+		watchCtx, a.stopWatching = context.WithCancel(context.Background())
+		go a.configStore.Watch(watchCtx, a.sources, time.Duration(cfg.Reload.Interval))
+	}
+
 	return nil
 }
 
-func (a App) Stop() {
+// Reload reads the config and the flagd flag file again, e.g. on SIGHUP, the current config is kept if the new one is invalid
+func (a *App) Reload() error {
+	log.Info("Reloading config")
+	if fileProvider, ok := a.toggleProvider.(*featuretoggles.FlagdFileProvider); ok {
+		err := fileProvider.Reload()
+		if err != nil {
+			log.WithError(err).Error("could not reload the flagd flags, keeping the current ones")
+		}
+	}
+	return a.configStore.Reload(a.sources)
+}
+
+// Addr returns the address the server listens on, e.g. to learn the port chosen for port 0, it is empty before Start
+func (a *App) Addr() string {
+	if a.listener == nil {
+		return ""
+	}
+	return a.listener.Addr().String()
+}
+
+func (a *App) Stop() {
 	log.Info("Shutting down server")
 
-	if err := a.server.Shutdown(context.Background()); err != nil {
-		log.WithError(fmt.Errorf("couldn't shutdown server cleanly: %s", err.Error()))
+	a.stopWatching()
+	a.stopPolling()
+	a.stopPurging()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(a.configStore.Get().Server.ShutdownGracePeriod))
+	defer cancel()
+
+	if err := a.server.Shutdown(ctx); err != nil {
+		log.WithError(err).Error("couldn't shutdown server cleanly")
+	}
+
+	if a.adminServer != nil {
+		if err := a.adminServer.Shutdown(ctx); err != nil {
+			log.WithError(err).Error("couldn't shutdown admin server cleanly")
+		}
 	}
 
+	// the requests are done, so the pool is no longer needed
+	closeDatabase(a.db)
+
+	// flushes the spans of the requests finished during shutdown
+	flushTraces(a.shutdownTracing)
+
 	log.Info("Shutting down done")
 }
 
-func ensureDatabaseConnectivity(ctx context.Context, cfg config.PersistenceConfig) error {
+func closeDatabase(db *sql.DB) {
+	if err := db.Close(); err != nil {
+		log.WithError(err).Error("couldn't close database connections")
+	}
+}
+
+func flushTraces(shutdownTracing tracing.Shutdown) {
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.WithError(err).Error("couldn't flush traces")
+	}
+}
+
+func ensureDatabaseConnectivity(ctx context.Context, db *sql.DB, cfg config.PersistenceConfig) error {
 	deadline := 20 * time.Second
 	pollingDelay := 500 * time.Millisecond
 
 	ctx, cancel := context.WithTimeout(ctx, deadline)
 	defer cancel()
 
-	g, ctx := errgroup.WithContext(ctx)
-
-	pgOptions := fmt.Sprintf("host=%s port=%d user=%s dbname=%s password=%s", cfg.DbHost, cfg.DbPort, cfg.DbUsername, cfg.DbName, cfg.DbPassword)
-	if !cfg.SslEnabled {
-		pgOptions = pgOptions + " sslmode=disable"
+	log.Infof("Checking for database connectivity on host: %s port: %d with user: %s", cfg.DbHost, cfg.DbPort, cfg.DbUsername)
+	err := persistence.EnsureConnected(ctx, db, pollingDelay)
+	if err != nil {
+		return fmt.Errorf("could not initialise database: %s", err.Error())
 	}
+	log.Infof("Database connection successful")
+	return nil
+}
 
-	g.Go(func() error {
-		log.Infof("Checking for database connectivity on host: %s port: %d with user: %s", cfg.DbHost, cfg.DbPort, cfg.DbUsername)
-		err := persistence.EnsureConnected(ctx, pgOptions, pollingDelay)
-		if err != nil {
-			return fmt.Errorf("could not initialise database: %s", err.Error())
-		}
-		log.Infof("Database connection successful")
-		return nil
-	})
-
-	return g.Wait()
+// warnAboutStaleToggles reminds the owners of expired toggles to remove them, see /admin/toggles/stale
+func warnAboutStaleToggles(toggles config.FeatureToggles) {
+	for _, stale := range featuretoggles.StaleToggles(toggles, time.Now().UTC()) {
+		log.WithFields(log.F("toggle", stale.Name), log.F("owner", stale.Owner)).
+			Warnf("toggle '%v' expired on %v and should be removed", stale.Name, stale.Expires)
+	}
 }
@@ -4,16 +4,21 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/go-playground/log"
-	"github.com/go-playground/log/handlers/console"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jenpaff/golang-microservices/config"
+	"github.com/jenpaff/golang-microservices/logging"
 	"os"
 	"strconv"
 )
 
+const (
+	logFormatEnv = "LOG_FORMAT"
+	logLevelEnv  = "LOG_LEVEL"
+)
+
 func main() {
 	initLogging()
 
@@ -70,7 +75,18 @@ func main() {
 	log.Info("PostgreSQL storage: migrations finished")
 }
 
+// initLogging uses the default logging config, format and level can be changed with LOG_FORMAT and LOG_LEVEL
 func initLogging() {
-	cLog := console.New(true)
-	log.AddHandler(cLog, log.AllLevels...)
+	loggingConfig := config.DefaultConfig().Logging
+	if format := os.Getenv(logFormatEnv); format != "" {
+		loggingConfig.Format = format
+	}
+	if level := os.Getenv(logLevelEnv); level != "" {
+		loggingConfig.Level = level
+	}
+
+	err := logging.Configure(loggingConfig)
+	if err != nil {
+		panic(err)
+	}
 }
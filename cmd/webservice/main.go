@@ -1,30 +1,61 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"github.com/go-playground/errors"
 	"github.com/go-playground/log"
-	"github.com/go-playground/log/handlers/console"
 	"github.com/jenpaff/golang-microservices/app"
+	"github.com/jenpaff/golang-microservices/config"
+	"github.com/jenpaff/golang-microservices/featuretoggles"
+	"github.com/jenpaff/golang-microservices/logging"
+	"io/ioutil"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 )
 
 const (
-	injectedSecretsEnv = "ENV_SECRETS"
+	injectedSecretsEnv    = "ENV_SECRETS"
+	checkConfigCommand    = "check-config"
+	encryptSecretsCommand = "encrypt-secrets"
+	toggleUsageCommand    = "toggle-usage"
+	secretsKeyEnv         = "SECRETS_KEY"
 )
 
 func main() {
 	initLogging()
-	log.Info("Starting app...")
 
-	configPath, secretsPath, secretsEnv, err := getStartArgs()
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == checkConfigCommand {
+		os.Exit(checkConfig(args[1:]))
+	}
+	if len(args) > 0 && args[0] == encryptSecretsCommand {
+		os.Exit(encryptSecrets(args[1:]))
+	}
+	if len(args) > 0 && args[0] == toggleUsageCommand {
+		os.Exit(toggleUsage(args[1:]))
+	}
+
+	sources, printConfig, err := getStartArgs(args)
 	if err != nil {
 		logErrorAndExit(fmt.Errorf("could not fetch necessary paths: %s", err))
 	}
 
-	app, err := app.NewApp("12345", configPath, secretsPath, secretsEnv)
+	if printConfig {
+		err = printEffectiveConfig(sources)
+		if err != nil {
+			logErrorAndExit(err)
+		}
+		return
+	}
+
+	log.Info("Starting app...")
+
+	app, err := app.NewApp(sources)
 	if err != nil {
 		logErrorAndExit(fmt.Errorf("could not initialise app: %s", err.Error()))
 	}
@@ -34,39 +65,197 @@ func main() {
 		logErrorAndExit(err)
 	}
 
+	// SIGHUP reloads the config, e.g. after editing it without waiting for the next poll
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
 	// essential to make the main wait for a stop signal
 	// otherwise we exit the main function and also the go-routine in app.Start() will be stopped
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
-	<-stop
 
-	app.Stop()
+	for {
+		select {
+		case <-reload:
+			err = app.Reload()
+			if err != nil {
+				log.WithError(err).Error("could not reload config, keeping the current config")
+			}
+		case <-stop:
+			app.Stop()
+			return
+		}
+	}
 }
 
+// initLogging logs with the default settings until the app applies the logging section of the config
 func initLogging() {
-	cLog := console.New(true)
-	log.AddHandler(cLog, log.AllLevels...)
+	err := logging.Configure(config.DefaultConfig().Logging)
+	if err != nil {
+		panic(err)
+	}
 }
 
 func logErrorAndExit(err error) {
-	cLog := console.New(true)
-
-	log.AddHandler(cLog, log.AllLevels...)
 	log.Fatalf(err.Error())
 	os.Exit(1)
 }
 
-func getStartArgs() (string, string, string, error) {
-	if len(os.Args) < 2 {
-		return "", "", "", errors.New("you must supply a config file path")
+func printEffectiveConfig(sources config.Sources) error {
+	cfg, origins, err := config.Load(sources)
+	if err != nil {
+		return fmt.Errorf("could not build config: %s", err.Error())
+	}
+	return config.Print(os.Stdout, cfg, origins)
+}
+
+// checkConfig validates the config built from the same arguments the service is started with,
+// every problem is printed and the exit code is 1 if there are any
+func checkConfig(args []string) int {
+	sources, _, err := getStartArgs(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 2
+	}
+	_, _, err = config.Load(sources)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	fmt.Println("config is valid")
+	return 0
+}
+
+// encryptSecrets prints the file for the encrypted secret provider, given a JSON object of secrets,
+// the base64 encoded 256 bit key is read from SECRETS_KEY
+func encryptSecrets(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: webservice %s <secrets.json>\n", encryptSecretsCommand)
+		return 2
+	}
+	key, err := base64.StdEncoding.DecodeString(os.Getenv(secretsKeyEnv))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not decode %s: %s\n", secretsKeyEnv, err.Error())
+		return 1
+	}
+	content, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	secrets := make(map[string]string)
+	err = json.Unmarshal(content, &secrets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "secrets must be a JSON object of strings: %s\n", err.Error())
+		return 1
+	}
+	encrypted, err := config.EncryptSecrets(key, secrets)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	fmt.Println(string(encrypted))
+	return 0
+}
+
+// toggleUsage compares the toggles evaluated in the Go source below the given directory with the toggles
+// of the config built from the start arguments, toggles only defined in the toggle store are not known to it,
+// the exit code is 1 if a toggle is unused or undefined
+func toggleUsage(args []string) int {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "usage: webservice %s <source directory> <start arguments>\n", toggleUsageCommand)
+		return 2
+	}
+	sources, _, err := getStartArgs(args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 2
+	}
+	cfg, _, err := config.Load(sources)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	usages, err := featuretoggles.ScanUsages(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	report := featuretoggles.CheckUsages(cfg.FeatureToggles, usages)
+	for _, name := range report.Unused {
+		fmt.Printf("unused: %s is defined but never evaluated\n", name)
+	}
+	for _, usage := range report.Undefined {
+		fmt.Printf("undefined: %s is evaluated at %s but not defined\n", usage.Toggle, usage.Position)
+	}
+	if report.HasFindings() {
+		return 1
+	}
+	fmt.Printf("all %d toggles are in use\n", len(cfg.FeatureToggles))
+	return 0
+}
+
+// getStartArgs supports the positional form "webservice <config file> <secrets directory>" as well as
+// --config (repeatable) and --secrets, config values can be overridden with flags and GOSVC_ environment variables
+func getStartArgs(args []string) (config.Sources, bool, error) {
+	fs := flag.NewFlagSet("webservice", flag.ContinueOnError)
+	var configFiles stringList
+	fs.Var(&configFiles, "config", "config file, can be repeated, later files override earlier ones")
+	secretsDirectoryPath := fs.String("secrets", "", "directory with one file per secret")
+	printConfig := fs.Bool("print-config", false, "print the effective config with the origin of each value and exit")
+	configFlags := config.RegisterFlags(fs)
+
+	// Parse stops at the first positional argument, the flags following the paths are parsed as well
+	var positional []string
+	remaining := args
+	for {
+		err := fs.Parse(remaining)
+		if err != nil {
+			return config.Sources{}, false, err
+		}
+		remaining = fs.Args()
+		if len(remaining) == 0 {
+			break
+		}
+		positional = append(positional, remaining[0])
+		remaining = remaining[1:]
+	}
+	if len(positional) > 2 {
+		return config.Sources{}, false, fmt.Errorf("unexpected arguments %s, expected at most a config file and a secrets directory", strings.Join(positional[2:], " "))
+	}
+
+	if len(positional) > 0 {
+		configFiles = append(configFiles, positional[0])
+	}
+	if len(positional) > 1 {
+		*secretsDirectoryPath = positional[1]
 	}
 
-	if len(os.Args) < 3 {
-		return "", "", "", errors.New("you must supply a secrets directory path")
+	if len(configFiles) == 0 {
+		return config.Sources{}, false, errors.New("you must supply a config file path")
 	}
 
-	envConfigFilePath := os.Args[1]
-	secretsDirectoryPath := os.Args[2]
-	injectedSecrets := os.Getenv(injectedSecretsEnv)
-	return envConfigFilePath, secretsDirectoryPath, injectedSecrets, nil
+	if *secretsDirectoryPath == "" {
+		return config.Sources{}, false, errors.New("you must supply a secrets directory path")
+	}
+
+	return config.Sources{
+		Files:                configFiles,
+		SecretsDirectoryPath: *secretsDirectoryPath,
+		InjectedSecrets:      os.Getenv(injectedSecretsEnv),
+		Environment:          os.Environ(),
+		Flags:                configFlags(),
+	}, *printConfig, nil
+}
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
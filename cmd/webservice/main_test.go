@@ -0,0 +1,36 @@
+//+build unit
+
+package main
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Start arguments", func() {
+
+	It("will read the flags following the positional paths", func() {
+		sources, printConfig, err := getStartArgs([]string{"config.json", "/secrets", "--print-config", "--persistence.dbHost=flaghost"})
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sources.Files).To(Equal([]string{"config.json"}))
+		Expect(sources.SecretsDirectoryPath).To(Equal("/secrets"))
+		Expect(printConfig).To(BeTrue())
+		Expect(sources.Flags).To(HaveKeyWithValue("persistence.dbHost", "flaghost"))
+	})
+
+	It("will read flags between the positional paths", func() {
+		sources, printConfig, err := getStartArgs([]string{"--config", "base.json", "override.json", "--print-config", "/secrets"})
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sources.Files).To(Equal([]string{"base.json", "override.json"}))
+		Expect(sources.SecretsDirectoryPath).To(Equal("/secrets"))
+		Expect(printConfig).To(BeTrue())
+	})
+
+	It("will reject more than two positional arguments", func() {
+		_, _, err := getStartArgs([]string{"config.json", "/secrets", "extra"})
+
+		Expect(err).To(MatchError(ContainSubstring("unexpected arguments extra")))
+	})
+})
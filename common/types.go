@@ -1,7 +1,21 @@
 package common
 
 type User struct {
+	ID          string `json:"id"`
 	UserName    string `json:"user_name"`
 	PhoneNumber string `json:"phone_number"`
 	Email       string `json:"email"`
 }
+
+type UserPage struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type UserFilter struct {
+	Limit          int
+	Cursor         string
+	UserNamePrefix string
+	EmailDomain    string
+	HasPhoneNumber *bool
+}
@@ -0,0 +1,474 @@
+//+build unit
+
+package config_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	stderrors "errors"
+	"flag"
+	"github.com/jenpaff/golang-microservices/config"
+	custom_errors "github.com/jenpaff/golang-microservices/errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const basePersistence = `{"persistence": {"dbName": "golangservice", "dbHost": "basehost", "dbPort": 5432, "dbUsername": "postgres"}}`
+
+var _ = Describe("Config", func() {
+
+	var dir string
+	var base string
+
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "config")
+		Expect(err).ToNot(HaveOccurred())
+		base = writeFile("base.json", basePersistence)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	invalidConfigProblems := func(err error) []string {
+		var invalidConfigError *config.InvalidConfigError
+		Expect(stderrors.As(err, &invalidConfigError)).To(BeTrue())
+		return invalidConfigError.Problems
+	}
+
+	Context("layers", func() {
+
+		It("will keep defaults not set in the config file", func() {
+			cfg, err := config.BuildConfig(base, "", "")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Persistence.DbHost).To(Equal("basehost"))
+			Expect(cfg.Name).To(Equal("Golang Service"))
+			Expect(time.Duration(cfg.Idempotency.KeyTTL)).To(Equal(24 * time.Hour))
+			Expect(cfg.Persistence.MaxOpenConns).To(Equal(20))
+			Expect(time.Duration(cfg.Persistence.ConnMaxLifetime)).To(Equal(30 * time.Minute))
+		})
+
+		It("will let later files override earlier ones", func() {
+			override := writeFile("override.json", `{"persistence": {"dbHost": "overridehost"}, "featuretoggles": {"enableNewFeature": false}}`)
+			toggles := writeFile("toggles.json", `{"featuretoggles": {"enableNewFeature": true}}`)
+
+			cfg, origins, err := config.Load(config.Sources{Files: []string{base, toggles, override}})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Persistence.DbHost).To(Equal("overridehost"))
+			Expect(cfg.Persistence.DbPort).To(Equal(5432))
+			Expect(cfg.FeatureToggles["enableNewFeature"].Enabled).To(BeFalse())
+			Expect(origins["persistence.dbHost"].Layer).To(Equal("file " + override))
+			Expect(origins["persistence.dbPort"].Layer).To(Equal("file " + base))
+		})
+
+		It("will let environment variables override files", func() {
+			path := writeFile("config.json", `{"featuretoggles": {"enableNewFeature": true}}`)
+
+			cfg, origins, err := config.Load(config.Sources{
+				Files: []string{base, path},
+				Environment: []string{
+					"GOSVC_PERSISTENCE_DBHOST=envhost",
+					"GOSVC_PERSISTENCE_DBPORT=6543",
+					"GOSVC_SERVER_READTIMEOUT=5s",
+					"GOSVC_FEATURETOGGLES_ENABLENEWFEATURE=false",
+					"GOSVC_LOGGING_REDACTEDFIELDS=email,token",
+					"PATH=/usr/bin",
+				},
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Persistence.DbHost).To(Equal("envhost"))
+			Expect(cfg.Persistence.DbPort).To(Equal(6543))
+			Expect(time.Duration(cfg.Server.ReadTimeout)).To(Equal(5 * time.Second))
+			Expect(cfg.FeatureToggles).To(Equal(config.FeatureToggles{"enableNewFeature": {Enabled: false}}))
+			Expect(cfg.Logging.RedactedFields).To(Equal([]string{"email", "token"}))
+			Expect(origins["persistence.dbHost"].Layer).To(Equal("env GOSVC_PERSISTENCE_DBHOST"))
+		})
+
+		It("will let flags override environment variables", func() {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			configFlags := config.RegisterFlags(fs)
+			Expect(fs.Parse([]string{"--persistence.dbHost=flaghost", "--set", "featuretoggles.enableNewFeature=true"})).To(Succeed())
+
+			cfg, origins, err := config.Load(config.Sources{
+				Files:       []string{base},
+				Environment: []string{"GOSVC_PERSISTENCE_DBHOST=envhost"},
+				Flags:       configFlags(),
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Persistence.DbHost).To(Equal("flaghost"))
+			Expect(cfg.FeatureToggles["enableNewFeature"].Enabled).To(BeTrue())
+			Expect(origins["persistence.dbHost"].Layer).To(Equal("flag --persistence.dbHost"))
+		})
+	})
+
+	Context("file formats", func() {
+
+		It("will read yaml and toml files and insert secrets into their placeholders", func() {
+			yamlPath := writeFile("config.yaml", `
+persistence:
+  dbName: golangservice
+  dbHost: yamlhost
+  dbPort: 5433
+  dbPassword: "{{ .db_password }}"
+featuretoggles:
+  enableNewFeature: true
+server:
+  readTimeout: 5s
+`)
+			tomlPath := writeFile("override.toml", `
+[persistence]
+dbHost = "tomlhost"
+dbUsername = "{{ .db_user }}"
+
+[featuretoggles]
+enableNewFeature = false
+`)
+			injected := "db_password:" + base64.StdEncoding.EncodeToString([]byte("secret")) + ";db_user:" + base64.StdEncoding.EncodeToString([]byte("postgres"))
+
+			cfg, origins, err := config.Load(config.Sources{Files: []string{yamlPath, tomlPath}, InjectedSecrets: injected})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Persistence.DbHost).To(Equal("tomlhost"))
+			Expect(cfg.Persistence.DbPort).To(Equal(5433))
+			Expect(cfg.Persistence.DbPassword).To(Equal("secret"))
+			Expect(cfg.Persistence.DbUsername).To(Equal("postgres"))
+			Expect(cfg.FeatureToggles["enableNewFeature"].Enabled).To(BeFalse())
+			Expect(time.Duration(cfg.Server.ReadTimeout)).To(Equal(5 * time.Second))
+			Expect(origins["persistence.dbPort"].Layer).To(Equal("file " + yamlPath))
+		})
+
+		It("will return an error for an invalid yaml file", func() {
+			path := writeFile("config.yml", "persistence: [")
+
+			_, err := config.BuildConfig(path, "", "")
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("secrets", func() {
+
+		It("will insert secrets from the secrets directory and the environment", func() {
+			path := writeFile("config.json", `{"persistence": {"dbUsername": "{{ .db_user }}", "dbPassword": "{{ .db_password }}"}}`)
+			secretsDir := filepath.Join(dir, "secrets")
+			Expect(os.Mkdir(secretsDir, 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(secretsDir, "db_user"), []byte("postgres\n"), 0600)).To(Succeed())
+			injected := "db_password:" + base64.StdEncoding.EncodeToString([]byte("secret"))
+
+			cfg, _, err := config.Load(config.Sources{Files: []string{base, path}, SecretsDirectoryPath: secretsDir, InjectedSecrets: injected})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Persistence.DbUsername).To(Equal("postgres"))
+			Expect(cfg.Persistence.DbPassword).To(Equal("secret"))
+		})
+
+		It("will print the effective config with its origins and without secrets", func() {
+			path := writeFile("config.json", `{"persistence": {"dbHost": "filehost", "dbUsername": "{{ .db_user }}", "dbPassword": "plain"}, "toggleStore": {"adminTokens": {"jane": "janes-token"}}}`)
+			injected := "db_user:" + base64.StdEncoding.EncodeToString([]byte("dbadmin"))
+
+			cfg, origins, err := config.Load(config.Sources{Files: []string{base, path}, InjectedSecrets: injected})
+			Expect(err).ToNot(HaveOccurred())
+
+			var output bytes.Buffer
+			Expect(config.Print(&output, cfg, origins)).To(Succeed())
+
+			Expect(output.String()).To(ContainSubstring(`persistence.dbHost = "filehost" (file ` + path + `)`))
+			Expect(output.String()).To(ContainSubstring(`persistence.dbUsername = [REDACTED] (file ` + path + `)`))
+			Expect(output.String()).To(ContainSubstring(`persistence.dbPassword = [REDACTED] (file ` + path + `)`))
+			Expect(output.String()).To(ContainSubstring(`name = "Golang Service" (default)`))
+			Expect(output.String()).ToNot(ContainSubstring("dbadmin"))
+			Expect(output.String()).ToNot(ContainSubstring("plain"))
+			Expect(output.String()).To(ContainSubstring(`toggleStore.adminTokens.jane = [REDACTED] (file ` + path + `)`))
+			Expect(output.String()).ToNot(ContainSubstring("janes-token"))
+		})
+	})
+
+	Context("validation", func() {
+
+		It("will report every problem at once", func() {
+			path := writeFile("config.json", `{
+				"featureToggles": {"enableNewFeature": true},
+				"persistence": {"dbHost": "", "dbPort": 70000, "dbPassword": "{{ .db_password }}", "dbPasword": "typo"},
+				"logging": {"format": "xml"},
+				"tracing": {"exporter": "file"}
+			}`)
+
+			_, _, err := config.Load(config.Sources{
+				Files:       []string{base, path},
+				Environment: []string{"GOSVC_PERSISTENCE_DBHOTS=abc", "GOSVC_SERVER_MAXBODYBYTES=many"},
+				Flags:       map[string]string{"persistence.unknown": "value"},
+			})
+
+			Expect(err).To(HaveOccurred())
+			Expect(invalidConfigProblems(err)).To(ConsistOf(
+				"file "+path+": unknown key featureToggles, did you mean featuretoggles?",
+				"file "+path+": unknown key persistence.dbPasword",
+				"environment variable GOSVC_PERSISTENCE_DBHOTS does not match any config value",
+				`invalid value for environment variable GOSVC_SERVER_MAXBODYBYTES: "many" is not a valid int64`,
+				"invalid flag --persistence.unknown: unknown config value persistence.unknown",
+				"persistence.dbPassword: secret db_password is not defined",
+				"persistence.dbHost: is required",
+				"persistence.dbPort: must be at most 65535",
+				"tracing.filePath: is required if Exporter file",
+				"logging.format: must be one of console, json, logfmt but is xml",
+			))
+		})
+
+		It("will accept the config files of the repository", func() {
+			for _, path := range []string{"test.json", "local.json"} {
+				_, err := config.BuildConfig(path, "", "")
+				Expect(err).ToNot(HaveOccurred(), path)
+			}
+		})
+	})
+	Context("feature toggles", func() {
+
+		It("will read toggles with rules next to plain toggles", func() {
+			path := writeFile("config.json", `{"featuretoggles": {
+				"plain": true,
+				"overridden": false,
+				"rollout": {"enabled": false, "percentage": 5, "allowUsers": ["tester"], "attributes": {"X-Beta": ["true"]}}
+			}}`)
+
+			cfg, origins, err := config.Load(config.Sources{
+				Files:       []string{base, path},
+				Environment: []string{`GOSVC_FEATURETOGGLES_OVERRIDDEN={"enabled": true, "denyUsers": ["blocked"]}`},
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.FeatureToggles["plain"]).To(Equal(config.FeatureToggle{Enabled: true}))
+			Expect(*cfg.FeatureToggles["rollout"].Percentage).To(Equal(5.0))
+			Expect(cfg.FeatureToggles["rollout"].AllowUsers).To(Equal([]string{"tester"}))
+			Expect(cfg.FeatureToggles["rollout"].Attributes).To(Equal(map[string][]string{"X-Beta": {"true"}}))
+			Expect(cfg.FeatureToggles["overridden"]).To(Equal(config.FeatureToggle{Enabled: true, DenyUsers: []string{"blocked"}}))
+
+			var output bytes.Buffer
+			Expect(config.Print(&output, cfg, origins)).To(Succeed())
+			Expect(output.String()).To(ContainSubstring("featuretoggles.plain = true (file " + path + ")"))
+		})
+
+		It("will reject unknown rules and invalid percentages", func() {
+			path := writeFile("config.json", `{"featuretoggles": {
+				"misspelt": {"enabled": true, "allowUser": ["tester"]},
+				"tooMuch": {"percentage": 120}
+			}}`)
+
+			_, _, err := config.Load(config.Sources{Files: []string{base, path}})
+
+			Expect(err).To(MatchError(ContainSubstring(`unknown field "allowUser"`)))
+
+			path = writeFile("config.json", `{"featuretoggles": {"tooMuch": {"percentage": 120}}}`)
+
+			_, _, err = config.Load(config.Sources{Files: []string{base, path}})
+
+			Expect(invalidConfigProblems(err)).To(ConsistOf("featuretoggles[tooMuch].percentage: must be at most 100"))
+		})
+
+		It("will read toggles with variants", func() {
+			path := writeFile("config.json", `{"featuretoggles": {
+				"checkoutButton": {"enabled": true, "defaultVariant": "blue", "variants": [
+					{"name": "blue", "value": "#00f", "weight": 50},
+					{"name": "limits", "value": {"limit": 10}, "weight": 50}
+				]}
+			}}`)
+
+			cfg, _, err := config.Load(config.Sources{Files: []string{base, path}})
+
+			Expect(err).ToNot(HaveOccurred())
+			toggle := cfg.FeatureToggles["checkoutButton"]
+			Expect(toggle.DefaultVariant).To(Equal("blue"))
+			Expect(toggle.Variants).To(HaveLen(2))
+			Expect(string(toggle.Variants[0].Value)).To(Equal(`"#00f"`))
+			Expect(string(toggle.Variants[1].Value)).To(MatchJSON(`{"limit": 10}`))
+		})
+
+		It("will reject invalid variants", func() {
+			path := writeFile("config.json", `{"featuretoggles": {
+				"twice": {"variants": [{"name": "a", "value": 1}, {"name": "a", "value": 2}]},
+				"unknownDefault": {"defaultVariant": "c", "variants": [{"name": "a", "value": 1}]},
+				"incomplete": {"variants": [{"name": "a", "weight": -1}]}
+			}}`)
+
+			_, _, err := config.Load(config.Sources{Files: []string{base, path}})
+
+			Expect(invalidConfigProblems(err)).To(ConsistOf(
+				"featuretoggles[twice].variants: must not repeat a name",
+				"featuretoggles[unknownDefault].defaultVariant: must name one of the variants but is c",
+				"featuretoggles[incomplete].variants[0].value: is required",
+				"featuretoggles[incomplete].variants[0].weight: must be at least 0",
+			))
+		})
+
+		It("will read the lifecycle of toggles", func() {
+			path := writeFile("config.json", `{"featuretoggles": {
+				"newCheckout": {"enabled": true, "owner": "checkout-team", "description": "new checkout flow",
+					"created": "2021-03-01", "expires": "2021-06-01"},
+				"killSwitch": {"enabled": true, "permanent": true}
+			}}`)
+
+			cfg, _, err := config.Load(config.Sources{Files: []string{base, path}})
+
+			Expect(err).ToNot(HaveOccurred())
+			toggle := cfg.FeatureToggles["newCheckout"]
+			Expect(toggle.Owner).To(Equal("checkout-team"))
+			Expect(toggle.Description).To(Equal("new checkout flow"))
+			Expect(toggle.Created.String()).To(Equal("2021-03-01"))
+			Expect(toggle.Expires.Time()).To(Equal(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)))
+			Expect(toggle.IsExpired(time.Date(2021, 5, 31, 23, 59, 0, 0, time.UTC))).To(BeFalse())
+			Expect(toggle.IsExpired(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))).To(BeTrue())
+			Expect(cfg.FeatureToggles["killSwitch"].IsExpired(time.Now())).To(BeFalse())
+
+			marshalled, err := json.Marshal(toggle)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(marshalled).To(MatchJSON(`{"enabled": true, "owner": "checkout-team", "description": "new checkout flow",
+				"created": "2021-03-01", "expires": "2021-06-01"}`))
+		})
+
+		It("will reject invalid lifecycles", func() {
+			path := writeFile("config.json", `{"featuretoggles": {"wrongDate": {"expires": "01.06.2021"}}}`)
+
+			_, _, err := config.Load(config.Sources{Files: []string{base, path}})
+
+			Expect(err).To(MatchError(ContainSubstring("date must have the form YYYY-MM-DD")))
+
+			path = writeFile("config.json", `{"featuretoggles": {
+				"expiringKillSwitch": {"permanent": true, "expires": "2021-06-01"},
+				"expiredBeforeCreated": {"created": "2021-06-01", "expires": "2021-03-01"}
+			}}`)
+
+			_, _, err = config.Load(config.Sources{Files: []string{base, path}})
+
+			Expect(invalidConfigProblems(err)).To(ConsistOf(
+				"featuretoggles[expiringKillSwitch].expires: must not be set for permanent toggles",
+				"featuretoggles[expiredBeforeCreated].expires: must not be before the created date but is 2021-03-01",
+			))
+		})
+
+		It("will reject admin tokens without a token or with an operator name longer than the change log allows", func() {
+			path := writeFile("config.json", `{"toggleStore": {"adminTokens": {"jane": "", "`+strings.Repeat("a", 256)+`": "token"}}}`)
+
+			_, _, err := config.Load(config.Sources{Files: []string{base, path}})
+
+			Expect(invalidConfigProblems(err)).To(ConsistOf(
+				"toggleStore.adminTokens[jane]: is required",
+				"toggleStore.adminTokens["+strings.Repeat("a", 256)+"]: must be at most 255",
+			))
+		})
+
+		It("will reject toggle settings the flagd providers ignore", func() {
+			path := writeFile("config.json", `{
+				"featuretoggles": {"enableNewFeature": {"attributes": {"X-Beta-Tester": ["true"]}}},
+				"toggleProvider": {"type": "flagd-http", "address": "http://flagd:8016"},
+				"toggleStore": {"enabled": true},
+				"toggleOverrides": {"source": "query"}
+			}`)
+
+			_, _, err := config.Load(config.Sources{Files: []string{base, path}})
+
+			Expect(invalidConfigProblems(err)).To(ConsistOf(
+				"featuretoggles: must not be set with the toggle provider flagd-http, flagd resolves every flag itself",
+				"toggleStore.enabled: must not be set with the toggle provider flagd-http, flagd resolves every flag itself",
+				"toggleOverrides.source: must not be set with the toggle provider flagd-http, flagd resolves every flag itself",
+			))
+
+			path = writeFile("config.json", `{"toggleProvider": {"type": "flagd-http", "address": "http://flagd:8016"}}`)
+
+			_, _, err = config.Load(config.Sources{Files: []string{base, path}})
+
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("will validate toggles changed at runtime like the toggles of the config files", func() {
+			percentage := 120.0
+			expires := config.NewDate(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+			toggle := config.FeatureToggle{Percentage: &percentage, DefaultVariant: "c", Permanent: true, Expires: &expires}
+
+			err := config.ValidateToggle(toggle)
+
+			var validationError *custom_errors.ValidationError
+			Expect(stderrors.As(err, &validationError)).To(BeTrue())
+			Expect(validationError.Errors).To(HaveLen(3))
+			Expect(err.Error()).To(And(
+				ContainSubstring("percentage: must be at most 100"),
+				ContainSubstring("defaultVariant: must name one of the variants but is c"),
+				ContainSubstring("expires: must not be set for permanent toggles"),
+			))
+			Expect(config.ValidateToggle(config.FeatureToggle{Enabled: true})).To(Succeed())
+		})
+	})
+
+	Context("reload", func() {
+
+		var sources config.Sources
+		var store *config.Store
+		var configPath string
+
+		BeforeEach(func() {
+			configPath = writeFile("config.json", `{"featuretoggles": {"enableNewFeature": false}}`)
+			sources = config.Sources{Files: []string{base, configPath}}
+			cfg, _, err := config.Load(sources)
+			Expect(err).ToNot(HaveOccurred())
+			store = config.NewStore(cfg)
+		})
+
+		It("will swap reloadable sections and keep values that require a restart", func() {
+			writeFile("config.json", `{"featuretoggles": {"enableNewFeature": true}, "logging": {"level": "debug"}, "persistence": {"dbHost": "otherhost"}}`)
+			var notified config.Config
+			store.OnChange(func(cfg config.Config) { notified = cfg })
+
+			cfg, _, err := config.Load(sources)
+			Expect(err).ToNot(HaveOccurred())
+			changes, err := store.Update(cfg)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changes.Reloaded).To(Equal([]string{"featuretoggles.enableNewFeature", "logging.level"}))
+			Expect(changes.RestartRequired).To(Equal([]string{"persistence.dbHost"}))
+			Expect(store.Get().FeatureToggles["enableNewFeature"].Enabled).To(BeTrue())
+			Expect(store.Get().Logging.Level).To(Equal("debug"))
+			Expect(store.Get().Persistence.DbHost).To(Equal("basehost"))
+			Expect(notified).To(Equal(store.Get()))
+		})
+
+		It("will keep the current config if the new one is invalid", func() {
+			writeFile("config.json", `{"featuretoggles": {"enableNewFeature": true}, "logging": {"level": "loud"}}`)
+
+			err := store.Reload(sources)
+
+			Expect(err).To(HaveOccurred())
+			Expect(store.Get().FeatureToggles["enableNewFeature"].Enabled).To(BeFalse())
+			Expect(store.Get().Logging.Level).To(Equal("info"))
+		})
+
+		It("will reload when a config file changes", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go store.Watch(ctx, sources, 10*time.Millisecond)
+
+			// the watcher takes its first fingerprint when it starts
+			time.Sleep(50 * time.Millisecond)
+			writeFile("config.json", `{"featuretoggles": {"enableNewFeature": true}}`)
+
+			Eventually(func() bool {
+				return store.Get().FeatureToggles["enableNewFeature"].Enabled
+			}).Should(BeTrue())
+		})
+	})
+})
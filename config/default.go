@@ -1,7 +1,57 @@
 package config
 
+import "time"
+
 var defaultConfig = Config{
 	Name: "Golang Service",
-	FeatureToggles: map[string]bool{
+	Server: ServerConfig{
+		Address:             ":12345",
+		ReadTimeout:         Duration(30 * time.Second),
+		ReadHeaderTimeout:   Duration(10 * time.Second),
+		WriteTimeout:        Duration(30 * time.Second),
+		IdleTimeout:         Duration(120 * time.Second),
+		MaxHeaderBytes:      1 << 20,
+		MaxBodyBytes:        1 << 20,
+		ShutdownGracePeriod: Duration(30 * time.Second),
+	},
+	Persistence: PersistenceConfig{
+		MaxOpenConns:    20,
+		MaxIdleConns:    10,
+		ConnMaxLifetime: Duration(30 * time.Minute),
+		ConnMaxIdleTime: Duration(5 * time.Minute),
+	},
+	FeatureToggles: FeatureToggles{},
+	ToggleOverrides: ToggleOverridesConfig{
+		Source: "disabled",
+	},
+	ToggleStore: ToggleStoreConfig{
+		PollInterval: Duration(10 * time.Second),
+	},
+	ToggleProvider: ToggleProviderConfig{
+		Type:    "config",
+		Timeout: Duration(time.Second),
+	},
+	Idempotency: IdempotencyConfig{
+		KeyTTL:        Duration(24 * time.Hour),
+		PurgeInterval: Duration(time.Hour),
 	},
+	Validation: ValidationConfig{
+		DefaultPhoneRegion: "DE",
+	},
+	Tracing: TracingConfig{
+		SampleRatio: 1,
+	},
+	Logging: LoggingConfig{
+		Format:         "console",
+		Level:          "info",
+		RedactedFields: []string{"email", "phone_number", "password"},
+	},
+	Reload: ReloadConfig{
+		Interval: Duration(5 * time.Second),
+	},
+}
+
+// DefaultConfig returns the config used before any config file was read, e.g. to set up logging
+func DefaultConfig() Config {
+	return defaultConfig
 }
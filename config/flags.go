@@ -0,0 +1,61 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+const setFlag = "set"
+
+// RegisterFlags adds a flag for every config value to fs, e.g. --persistence.dbHost, and a repeatable
+// --set path=value for entries of maps such as feature toggles. The returned function collects the
+// flags that were given, it must be called after fs was parsed and the result passed as Sources.Flags.
+func RegisterFlags(fs *flag.FlagSet) func() map[string]string {
+	paths := make(map[string]bool)
+	for _, l := range structLeaves(configType, nil) {
+		path := strings.Join(l.path, ".")
+		paths[path] = true
+		fs.String(path, "", fmt.Sprintf("overrides the config value %s", path))
+	}
+
+	setValues := &keyValueFlag{values: map[string]string{}}
+	fs.Var(setValues, setFlag, "overrides a config value given as path=value, e.g. featuretoggles.enableNewFeature=true, can be repeated")
+
+	return func() map[string]string {
+		flags := make(map[string]string)
+		for path, value := range setValues.values {
+			flags[path] = value
+		}
+		fs.Visit(func(f *flag.Flag) {
+			if paths[f.Name] {
+				flags[f.Name] = f.Value.String()
+			}
+		})
+		return flags
+	}
+}
+
+type keyValueFlag struct {
+	values map[string]string
+}
+
+func (f *keyValueFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(f.values))
+	for key, value := range f.values {
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f *keyValueFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("%q must have the form path=value", value)
+	}
+	f.values[parts[0]] = parts[1]
+	return nil
+}
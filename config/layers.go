@@ -0,0 +1,397 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// EnvPrefix starts all environment variables overriding config values, e.g. GOSVC_PERSISTENCE_DBHOST
+	EnvPrefix = "GOSVC_"
+
+	layerDefault = "default"
+)
+
+var configType = reflect.TypeOf(Config{})
+
+// tree is a config layer as decoded from JSON, keys are the json names of the Config fields
+type tree = map[string]interface{}
+
+// Origin tells which layer a config value came from and whether it must not be printed
+type Origin struct {
+	Layer  string
+	Secret bool
+}
+
+// Origins maps config paths such as persistence.dbHost to their origin
+type Origins map[string]Origin
+
+// leaf is a config value that can be set on its own, entries of map fields are leaves below the path of the map
+type leaf struct {
+	path   []string
+	typ    reflect.Type
+	secret bool
+}
+
+// structLeaves returns every value of the struct type t that is not itself a struct
+func structLeaves(t reflect.Type, prefix []string) []leaf {
+	var leaves []leaf
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := jsonName(field)
+		if name == "" {
+			continue
+		}
+		path := append(append([]string{}, prefix...), name)
+		if field.Type.Kind() == reflect.Struct {
+			leaves = append(leaves, structLeaves(field.Type, path)...)
+			continue
+		}
+		leaves = append(leaves, leaf{path: path, typ: field.Type, secret: field.Tag.Get("secret") == "true"})
+	}
+	return leaves
+}
+
+func jsonName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// fieldByJSONName matches config file keys exactly, unlike encoding/json, such that typos in the case are reported
+func fieldByJSONName(t reflect.Type, name string) (reflect.StructField, string, bool) {
+	return findField(t, func(jsonName string) bool { return jsonName == name })
+}
+
+// fieldByJSONNameFold matches case-insensitively, for environment variables and flags
+func fieldByJSONNameFold(t reflect.Type, name string) (reflect.StructField, string, bool) {
+	return findField(t, func(jsonName string) bool { return strings.EqualFold(jsonName, name) })
+}
+
+func findField(t reflect.Type, matches func(jsonName string) bool) (reflect.StructField, string, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if jsonName := jsonName(field); jsonName != "" && matches(jsonName) {
+			return field, jsonName, true
+		}
+	}
+	return reflect.StructField{}, "", false
+}
+
+// walkTree calls fn for every leaf of the tree, t is nil for values that have no field in Config
+func walkTree(value interface{}, t reflect.Type, path []string, fn func(path []string, t reflect.Type, value interface{})) {
+	m, isMap := value.(tree)
+	switch {
+	case isMap && t != nil && t.Kind() == reflect.Struct:
+		for key, child := range m {
+			field, name, ok := fieldByJSONName(t, key)
+			if !ok {
+				fn(appendPath(path, key), nil, child)
+				continue
+			}
+			walkTree(child, field.Type, appendPath(path, name), fn)
+		}
+	case isMap && t != nil && t.Kind() == reflect.Map:
+		for key, child := range m {
+			fn(appendPath(path, key), t.Elem(), child)
+		}
+	default:
+		fn(path, t, value)
+	}
+}
+
+func appendPath(path []string, key string) []string {
+	return append(append([]string{}, path...), key)
+}
+
+// unknownKeys reports every key of the layer that has no field in Config
+func unknownKeys(layer tree, name string) []string {
+	var problems []string
+	walkTree(layer, configType, nil, func(path []string, t reflect.Type, _ interface{}) {
+		if t != nil {
+			return
+		}
+		problem := fmt.Sprintf("%s: unknown key %s", name, strings.Join(path, "."))
+		if parent := parentType(path[:len(path)-1]); parent != nil {
+			if _, suggestion, ok := fieldByJSONNameFold(parent, path[len(path)-1]); ok {
+				problem += fmt.Sprintf(", did you mean %s?", suggestion)
+			}
+		}
+		problems = append(problems, problem)
+	})
+	sort.Strings(problems)
+	return problems
+}
+
+func parentType(path []string) reflect.Type {
+	t := configType
+	for _, key := range path {
+		field, _, ok := fieldByJSONName(t, key)
+		if !ok || field.Type.Kind() != reflect.Struct {
+			return nil
+		}
+		t = field.Type
+	}
+	return t
+}
+
+// merge copies the layer into base, nested objects are merged while all other values are replaced
+func merge(base, layer tree) {
+	for key, value := range layer {
+		baseChild, baseIsMap := base[key].(tree)
+		layerChild, layerIsMap := value.(tree)
+		if baseIsMap && layerIsMap {
+			merge(baseChild, layerChild)
+			continue
+		}
+		base[key] = value
+	}
+}
+
+func setPath(t tree, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := t[key].(tree)
+		if !ok {
+			child = tree{}
+			t[key] = child
+		}
+		t = child
+	}
+	t[path[len(path)-1]] = value
+}
+
+func getPath(t tree, path []string) (interface{}, bool) {
+	var value interface{} = t
+	for _, key := range path {
+		m, ok := value.(tree)
+		if !ok {
+			return nil, false
+		}
+		value, ok = m[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// lookupLeaf resolves a path such as persistence.dbHost or featuretoggles.enableNewFeature,
+// the keys of map fields are matched case-insensitively against the keys already in the tree
+func lookupLeaf(t tree, path []string) (leaf, error) {
+	typ := configType
+	resolved := make([]string, 0, len(path))
+	secret := false
+	mapEntry := false
+	for i, key := range path {
+		switch typ.Kind() {
+		case reflect.Struct:
+			field, name, ok := fieldByJSONNameFold(typ, key)
+			if !ok {
+				return leaf{}, fmt.Errorf("unknown config value %s", strings.Join(path, "."))
+			}
+			resolved = append(resolved, name)
+			typ = field.Type
+			secret = field.Tag.Get("secret") == "true"
+		case reflect.Map:
+			if i != len(path)-1 {
+				return leaf{}, fmt.Errorf("unknown config value %s", strings.Join(path, "."))
+			}
+			resolved = append(resolved, existingKey(t, resolved, key))
+			typ = typ.Elem()
+			mapEntry = true
+		default:
+			return leaf{}, fmt.Errorf("unknown config value %s", strings.Join(path, "."))
+		}
+	}
+	// entries of maps are values even if they are structs, such as feature toggles with rules
+	if typ.Kind() == reflect.Struct && !mapEntry {
+		return leaf{}, fmt.Errorf("config value %s is a section, not a value", strings.Join(path, "."))
+	}
+	return leaf{path: resolved, typ: typ, secret: secret}, nil
+}
+
+func existingKey(t tree, mapPath []string, key string) string {
+	if m, ok := getPathMap(t, mapPath); ok {
+		for existing := range m {
+			if strings.EqualFold(existing, key) {
+				return existing
+			}
+		}
+	}
+	return key
+}
+
+func getPathMap(t tree, path []string) (tree, bool) {
+	value, ok := getPath(t, path)
+	if !ok {
+		return nil, false
+	}
+	m, ok := value.(tree)
+	return m, ok
+}
+
+// parseValue converts a value given as text, e.g. in an environment variable, into the JSON value for type t.
+// Text that is valid JSON for t is used as is, otherwise it is taken as string, lists may also be comma separated.
+func parseValue(raw string, t reflect.Type) (interface{}, error) {
+	var value interface{}
+	if json.Unmarshal([]byte(raw), reflect.New(t).Interface()) == nil {
+		_ = json.Unmarshal([]byte(raw), &value)
+		return value, nil
+	}
+
+	quoted, _ := json.Marshal(raw)
+	if json.Unmarshal(quoted, reflect.New(t).Interface()) == nil {
+		return raw, nil
+	}
+
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String {
+		var list []interface{}
+		for _, element := range strings.Split(raw, ",") {
+			list = append(list, strings.TrimSpace(element))
+		}
+		return list, nil
+	}
+
+	return nil, fmt.Errorf("%q is not a valid %s", raw, t.String())
+}
+
+// envLayer applies all environment variables starting with EnvPrefix to the tree,
+// the variable name is the config path in upper case with _ instead of ., e.g. GOSVC_PERSISTENCE_DBHOST
+func envLayer(t tree, environment []string, origins Origins) []string {
+	var problems []string
+	leaves := make(map[string]leaf)
+	for _, l := range structLeaves(configType, nil) {
+		leaves[envName(l.path)] = l
+	}
+
+	for _, variable := range environment {
+		name, raw := splitEnv(variable)
+		if !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+
+		l, ok := leaves[name]
+		if !ok {
+			var err error
+			l, err = mapEntryForEnv(t, leaves, name)
+			if err != nil {
+				problems = append(problems, err.Error())
+				continue
+			}
+		}
+
+		value, err := parseValue(raw, l.typ)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid value for environment variable %s: %s", name, err.Error()))
+			continue
+		}
+		setPath(t, l.path, value)
+		origins[strings.Join(l.path, ".")] = Origin{Layer: "env " + name}
+	}
+	return problems
+}
+
+// mapEntryForEnv resolves variables such as GOSVC_FEATURETOGGLES_ENABLENEWFEATURE to an entry of a map field
+func mapEntryForEnv(t tree, leaves map[string]leaf, name string) (leaf, error) {
+	for mapName, l := range leaves {
+		if l.typ.Kind() != reflect.Map || !strings.HasPrefix(name, mapName+"_") {
+			continue
+		}
+		key := existingKey(t, l.path, strings.TrimPrefix(name, mapName+"_"))
+		return leaf{path: appendPath(l.path, key), typ: l.typ.Elem()}, nil
+	}
+	return leaf{}, fmt.Errorf("environment variable %s does not match any config value", name)
+}
+
+func envName(path []string) string {
+	return EnvPrefix + strings.ToUpper(strings.Join(path, "_"))
+}
+
+func splitEnv(variable string) (string, string) {
+	parts := strings.SplitN(variable, "=", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// flagLayer applies values given on the command line, keyed by config path such as persistence.dbHost
+func flagLayer(t tree, flags map[string]string, origins Origins) []string {
+	var problems []string
+	paths := make([]string, 0, len(flags))
+	for path := range flags {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		l, err := lookupLeaf(t, strings.Split(path, "."))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid flag --%s: %s", path, err.Error()))
+			continue
+		}
+		value, err := parseValue(flags[path], l.typ)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid value for flag --%s: %s", path, err.Error()))
+			continue
+		}
+		setPath(t, l.path, value)
+		origins[strings.Join(l.path, ".")] = Origin{Layer: "flag --" + path}
+	}
+	return problems
+}
+
+// recordOrigins marks every value of the layer as coming from it
+func recordOrigins(layer tree, name string, origins Origins) {
+	walkTree(layer, configType, nil, func(path []string, t reflect.Type, _ interface{}) {
+		if t != nil {
+			origins[strings.Join(path, ".")] = Origin{Layer: name}
+		}
+	})
+}
+
+// markSecrets flags values that are tagged as secret or filled in from secrets by a template
+func markSecrets(t tree, origins Origins) {
+	secretFields := make(map[string]bool)
+	for _, l := range structLeaves(configType, nil) {
+		if l.secret {
+			secretFields[strings.Join(l.path, ".")] = true
+		}
+	}
+
+	walkTree(t, configType, nil, func(path []string, typ reflect.Type, value interface{}) {
+		key := strings.Join(path, ".")
+		text, isString := value.(string)
+		// the entries of a secret map are secrets as well
+		secretEntry := len(path) > 1 && secretFields[strings.Join(path[:len(path)-1], ".")]
+		if secretFields[key] || secretEntry || (isString && strings.Contains(text, "{{")) {
+			origin, ok := origins[key]
+			if !ok {
+				origin = Origin{Layer: layerDefault}
+			}
+			origin.Secret = true
+			origins[key] = origin
+		}
+	})
+}
+
+func toTree(value interface{}) (tree, error) {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	t := tree{}
+	err = json.Unmarshal(bytes, &t)
+	return t, err
+}
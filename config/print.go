@@ -0,0 +1,50 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Print writes every value of the effective config with the layer it came from, one per line,
+// secrets are replaced by [REDACTED]
+func Print(w io.Writer, config Config, origins Origins) error {
+	effective, err := toTree(config)
+	if err != nil {
+		return fmt.Errorf("could not marshal config: %s", err.Error())
+	}
+
+	lines := make(map[string]string)
+	walkTree(effective, configType, nil, func(path []string, _ reflect.Type, value interface{}) {
+		key := strings.Join(path, ".")
+		origin, ok := origins[key]
+		if !ok {
+			origin = Origin{Layer: layerDefault}
+		}
+
+		text := redacted
+		if !origin.Secret {
+			encoded, _ := json.Marshal(value)
+			text = string(encoded)
+		}
+		lines[key] = fmt.Sprintf("%s = %s (%s)", key, text, origin.Layer)
+	})
+
+	keys := make([]string, 0, len(lines))
+	for key := range lines {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if _, err := fmt.Fprintln(w, lines[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"github.com/go-playground/log"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Store holds the current config, reloads replace the sections of Config tagged reload:"true" atomically
+type Store struct {
+	current   atomic.Value
+	mu        sync.Mutex
+	listeners []func(Config)
+}
+
+func NewStore(config Config) *Store {
+	store := &Store{}
+	store.current.Store(config)
+	return store
+}
+
+// Get returns the current config, callers must not modify its maps and slices
+func (s *Store) Get() Config {
+	return s.current.Load().(Config)
+}
+
+// Changes lists the config paths whose values differ after an update
+type Changes struct {
+	Reloaded []string
+	// RestartRequired values are not applied until the service is restarted
+	RestartRequired []string
+}
+
+// OnChange registers a listener that is called with the new config after every update
+func (s *Store) OnChange(listener func(Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// Update swaps in the reloadable sections of next, the other sections of the current config are kept
+func (s *Store) Update(next Config) (Changes, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.Get()
+	reloaded, err := changedPaths(current, next, true)
+	if err != nil {
+		return Changes{}, err
+	}
+	restartRequired, err := changedPaths(current, next, false)
+	if err != nil {
+		return Changes{}, err
+	}
+
+	updated := current
+	currentValue := reflect.ValueOf(&updated).Elem()
+	nextValue := reflect.ValueOf(next)
+	for i := 0; i < configType.NumField(); i++ {
+		if isReloadable(configType.Field(i)) {
+			currentValue.Field(i).Set(nextValue.Field(i))
+		}
+	}
+	s.current.Store(updated)
+
+	for _, listener := range s.listeners {
+		listener(updated)
+	}
+	return Changes{Reloaded: reloaded, RestartRequired: restartRequired}, nil
+}
+
+// Reload loads the config from sources again, an invalid config is rejected and the current config is kept
+func (s *Store) Reload(sources Sources) error {
+	next, _, err := Load(sources)
+	if err != nil {
+		return err
+	}
+	changes, err := s.Update(next)
+	if err != nil {
+		return fmt.Errorf("could not update config: %s", err.Error())
+	}
+	for _, path := range changes.Reloaded {
+		log.Infof("config value %s reloaded", path)
+	}
+	for _, path := range changes.RestartRequired {
+		log.Warnf("config value %s changed but requires a restart to take effect", path)
+	}
+	return nil
+}
+
+// Watch reloads the config whenever one of the config files or secrets changes, changes are detected by polling
+// because editors and Kubernetes replace files by renaming, which file watches lose track of. Watch blocks until ctx is done.
+func (s *Store) Watch(ctx context.Context, sources Sources, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := fingerprint(sources)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := fingerprint(sources)
+			if current == last {
+				continue
+			}
+			last = current
+			log.Info("config files changed, reloading config")
+			err := s.Reload(sources)
+			if err != nil {
+				log.WithError(err).Error("could not reload config, keeping the current config")
+			}
+		}
+	}
+}
+
+func isReloadable(field reflect.StructField) bool {
+	return field.Tag.Get("reload") == "true"
+}
+
+// changedPaths lists the config paths whose values differ, either in the reloadable sections or in all others
+func changedPaths(previous, next Config, reloadable bool) ([]string, error) {
+	previousTree, err := toTree(previous)
+	if err != nil {
+		return nil, err
+	}
+	nextTree, err := toTree(next)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		if isReloadable(field) != reloadable {
+			continue
+		}
+		name := jsonName(field)
+		changed = append(changed, diffValues(previousTree[name], nextTree[name], []string{name})...)
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+func diffValues(previous, next interface{}, path []string) []string {
+	previousMap, previousIsMap := previous.(tree)
+	nextMap, nextIsMap := next.(tree)
+	if !previousIsMap || !nextIsMap {
+		if reflect.DeepEqual(previous, next) {
+			return nil
+		}
+		return []string{strings.Join(path, ".")}
+	}
+
+	var changed []string
+	for key, value := range previousMap {
+		changed = append(changed, diffValues(value, nextMap[key], appendPath(path, key))...)
+	}
+	for key, value := range nextMap {
+		if _, ok := previousMap[key]; !ok {
+			changed = append(changed, diffValues(nil, value, appendPath(path, key))...)
+		}
+	}
+	return changed
+}
+
+// fingerprint hashes the contents of the config files and the secrets directory, unreadable files hash as empty
+func fingerprint(sources Sources) [sha256.Size]byte {
+	hash := sha256.New()
+	paths := append([]string{}, sources.Files...)
+	if sources.SecretsDirectoryPath != "" {
+		secretFiles, _ := ioutil.ReadDir(sources.SecretsDirectoryPath)
+		for _, fileInfo := range secretFiles {
+			if fileInfo.Name()[0:1] == "." || fileInfo.IsDir() {
+				continue
+			}
+			paths = append(paths, filepath.Join(sources.SecretsDirectoryPath, fileInfo.Name()))
+		}
+	}
+	for _, path := range paths {
+		content, _ := ioutil.ReadFile(path)
+		_, _ = fmt.Fprintf(hash, "%s:%d:", path, len(content))
+		_, _ = hash.Write(content)
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/jenpaff/golang-microservices/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileSecretProvider reads each secret from a file of the directory, e.g. a mounted Kubernetes secret
+type FileSecretProvider struct {
+	directory string
+}
+
+func NewFileSecretProvider(directory string) *FileSecretProvider {
+	return &FileSecretProvider{directory: directory}
+}
+
+func (p *FileSecretProvider) Secret(_ context.Context, name string) (string, error) {
+	cleaned := filepath.Clean(name)
+	if p.directory == "" || filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", ErrSecretNotFound
+	}
+	content, err := ioutil.ReadFile(filepath.Join(p.directory, cleaned))
+	if os.IsNotExist(err) {
+		return "", ErrSecretNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// EnvSecretProvider serves the secrets injected as "name:base64 value;name:base64 value", e.g. in ENV_SECRETS
+type EnvSecretProvider struct {
+	secrets map[string]string
+}
+
+func NewEnvSecretProvider(injectedSecrets string) (*EnvSecretProvider, error) {
+	secrets, err := injectSecretsFromEnvironment(injectedSecrets, make(map[string]string))
+	if err != nil {
+		return nil, err
+	}
+	return &EnvSecretProvider{secrets: secrets}, nil
+}
+
+func (p *EnvSecretProvider) Secret(_ context.Context, name string) (string, error) {
+	secret, ok := p.secrets[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return secret, nil
+}
+
+// VaultSecretProvider reads secrets from a HashiCorp Vault compatible KV version 2 secrets engine,
+// the name "db/password" refers to the key password of the secret at the path db
+type VaultSecretProvider struct {
+	address string
+	mount   string
+	token   string
+	client  *http.Client
+
+	mu sync.Mutex
+	// secrets caches the keys of each path read, a config usually refers to several keys of the same path
+	secrets map[string]map[string]interface{}
+}
+
+func NewVaultSecretProvider(cfg VaultConfig, token string) *VaultSecretProvider {
+	mount := cfg.Mount
+	if mount == "" {
+		mount = "secret"
+	}
+	timeout := time.Duration(cfg.Timeout)
+	if timeout == 0 {
+		timeout = defaultSecretTimeout
+	}
+	return &VaultSecretProvider{
+		address: strings.TrimSuffix(cfg.Address, "/"),
+		mount:   strings.Trim(mount, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: timeout},
+		secrets: make(map[string]map[string]interface{}),
+	}
+}
+
+func (p *VaultSecretProvider) Secret(ctx context.Context, name string) (string, error) {
+	secretPath, key := path.Split(name)
+	secretPath = strings.Trim(secretPath, "/")
+	if secretPath == "" || key == "" {
+		return "", fmt.Errorf("vault secret %s must have the form path/key", name)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, ok := p.secrets[secretPath]
+	if !ok {
+		var err error
+		data, err = p.read(ctx, secretPath)
+		if err != nil {
+			return "", err
+		}
+		p.secrets[secretPath] = data
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	if text, ok := value.(string); ok {
+		return text, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+type vaultResponse struct {
+	Data struct {
+		Data map[string]interface{} `json:"data"`
+	} `json:"data"`
+}
+
+func (p *VaultSecretProvider) read(ctx context.Context, secretPath string) (map[string]interface{}, error) {
+	secretURL := fmt.Sprintf("%s/v1/%s/data/%s", p.address, url.PathEscape(p.mount), escapePath(secretPath))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, secretURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach vault: %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrSecretNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned status %d for %s", resp.StatusCode, secretPath)
+	}
+
+	var body vaultResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode vault response: %s", err.Error())
+	}
+	if body.Data.Data == nil {
+		return map[string]interface{}{}, nil
+	}
+	return body.Data.Data, nil
+}
+
+func escapePath(secretPath string) string {
+	segments := strings.Split(secretPath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// EncryptedFileSecretProvider reads secrets from a JSON object such as {"db_password": "..."} encrypted with
+// AES-256-GCM, the file holds the base64 encoded nonce followed by the ciphertext, see EncryptSecrets
+type EncryptedFileSecretProvider struct {
+	path string
+	key  []byte
+
+	once    sync.Once
+	secrets map[string]string
+	err     error
+}
+
+func NewEncryptedFileSecretProvider(path string, key []byte) *EncryptedFileSecretProvider {
+	return &EncryptedFileSecretProvider{path: path, key: key}
+}
+
+func (p *EncryptedFileSecretProvider) Secret(_ context.Context, name string) (string, error) {
+	p.once.Do(func() {
+		p.secrets, p.err = p.decrypt()
+	})
+	if p.err != nil {
+		return "", p.err
+	}
+	secret, ok := p.secrets[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return secret, nil
+}
+
+func (p *EncryptedFileSecretProvider) decrypt() (map[string]string, error) {
+	encoded, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read encrypted secrets %s: %s", p.path, err.Error())
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode encrypted secrets %s: %s", p.path, err.Error())
+	}
+	gcm, err := newGCM(p.key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted secrets %s are too short", p.path)
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt secrets %s, is the key correct?", p.path)
+	}
+	secrets := make(map[string]string)
+	err = json.Unmarshal(plaintext, &secrets)
+	if err != nil {
+		return nil, fmt.Errorf("encrypted secrets %s must be a JSON object of strings: %s", p.path, err.Error())
+	}
+	return secrets, nil
+}
+
+// EncryptSecrets creates the content of a file for the EncryptedFileSecretProvider, key must have 32 bytes
+func EncryptSecrets(key []byte, secrets map[string]string) ([]byte, error) {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+	sealed := gcm.Seal(nonce, nonce, plaintext, nil)
+	return []byte(base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("secrets key must have 32 bytes but has %d: %w", len(key), errors.InternalServerError)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"github.com/jenpaff/golang-microservices/errors"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// SecretProvider looks up secrets by name, e.g. "db/password" for the reference {{ secret "vault:db/password" }}
+type SecretProvider interface {
+	// Secret returns ErrSecretNotFound if the provider does not have the secret
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// ErrSecretNotFound is returned by a SecretProvider for secrets it does not have
+var ErrSecretNotFound = stderrors.New("secret not found")
+
+const (
+	ProviderFile      = "file"
+	ProviderEnv       = "env"
+	ProviderVault     = "vault"
+	ProviderEncrypted = "encrypted"
+
+	defaultSecretTimeout = 5 * time.Second
+)
+
+// placeholderPattern finds secret placeholders such as {{ .db_password }}
+var placeholderPattern = regexp.MustCompile(`{{\s*\.(\w+)\s*}}`)
+
+// referencePattern finds secret references such as {{ secret "vault:db/password" }}, references without a provider
+// are looked up like placeholders
+var referencePattern = regexp.MustCompile(`{{\s*secret\s+"([^"]+)"\s*}}`)
+
+// secretProviders creates the providers the config can refer to, providers of sources replace the built-in ones
+func secretProviders(layered tree, sources Sources) (map[string]SecretProvider, []string) {
+	providers := map[string]SecretProvider{
+		ProviderFile: NewFileSecretProvider(sources.SecretsDirectoryPath),
+	}
+	var problems []string
+
+	envProvider, err := NewEnvSecretProvider(sources.InjectedSecrets)
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else {
+		providers[ProviderEnv] = envProvider
+	}
+
+	var secretsConfig SecretsConfig
+	if section, ok := layered["secrets"]; ok {
+		sectionJSON, err := json.Marshal(section)
+		if err == nil {
+			err = json.Unmarshal(sectionJSON, &secretsConfig)
+		}
+		if err != nil {
+			// reported once more when the whole config is unmarshalled
+			return providers, problems
+		}
+	}
+
+	if secretsConfig.Vault.Address != "" {
+		tokenEnv := secretsConfig.Vault.TokenEnv
+		if tokenEnv == "" {
+			tokenEnv = "VAULT_TOKEN"
+		}
+		token, ok := lookupEnv(sources.Environment, tokenEnv)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("secrets.vault: environment variable %s with the vault token is not set", tokenEnv))
+		} else {
+			providers[ProviderVault] = NewVaultSecretProvider(secretsConfig.Vault, token)
+		}
+	}
+
+	if secretsConfig.EncryptedFile.Path != "" {
+		keyEnv := secretsConfig.EncryptedFile.KeyEnv
+		if keyEnv == "" {
+			keyEnv = "SECRETS_KEY"
+		}
+		encodedKey, ok := lookupEnv(sources.Environment, keyEnv)
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if !ok || err != nil || len(key) != 32 {
+			problems = append(problems, fmt.Sprintf("secrets.encryptedFile: environment variable %s must hold a base64 encoded 256 bit key", keyEnv))
+		} else {
+			providers[ProviderEncrypted] = NewEncryptedFileSecretProvider(secretsConfig.EncryptedFile.Path, key)
+		}
+	}
+
+	for name, provider := range sources.SecretProviders {
+		providers[name] = provider
+	}
+	return providers, problems
+}
+
+// resolveSecrets looks up every secret the tree refers to and reports the ones that cannot be resolved
+func resolveSecrets(ctx context.Context, t tree, secrets map[string]string, providers map[string]SecretProvider) (map[string]string, []string) {
+	resolved := make(map[string]string)
+	var problems []string
+
+	walkTree(t, configType, nil, func(path []string, _ reflect.Type, value interface{}) {
+		text, ok := value.(string)
+		if !ok {
+			return
+		}
+		location := strings.Join(path, ".")
+
+		for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			if _, ok := secrets[match[1]]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: secret %s is not defined", location, match[1]))
+			}
+		}
+
+		for _, match := range referencePattern.FindAllStringSubmatch(text, -1) {
+			reference := match[1]
+			if _, ok := resolved[reference]; ok {
+				continue
+			}
+			providerName, name, qualified := splitReference(reference)
+			if !qualified {
+				if _, ok := secrets[reference]; !ok {
+					problems = append(problems, fmt.Sprintf("%s: secret %s is not defined", location, reference))
+				}
+				continue
+			}
+			provider, ok := providers[providerName]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: secret provider %s is not configured", location, providerName))
+				continue
+			}
+			secret, err := provider.Secret(ctx, name)
+			if stderrors.Is(err, ErrSecretNotFound) {
+				problems = append(problems, fmt.Sprintf("%s: secret %s is not defined", location, reference))
+				continue
+			}
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: could not read secret %s: %s", location, reference, err.Error()))
+				continue
+			}
+			resolved[reference] = secret
+		}
+	})
+	sort.Strings(problems)
+	return resolved, problems
+}
+
+func splitReference(reference string) (string, string, bool) {
+	parts := strings.SplitN(reference, ":", 2)
+	if len(parts) != 2 {
+		return "", reference, false
+	}
+	return parts[0], parts[1], true
+}
+
+// insertSecrets replaces the placeholders and references of every string value of the tree by the secrets,
+// each value is a template of its own such that secrets are inserted verbatim, whatever characters they contain
+func insertSecrets(value interface{}, secrets map[string]string, resolved map[string]string) (interface{}, error) {
+	switch typed := value.(type) {
+	case tree:
+		for key, child := range typed {
+			inserted, err := insertSecrets(child, secrets, resolved)
+			if err != nil {
+				return nil, err
+			}
+			typed[key] = inserted
+		}
+		return typed, nil
+	case []interface{}:
+		for i, child := range typed {
+			inserted, err := insertSecrets(child, secrets, resolved)
+			if err != nil {
+				return nil, err
+			}
+			typed[i] = inserted
+		}
+		return typed, nil
+	case string:
+		if !strings.Contains(typed, "{{") {
+			return typed, nil
+		}
+		secretsTemplate, err := template.New("secret").Funcs(template.FuncMap{
+			"secret": func(reference string) string {
+				if secret, ok := resolved[reference]; ok {
+					return secret
+				}
+				return secrets[reference]
+			},
+		}).Parse(typed)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse secret template %q: %s: %w", typed, err.Error(), errors.InternalServerError)
+		}
+		var inserted bytes.Buffer
+		err = secretsTemplate.Execute(&inserted, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("could not insert secrets: %s: %w", err.Error(), errors.InternalServerError)
+		}
+		return inserted.String(), nil
+	default:
+		return value, nil
+	}
+}
+
+func lookupEnv(environment []string, name string) (string, bool) {
+	for _, variable := range environment {
+		key, value := splitEnv(variable)
+		if key == name {
+			return value, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,199 @@
+//+build unit
+
+package config_test
+
+import (
+	"context"
+	"encoding/base64"
+	stderrors "errors"
+	"github.com/jenpaff/golang-microservices/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+)
+
+var _ = Describe("Secrets", func() {
+
+	var dir string
+	var base string
+
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(Succeed())
+		return path
+	}
+
+	problemsOf := func(err error) []string {
+		var invalidConfigError *config.InvalidConfigError
+		Expect(stderrors.As(err, &invalidConfigError)).To(BeTrue())
+		return invalidConfigError.Problems
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "secrets")
+		Expect(err).ToNot(HaveOccurred())
+		base = writeFile("base.json", basePersistence)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	Context("vault", func() {
+
+		var vault *httptest.Server
+		var requests int
+
+		BeforeEach(func() {
+			requests = 0
+			vault = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if r.Header.Get("X-Vault-Token") != "token" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				if r.URL.Path != "/v1/kv/data/service/db" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = w.Write([]byte(`{"data": {"data": {"username": "vaultuser", "password": "p\"w{{"}, "metadata": {"version": 3}}}`))
+			}))
+		})
+
+		AfterEach(func() {
+			vault.Close()
+		})
+
+		It("will insert secrets read from vault", func() {
+			path := writeFile("config.json", `{
+				"secrets": {"vault": {"address": "`+vault.URL+`", "mount": "kv"}},
+				"persistence": {"dbUsername": "{{ secret \"vault:service/db/username\" }}", "dbPassword": "{{ secret \"vault:service/db/password\" }}"}
+			}`)
+
+			cfg, origins, err := config.Load(config.Sources{Files: []string{base, path}, Environment: []string{"VAULT_TOKEN=token"}})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Persistence.DbUsername).To(Equal("vaultuser"))
+			Expect(cfg.Persistence.DbPassword).To(Equal(`p"w{{`))
+			Expect(origins["persistence.dbUsername"].Secret).To(BeTrue())
+			Expect(requests).To(Equal(1))
+		})
+
+		It("will report secrets missing in vault and a missing token", func() {
+			path := writeFile("config.json", `{
+				"secrets": {"vault": {"address": "`+vault.URL+`", "mount": "kv", "tokenEnv": "MY_TOKEN"}},
+				"persistence": {"dbPassword": "{{ secret \"vault:service/db/unknown\" }}"}
+			}`)
+
+			_, _, err := config.Load(config.Sources{Files: []string{base, path}})
+			Expect(problemsOf(err)).To(ConsistOf(
+				"secrets.vault: environment variable MY_TOKEN with the vault token is not set",
+				"persistence.dbPassword: secret provider vault is not configured",
+			))
+
+			_, _, err = config.Load(config.Sources{Files: []string{base, path}, Environment: []string{"MY_TOKEN=token"}})
+			Expect(problemsOf(err)).To(ConsistOf("persistence.dbPassword: secret vault:service/db/unknown is not defined"))
+		})
+	})
+
+	Context("encrypted file", func() {
+
+		var key []byte
+
+		BeforeEach(func() {
+			key = make([]byte, 32)
+			for i := range key {
+				key[i] = byte(i)
+			}
+			encrypted, err := config.EncryptSecrets(key, map[string]string{"db_password": "encrypted secret"})
+			Expect(err).ToNot(HaveOccurred())
+			writeFile("secrets.enc", string(encrypted))
+		})
+
+		It("will insert secrets decrypted with the key from the environment", func() {
+			path := writeFile("config.json", `{
+				"secrets": {"encryptedFile": {"path": "`+filepath.Join(dir, "secrets.enc")+`"}},
+				"persistence": {"dbPassword": "{{ secret \"encrypted:db_password\" }}"}
+			}`)
+
+			cfg, _, err := config.Load(config.Sources{
+				Files:       []string{base, path},
+				Environment: []string{"SECRETS_KEY=" + base64.StdEncoding.EncodeToString(key)},
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Persistence.DbPassword).To(Equal("encrypted secret"))
+		})
+
+		It("will not decrypt with a wrong key", func() {
+			wrongKey := make([]byte, 32)
+			provider := config.NewEncryptedFileSecretProvider(filepath.Join(dir, "secrets.enc"), wrongKey)
+
+			_, err := provider.Secret(context.Background(), "db_password")
+
+			Expect(err).To(MatchError(ContainSubstring("could not decrypt")))
+		})
+	})
+
+	Context("file and env", func() {
+
+		It("will resolve qualified and unqualified references", func() {
+			secretsDir := filepath.Join(dir, "secrets")
+			Expect(os.Mkdir(secretsDir, 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(secretsDir, "db_user"), []byte("fileuser\n"), 0600)).To(Succeed())
+			path := writeFile("config.json", `{
+				"name": "{{ secret \"db_user\" }}",
+				"persistence": {"dbUsername": "{{ secret \"file:db_user\" }}", "dbPassword": "{{ secret \"env:db_password\" }}"}
+			}`)
+			injected := "db_password:" + base64.StdEncoding.EncodeToString([]byte("envpassword")) + ";"
+
+			cfg, _, err := config.Load(config.Sources{Files: []string{base, path}, SecretsDirectoryPath: secretsDir, InjectedSecrets: injected})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Name).To(Equal("fileuser"))
+			Expect(cfg.Persistence.DbUsername).To(Equal("fileuser"))
+			Expect(cfg.Persistence.DbPassword).To(Equal("envpassword"))
+		})
+
+		It("will not read files outside of the secrets directory", func() {
+			provider := config.NewFileSecretProvider(filepath.Join(dir, "secrets"))
+
+			_, err := provider.Secret(context.Background(), "../base.json")
+
+			Expect(err).To(Equal(config.ErrSecretNotFound))
+		})
+
+		It("will report injected secrets without a name instead of panicking", func() {
+			_, _, err := config.Load(config.Sources{Files: []string{base}, InjectedSecrets: "bm9uYW1l"})
+
+			Expect(problemsOf(err)).To(ConsistOf(ContainSubstring("injected secret 1 must have the form name:base64 value")))
+		})
+
+		It("will use custom providers", func() {
+			path := writeFile("config.json", `{"persistence": {"dbPassword": "{{ secret \"custom:password\" }}"}}`)
+
+			cfg, _, err := config.Load(config.Sources{
+				Files:           []string{base, path},
+				SecretProviders: map[string]config.SecretProvider{"custom": staticSecrets{"password": "custom secret"}},
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Persistence.DbPassword).To(Equal("custom secret"))
+		})
+	})
+})
+
+type staticSecrets map[string]string
+
+func (s staticSecrets) Secret(_ context.Context, name string) (string, error) {
+	secret, ok := s[name]
+	if !ok {
+		return "", config.ErrSecretNotFound
+	}
+	return secret, nil
+}
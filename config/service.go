@@ -1,78 +1,130 @@
 package config
 
 import (
-	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"github.com/BurntSushi/toml"
 	"github.com/go-playground/log"
-	"github.com/imdario/mergo"
 	"github.com/jenpaff/golang-microservices/errors"
+	"gopkg.in/yaml.v3"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
-	"text/template"
 )
 
+// Sources lists where the config is read from, each layer overrides the values of the layers before:
+// defaults, the files in the given order, environment variables and finally command line flags
+type Sources struct {
+	Files                []string
+	SecretsDirectoryPath string
+	InjectedSecrets      string
+	// Environment holds KEY=value pairs as returned by os.Environ, only variables starting with EnvPrefix are used
+	Environment []string
+	// Flags maps config paths such as persistence.dbHost to the value given on the command line
+	Flags map[string]string
+	// SecretProviders adds providers for references such as {{ secret "name:path" }} or replaces the built-in
+	// file, env, vault and encrypted providers
+	SecretProviders map[string]SecretProvider
+}
+
 // Build creates and initializes the configuration
 func BuildConfig(envConfigFilePath string, secretsDirectoryPath string, injectedSecrets string) (Config, error) {
+	config, _, err := Load(Sources{
+		Files:                []string{envConfigFilePath},
+		SecretsDirectoryPath: secretsDirectoryPath,
+		InjectedSecrets:      injectedSecrets,
+	})
+	return config, err
+}
+
+// Load builds the config from all layers of sources and returns where each value came from.
+// It fails with an InvalidConfigError listing every unknown key, unresolved secret and invalid value.
+func Load(sources Sources) (Config, Origins, error) {
 	config := defaultConfig
+	origins := make(Origins)
+	var problems []string
 
-	envConfig, err := readEnvSpecificConfig(envConfigFilePath)
+	layered, err := toTree(defaultConfig)
 	if err != nil {
-		return config, err
+		return config, nil, fmt.Errorf("could not marshal default config: %s: %w", err.Error(), errors.InternalServerError)
 	}
+	recordOrigins(layered, layerDefault, origins)
 
-	err = mergo.Merge(&config, envConfig, mergo.WithOverride)
-	if err != nil {
-		return config, fmt.Errorf("could not merge configs: %s: %w", err.Error(), errors.InternalServerError)
+	for _, envConfigFilePath := range sources.Files {
+		fileLayer, err := readConfigFile(envConfigFilePath)
+		if err != nil {
+			return config, nil, err
+		}
+		name := "file " + envConfigFilePath
+		problems = append(problems, unknownKeys(fileLayer, name)...)
+		merge(layered, fileLayer)
+		recordOrigins(fileLayer, name, origins)
 	}
 
-	configJSON, err := json.Marshal(config)
-	if err != nil {
-		return config, fmt.Errorf("could not marshal config: %s: %w", err.Error(), errors.InternalServerError)
-	}
+	problems = append(problems, envLayer(layered, sources.Environment, origins)...)
+	problems = append(problems, flagLayer(layered, sources.Flags, origins)...)
 
-	secretsTemplate, err := template.New("config").Parse(string(configJSON))
-	if err != nil {
-		return config, fmt.Errorf("could not create secrets template: %s: %w", err.Error(), errors.InternalServerError)
-	}
+	markSecrets(layered, origins)
 
 	secrets := make(map[string]string)
 
-	secrets, err = injectSecretsFromSecretsDir(secretsDirectoryPath, secrets)
+	secrets, err = injectSecretsFromSecretsDir(sources.SecretsDirectoryPath, secrets)
 	if err != nil {
-		return config, err
+		return config, nil, err
 	}
 
-	secrets, err = injectSecretsFromEnvironment(injectedSecrets, secrets)
+	// a malformed entry is reported together with the other problems by the env secret provider
+	_, _ = injectSecretsFromEnvironment(sources.InjectedSecrets, secrets)
+
+	providers, providerProblems := secretProviders(layered, sources)
+	problems = append(problems, providerProblems...)
+
+	resolved, secretProblems := resolveSecrets(context.Background(), layered, secrets, providers)
+	problems = append(problems, secretProblems...)
+
+	_, err = insertSecrets(layered, secrets, resolved)
 	if err != nil {
-		return config, err
+		return config, nil, err
 	}
 
-	var tpl bytes.Buffer
-	err = secretsTemplate.Execute(&tpl, secrets)
+	configJSON, err := json.Marshal(layered)
 	if err != nil {
-		return config, fmt.Errorf("could not insert secrets into template: %s: %w", err.Error(), errors.InternalServerError)
+		return config, nil, fmt.Errorf("could not marshal config: %s: %w", err.Error(), errors.InternalServerError)
 	}
 
-	err = json.Unmarshal(tpl.Bytes(), &config)
+	// start from an empty config such that maps and lists are replaced by the layered values instead of merged into the defaults
+	config = Config{}
+	err = json.Unmarshal(configJSON, &config)
 	if err != nil {
-		return config, fmt.Errorf("could not unmarshal config: %s: %w", err.Error(), errors.InternalServerError)
+		problems = append(problems, fmt.Sprintf("could not unmarshal config: %s", err.Error()))
+	} else {
+		problems = append(problems, validate(config)...)
 	}
 
-	return config, nil
+	if len(problems) > 0 {
+		return config, origins, &InvalidConfigError{Problems: problems}
+	}
+	return config, origins, nil
 }
 
 func injectSecretsFromEnvironment(injectedSecrets string, secrets map[string]string) (map[string]string, error) {
 	if injectedSecrets != "" {
-		for _, splitSecret := range strings.Split(injectedSecrets, ";") {
-			secret := strings.Split(splitSecret, ":")
+		for i, splitSecret := range strings.Split(injectedSecrets, ";") {
+			if strings.TrimSpace(splitSecret) == "" {
+				continue
+			}
+
+			secret := strings.SplitN(splitSecret, ":", 2)
+			if len(secret) != 2 {
+				return nil, fmt.Errorf("injected secret %d must have the form name:base64 value: %w", i+1, errors.InternalServerError)
+			}
 
 			secretName := strings.TrimSpace(secret[0])
 
-			unencodedSecret, err := base64.StdEncoding.DecodeString(secret[1])
+			unencodedSecret, err := base64.StdEncoding.DecodeString(strings.TrimSpace(secret[1]))
 			if err != nil {
 				return nil, fmt.Errorf("could not decode injected secret %s: %s: %w", secretName, err.Error(), errors.InternalServerError)
 			}
@@ -112,16 +164,31 @@ func injectSecretsFromSecretsDir(secretsDirectoryPath string, secrets map[string
 	return secrets, nil
 }
 
-func readEnvSpecificConfig(envConfigFilePath string) (Config, error) {
+// readConfigFile reads a JSON, YAML (.yaml, .yml) or TOML (.toml) file depending on its extension,
+// secret placeholders such as {{ .db_password }} must be quoted strings in YAML and TOML
+func readConfigFile(envConfigFilePath string) (tree, error) {
 	fileBytes, err := ioutil.ReadFile(envConfigFilePath)
 	if err != nil {
-		return Config{}, fmt.Errorf("could not read config file %s: %s: %w", envConfigFilePath, err.Error(), errors.InternalServerError)
+		return nil, fmt.Errorf("could not read config file %s: %s: %w", envConfigFilePath, err.Error(), errors.InternalServerError)
+	}
+
+	var document map[string]interface{}
+	switch strings.ToLower(filepath.Ext(envConfigFilePath)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(fileBytes, &document)
+	case ".toml":
+		err = toml.Unmarshal(fileBytes, &document)
+	default:
+		err = json.Unmarshal(fileBytes, &document)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal config file %s: %s: %w", envConfigFilePath, err.Error(), errors.InternalServerError)
 	}
 
-	envConfig := Config{}
-	err = json.Unmarshal(fileBytes, &envConfig)
+	// a JSON round trip gives YAML and TOML documents the same value types as JSON ones
+	fileLayer, err := toTree(document)
 	if err != nil {
-		return Config{}, fmt.Errorf("could not unmarshal secret file %s: %s: %w", envConfigFilePath, err.Error(), errors.InternalServerError)
+		return nil, fmt.Errorf("could not convert config file %s: %s: %w", envConfigFilePath, err.Error(), errors.InternalServerError)
 	}
-	return envConfig, nil
+	return fileLayer, nil
 }
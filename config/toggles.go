@@ -0,0 +1,109 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// FeatureToggles maps toggle names to their definition, e.g. {"enableNewFeature": true},
+// {"enableNewFeature": {"enabled": false, "percentage": 5}} or a toggle with variants such as
+// {"checkoutButton": {"enabled": true, "variants": [{"name": "blue", "value": "#00f", "weight": 50}, ...], "defaultVariant": "blue"}}
+type FeatureToggles map[string]FeatureToggle
+
+// FeatureToggle is a toggle with optional rules, a toggle without rules can be written as plain true or false.
+// Rules are checked in this order: DenyUsers, AllowUsers, Attributes and Percentage, Enabled applies if none decides.
+type FeatureToggle struct {
+	Enabled bool `json:"enabled"`
+	// Percentage enables the toggle for this share of users, a user keeps the toggle when the percentage is raised
+	Percentage *float64 `json:"percentage,omitempty" validate:"omitempty,min=0,max=100"`
+	// AllowUsers always get the toggle enabled
+	AllowUsers []string `json:"allowUsers,omitempty"`
+	// DenyUsers never get the toggle enabled, even if they are allowed as well
+	DenyUsers []string `json:"denyUsers,omitempty"`
+	// Attributes enable the toggle if an attribute has one of the listed values, request headers are attributes
+	// named by their canonical header name, e.g. {"X-Beta-Tester": ["true"]}
+	Attributes map[string][]string `json:"attributes,omitempty"`
+	// Overridable toggles can be switched per request from the source configured in ToggleOverridesConfig
+	Overridable bool `json:"overridable,omitempty"`
+	// Variants are split among the users the toggle is enabled for by their weight
+	Variants []Variant `json:"variants,omitempty" validate:"unique=Name,dive"`
+	// DefaultVariant is chosen for users the toggle is disabled for and if there is no user to split by
+	DefaultVariant string `json:"defaultVariant,omitempty"`
+	// Owner is who decides when the toggle can be removed, e.g. a team or a person
+	Owner       string `json:"owner,omitempty"`
+	Description string `json:"description,omitempty"`
+	Created     *Date  `json:"created,omitempty"`
+	// Expires is the day from which the toggle is reported as stale, it should be removed from the code by then
+	Expires *Date `json:"expires,omitempty"`
+	// Permanent toggles such as kill switches are meant to stay and never expire
+	Permanent bool `json:"permanent,omitempty"`
+}
+
+// Variant is one value of a multi-variant toggle, Value is any JSON value such as "blue", 3 or {"limit": 10}
+type Variant struct {
+	Name   string          `json:"name" validate:"required"`
+	Value  json.RawMessage `json:"value" validate:"required"`
+	Weight float64         `json:"weight" validate:"min=0"`
+}
+
+// toggleDefinition avoids the recursion into the custom (un)marshalling of FeatureToggle
+type toggleDefinition FeatureToggle
+
+// HasRules tells whether the toggle depends on who is asking
+func (t FeatureToggle) HasRules() bool {
+	return t.Percentage != nil || len(t.AllowUsers) > 0 || len(t.DenyUsers) > 0 || len(t.Attributes) > 0
+}
+
+// HasVariants tells whether the toggle has more values than enabled and disabled
+func (t FeatureToggle) HasVariants() bool {
+	return len(t.Variants) > 0
+}
+
+// FindVariant returns the variant of the given name
+func (t FeatureToggle) FindVariant(name string) (Variant, bool) {
+	for _, variant := range t.Variants {
+		if variant.Name == name {
+			return variant, true
+		}
+	}
+	return Variant{}, false
+}
+
+// IsExpired tells whether the toggle should have been removed at the given time
+func (t FeatureToggle) IsExpired(now time.Time) bool {
+	return !t.Permanent && t.Expires != nil && !now.Before(t.Expires.Time())
+}
+
+// isPlain tells whether the toggle is nothing but enabled or disabled
+func (t FeatureToggle) isPlain() bool {
+	return !t.HasRules() && !t.HasVariants() && !t.Overridable && t.Owner == "" && t.Description == "" &&
+		t.Created == nil && t.Expires == nil && !t.Permanent
+}
+
+// MarshalJSON writes toggles without rules as plain true or false, like they are usually written in config files
+func (t FeatureToggle) MarshalJSON() ([]byte, error) {
+	if t.isPlain() {
+		return json.Marshal(t.Enabled)
+	}
+	return json.Marshal(toggleDefinition(t))
+}
+
+func (t *FeatureToggle) UnmarshalJSON(data []byte) error {
+	var enabled bool
+	if json.Unmarshal(data, &enabled) == nil {
+		*t = FeatureToggle{Enabled: enabled}
+		return nil
+	}
+
+	var definition toggleDefinition
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// a misspelt rule would otherwise enable or disable the toggle for everyone
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&definition)
+	if err != nil {
+		return err
+	}
+	*t = FeatureToggle(definition)
+	return nil
+}
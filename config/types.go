@@ -1,18 +1,222 @@
 package config
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 type Config struct {
-	Name           string            `json:"name"`
+	// Environment names the deployment, e.g. dev, it is informational only
+	Environment    string            `json:"environment"`
+	Name           string            `json:"name" validate:"required"`
+	Server         ServerConfig      `json:"server"`
 	Persistence    PersistenceConfig `json:"persistence"`
-	FeatureToggles FeatureToggles    `json:"featuretoggles"`
+	FeatureToggles FeatureToggles    `json:"featuretoggles" reload:"true" validate:"dive"`
+	// ToggleOverrides controls how requests may override feature toggles
+	ToggleOverrides ToggleOverridesConfig `json:"toggleOverrides" reload:"true"`
+	ToggleStore     ToggleStoreConfig     `json:"toggleStore"`
+	ToggleProvider  ToggleProviderConfig  `json:"toggleProvider"`
+	Idempotency     IdempotencyConfig     `json:"idempotency"`
+	Validation      ValidationConfig      `json:"validation"`
+	Metrics         MetricsConfig         `json:"metrics"`
+	Tracing         TracingConfig         `json:"tracing"`
+	Logging         LoggingConfig         `json:"logging" reload:"true"`
+	Reload          ReloadConfig          `json:"reload"`
+	Secrets         SecretsConfig         `json:"secrets"`
 }
 
-type FeatureToggles map[string]bool
+type ServerConfig struct {
+	// Address is the listen address, use port 0 to bind to a free port, e.g. "localhost:0"
+	Address           string   `json:"address" validate:"required"`
+	ReadTimeout       Duration `json:"readTimeout" validate:"min=0"`
+	ReadHeaderTimeout Duration `json:"readHeaderTimeout" validate:"min=0"`
+	WriteTimeout      Duration `json:"writeTimeout" validate:"min=0"`
+	IdleTimeout       Duration `json:"idleTimeout" validate:"min=0"`
+	MaxHeaderBytes    int      `json:"maxHeaderBytes" validate:"min=0"`
+	// MaxBodyBytes rejects larger request bodies with 413, there is no limit if it is 0
+	MaxBodyBytes int64 `json:"maxBodyBytes" validate:"min=0"`
+	// ShutdownGracePeriod is how long running requests may take to finish on shutdown
+	ShutdownGracePeriod Duration `json:"shutdownGracePeriod" validate:"min=0"`
+}
 
 type PersistenceConfig struct {
-	DbName     string `json:"dbName"`
-	DbHost     string `json:"dbHost"`
-	DbPort     int    `json:"dbPort"`
-	DbUsername string `json:"dbUsername"`
-	DbPassword string `json:"dbPassword"`
+	DbName     string `json:"dbName" validate:"required"`
+	DbHost     string `json:"dbHost" validate:"required"`
+	DbPort     int    `json:"dbPort" validate:"min=1,max=65535"`
+	DbUsername string `json:"dbUsername" validate:"required"`
+	DbPassword string `json:"dbPassword" secret:"true"`
 	SslEnabled bool   `json:"sslEnabled"`
+	// MaxOpenConns limits the connections of the pool, 0 means unlimited, it should stay below max_connections
+	// of the database divided by the number of instances of the service
+	MaxOpenConns int `json:"maxOpenConns" validate:"min=0"`
+	// MaxIdleConns are kept open for the next queries, it is capped at MaxOpenConns, 0 keeps none
+	MaxIdleConns int `json:"maxIdleConns" validate:"min=0"`
+	// ConnMaxLifetime closes connections after this time, e.g. to follow a database failover, 0 keeps them forever
+	ConnMaxLifetime Duration `json:"connMaxLifetime" validate:"min=0"`
+	// ConnMaxIdleTime closes connections that were not used for this time, 0 keeps them until ConnMaxLifetime
+	ConnMaxIdleTime Duration `json:"connMaxIdleTime" validate:"min=0"`
+}
+
+type IdempotencyConfig struct {
+	// KeyTTL is how long a stored response is replayed for requests with the same Idempotency-Key
+	KeyTTL Duration `json:"keyTtl" validate:"gt=0"`
+	// PurgeInterval is how often the keys older than KeyTTL are deleted, 0 never deletes them
+	PurgeInterval Duration `json:"purgeInterval" validate:"min=0"`
+}
+
+type ValidationConfig struct {
+	// DefaultPhoneRegion is the ISO 3166 country code used for phone numbers without a country calling code
+	DefaultPhoneRegion string `json:"defaultPhoneRegion" validate:"required,len=2"`
+}
+
+type MetricsConfig struct {
+	// AdminPort serves /metrics on a separate port, if empty /metrics is served next to the API
+	AdminPort string `json:"adminPort" validate:"omitempty,numeric"`
+}
+
+type TracingConfig struct {
+	// Exporter is one of "otlp", "stdout" or "file", spans are not exported if it is empty
+	Exporter string `json:"exporter" validate:"omitempty,oneof=otlp stdout file"`
+	// OtlpEndpoint is the host:port of the OTLP/HTTP collector, defaults to localhost:4318
+	OtlpEndpoint string `json:"otlpEndpoint"`
+	OtlpInsecure bool   `json:"otlpInsecure"`
+	// FilePath is the file the "file" exporter appends spans to
+	FilePath string `json:"filePath" validate:"required_if=Exporter file"`
+	// SampleRatio is the fraction of new traces that are sampled, traces started by callers follow their decision
+	SampleRatio float64 `json:"sampleRatio" validate:"min=0,max=1"`
+}
+
+type LoggingConfig struct {
+	// Format is one of "console", "json" or "logfmt"
+	Format string `json:"format" validate:"oneof=console json logfmt"`
+	// Level is the minimum level logged, e.g. "info"
+	Level string `json:"level" validate:"oneof=debug info notice warn error panic alert fatal"`
+	// Packages overrides the level per package, e.g. {"featuretoggles": "warn"}
+	Packages map[string]string `json:"packages" validate:"dive,oneof=debug info notice warn error panic alert fatal"`
+	// RedactedFields are log fields whose values are never logged, email addresses and phone numbers are redacted everywhere
+	RedactedFields []string `json:"redactedFields"`
+}
+
+// ReloadConfig controls reloading the sections of Config tagged reload:"true" while the service runs,
+// all other changes are logged and require a restart
+type ReloadConfig struct {
+	// Interval is how often the config files and secrets are checked for changes, 0 only reloads on SIGHUP
+	Interval Duration `json:"interval" validate:"min=0"`
+}
+
+type ToggleOverridesConfig struct {
+	// Source is where requests may override toggles marked overridable: "query" parameters named like the toggle,
+	// the signed "header" X-Feature-Overrides or "disabled"
+	Source string `json:"source" validate:"oneof=query header disabled"`
+	// SigningKey verifies the HMAC-SHA256 signature of the X-Feature-Overrides header
+	SigningKey string `json:"signingKey" secret:"true" validate:"required_if=Source header"`
+}
+
+// ToggleStoreConfig enables toggles saved in the database, they replace the toggles of the config files of the same name
+type ToggleStoreConfig struct {
+	Enabled bool `json:"enabled"`
+	// PollInterval is how often the database is checked for toggles changed by other instances
+	PollInterval Duration `json:"pollInterval" validate:"min=0"`
+	// AdminTokens maps the operators of the /admin/toggles API to their bearer token, the operator of the token is
+	// recorded as the author of a change. The API is only served if there is a token and only reports stale toggles
+	// unless the store is enabled
+	AdminTokens map[string]string `json:"adminTokens" secret:"true" validate:"dive,keys,required,max=255,endkeys,required"`
+}
+
+// ToggleProviderConfig chooses where feature toggles are resolved: "config" for the toggles of the config files
+// and the toggle store, "flagd-file" for a flagd flag definition file or "flagd-http" for the remote evaluation API of flagd.
+// The flagd providers do not support featuretoggles, the toggle store or toggle overrides
+type ToggleProviderConfig struct {
+	Type string `json:"type" validate:"oneof=config flagd-file flagd-http"`
+	// Path of the flagd flag definition file, it is read again on SIGHUP
+	Path string `json:"path" validate:"required_if=Type flagd-file"`
+	// Address of flagd's remote evaluation API, e.g. "http://flagd:8016"
+	Address string   `json:"address" validate:"required_if=Type flagd-http,omitempty,url"`
+	Timeout Duration `json:"timeout" validate:"min=0"`
+}
+
+// SecretsConfig configures the secret providers for references such as {{ secret "vault:db/password" }},
+// the file provider reads the secrets directory and the env provider the injected secrets
+type SecretsConfig struct {
+	Vault         VaultConfig         `json:"vault"`
+	EncryptedFile EncryptedFileConfig `json:"encryptedFile"`
+}
+
+type VaultConfig struct {
+	// Address of the Vault server, e.g. "https://vault:8200", the vault provider is only available if it is set
+	Address string `json:"address" validate:"omitempty,url"`
+	// Mount is the path of the KV version 2 secrets engine, defaults to "secret"
+	Mount string `json:"mount"`
+	// TokenEnv is the environment variable holding the Vault token, defaults to VAULT_TOKEN
+	TokenEnv string `json:"tokenEnv"`
+	// Timeout of each request to Vault, defaults to 5s
+	Timeout Duration `json:"timeout" validate:"min=0"`
+}
+
+type EncryptedFileConfig struct {
+	// Path of the file created with EncryptSecrets, the encrypted provider is only available if it is set
+	Path string `json:"path"`
+	// KeyEnv is the environment variable holding the base64 encoded 256 bit key, defaults to SECRETS_KEY
+	KeyEnv string `json:"keyEnv"`
+}
+
+// Duration is a time.Duration that is written as a string such as "24h" in config files
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(bytes []byte) error {
+	var value string
+	err := json.Unmarshal(bytes, &value)
+	if err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// dateLayout is how dates are written in config files
+const dateLayout = "2006-01-02"
+
+// Date is a day in UTC that is written as a string such as "2024-01-31" in config files
+type Date time.Time
+
+// NewDate returns the day of t in UTC
+func NewDate(t time.Time) Date {
+	year, month, day := t.UTC().Date()
+	return Date(time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+}
+
+// Time is the start of the day
+func (d Date) Time() time.Time {
+	return time.Time(d)
+}
+
+func (d Date) String() string {
+	return time.Time(d).Format(dateLayout)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(bytes []byte) error {
+	var value string
+	err := json.Unmarshal(bytes, &value)
+	if err != nil {
+		return fmt.Errorf("date must be a string such as \"2024-01-31\": %w", err)
+	}
+	parsed, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return fmt.Errorf("date must have the form YYYY-MM-DD: %w", err)
+	}
+	*d = Date(parsed)
+	return nil
 }
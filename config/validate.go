@@ -0,0 +1,148 @@
+package config
+
+import (
+	stderrors "errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/jenpaff/golang-microservices/errors"
+	"reflect"
+	"strings"
+)
+
+// InvalidConfigError lists every problem found while building the config
+type InvalidConfigError struct {
+	Problems []string
+}
+
+func (e *InvalidConfigError) Error() string {
+	return fmt.Sprintf("invalid config:\n  - %s", strings.Join(e.Problems, "\n  - "))
+}
+
+func (e *InvalidConfigError) Unwrap() error {
+	return errors.InternalServerError
+}
+
+var configValidator = newConfigValidator()
+
+func newConfigValidator() *validator.Validate {
+	v := validator.New()
+	// report config paths such as persistence.dbHost instead of Go field names
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return jsonName(field)
+	})
+	v.RegisterStructValidation(validateToggle, FeatureToggle{})
+	v.RegisterStructValidation(validateToggleProvider, Config{})
+	return v
+}
+
+// validateToggleProvider rejects settings the flagd providers would ignore: flagd resolves every flag itself, so
+// the toggles of the config files, the toggle store and request overrides never apply
+func validateToggleProvider(sl validator.StructLevel) {
+	config := sl.Current().Interface().(Config)
+	if config.ToggleProvider.Type != "flagd-file" && config.ToggleProvider.Type != "flagd-http" {
+		return
+	}
+	if len(config.FeatureToggles) > 0 {
+		sl.ReportError(config.FeatureToggles, "featuretoggles", "FeatureToggles", "flagd", config.ToggleProvider.Type)
+	}
+	if config.ToggleStore.Enabled {
+		sl.ReportError(config.ToggleStore.Enabled, "toggleStore.enabled", "ToggleStore.Enabled", "flagd", config.ToggleProvider.Type)
+	}
+	if config.ToggleOverrides.Source != "disabled" {
+		sl.ReportError(config.ToggleOverrides.Source, "toggleOverrides.source", "ToggleOverrides.Source", "flagd", config.ToggleProvider.Type)
+	}
+}
+
+func validateToggle(sl validator.StructLevel) {
+	toggle := sl.Current().Interface().(FeatureToggle)
+	if _, ok := toggle.FindVariant(toggle.DefaultVariant); toggle.DefaultVariant != "" && !ok {
+		sl.ReportError(toggle.DefaultVariant, "defaultVariant", "DefaultVariant", "variant", "")
+	}
+	if toggle.Permanent && toggle.Expires != nil {
+		sl.ReportError(toggle.Expires.String(), "expires", "Expires", "permanent", "")
+	}
+	if toggle.Created != nil && toggle.Expires != nil && toggle.Expires.Time().Before(toggle.Created.Time()) {
+		sl.ReportError(toggle.Expires.String(), "expires", "Expires", "aftercreated", "")
+	}
+}
+
+// validate checks the validate tags of Config
+func validate(config Config) []string {
+	err := configValidator.Struct(config)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !stderrors.As(err, &validationErrors) {
+		return []string{err.Error()}
+	}
+
+	problems := make([]string, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		path := strings.TrimPrefix(fieldError.Namespace(), configType.Name()+".")
+		problems = append(problems, fmt.Sprintf("%s: %s", path, describe(fieldError)))
+	}
+	return problems
+}
+
+// ValidateToggle checks a toggle changed at runtime like the toggles of the config files, the error is a
+// validation error listing every invalid field
+func ValidateToggle(toggle FeatureToggle) error {
+	err := configValidator.Struct(toggle)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !stderrors.As(err, &validationErrors) {
+		return fmt.Errorf("could not validate toggle: %s: %w", err.Error(), errors.InvalidInput)
+	}
+
+	fieldErrors := make([]errors.FieldError, len(validationErrors))
+	for i, fieldError := range validationErrors {
+		path := strings.TrimPrefix(fieldError.Namespace(), reflect.TypeOf(toggle).Name()+".")
+		fieldErrors[i] = errors.FieldError{
+			Field:   path,
+			Tag:     fieldError.Tag(),
+			Param:   fieldError.Param(),
+			Message: fmt.Sprintf("%s: %s", path, describe(fieldError)),
+		}
+	}
+	return errors.NewValidationError(fieldErrors)
+}
+
+func describe(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "required_if":
+		return fmt.Sprintf("is required if %s", fieldError.Param())
+	case "min":
+		return fmt.Sprintf("must be at least %s", fieldError.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s", fieldError.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fieldError.Param())
+	case "len":
+		return fmt.Sprintf("must have a length of %s", fieldError.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of %s but is %v", strings.ReplaceAll(fieldError.Param(), " ", ", "), fieldError.Value())
+	case "numeric":
+		return "must be a number"
+	case "url":
+		return "must be a URL"
+	case "unique":
+		return fmt.Sprintf("must not repeat a %s", strings.ToLower(fieldError.Param()))
+	case "variant":
+		return fmt.Sprintf("must name one of the variants but is %v", fieldError.Value())
+	case "permanent":
+		return "must not be set for permanent toggles"
+	case "flagd":
+		return fmt.Sprintf("must not be set with the toggle provider %s, flagd resolves every flag itself", fieldError.Param())
+	case "aftercreated":
+		return fmt.Sprintf("must not be before the created date but is %v", fieldError.Value())
+	default:
+		return fmt.Sprintf("failed the %s check", fieldError.Tag())
+	}
+}
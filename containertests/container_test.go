@@ -69,7 +69,7 @@ var _ = Describe("Golang Service", func() {
 					user, err := json.Marshal(&api.UserCreationRequest{
 						UserName:    "jenpaff",
 						Email:       "jenpaff@test.com",
-						PhoneNumber: "012345678",
+						PhoneNumber: "030 12345678",
 					})
 					Expect(err).ToNot(HaveOccurred())
 					response := g.Post("/users?enableNewFeature=false", map[string]string{}, bytes.NewReader(user))
@@ -95,7 +95,7 @@ var _ = Describe("Golang Service", func() {
 					user, err := json.Marshal(&api.UserCreationRequest{
 						UserName:    "jenpaff",
 						Email:       "jenpaff@test.com",
-						PhoneNumber: "012345678",
+						PhoneNumber: "030 12345678",
 					})
 					Expect(err).ToNot(HaveOccurred())
 					response := g.Post("/users", map[string]string{}, bytes.NewReader(user))
@@ -24,6 +24,8 @@ func CleanUpDatabase() error {
 		return err
 	}
 
+	defer db.Close()
+
 	_, err = db.Exec("delete from users")
 	if err != nil {
 		return err
@@ -1,11 +1,14 @@
 package consumer
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/go-playground/log"
 	"github.com/jenpaff/golang-microservices/common"
 	"github.com/jenpaff/golang-microservices/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"io/ioutil"
 	"net/http"
 )
@@ -14,12 +17,14 @@ type UserClient struct {
 	BaseURL string
 }
 
-func (c *UserClient) GetUser(userName string) (*common.User, error) {
-	req, err := http.NewRequest(http.MethodGet, c.BaseURL+"/users/"+userName, nil)
+// GetUser continues the trace in ctx by sending its W3C traceparent header
+func (c *UserClient) GetUser(ctx context.Context, userName string) (*common.User, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/users/"+userName, nil)
 	if err != nil {
 		return nil, fmt.Errorf("could not create request due to error : %w", err)
 	}
 	req.Header.Add("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	response, err := http.DefaultClient.Do(req)
 	if err != nil {
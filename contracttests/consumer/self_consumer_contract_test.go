@@ -3,6 +3,7 @@
 package consumer_test
 
 import (
+	"context"
 	"fmt"
 	"github.com/jenpaff/golang-microservices/contracttests/consumer"
 	. "github.com/onsi/ginkgo"
@@ -47,7 +48,7 @@ var _ = Describe("SelfConsumerContract", func() {
 			BaseURL: fmt.Sprintf("http://localhost:%d", pact.Server.Port),
 		}
 		var test = func() error {
-			returnedUser, err := userClient.GetUser("user1")
+			returnedUser, err := userClient.GetUser(context.Background(), "user1")
 			Expect(returnedUser).To(BeNil())
 			Expect(err).To(HaveOccurred())
 			return nil
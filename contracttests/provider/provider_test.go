@@ -7,6 +7,8 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/jenpaff/golang-microservices/api"
 	"github.com/jenpaff/golang-microservices/config"
+	"github.com/jenpaff/golang-microservices/featuretoggles"
+	"github.com/jenpaff/golang-microservices/metrics"
 	"github.com/jenpaff/golang-microservices/users"
 	"github.com/jenpaff/golang-microservices/validation"
 	"github.com/pact-foundation/pact-go/dsl"
@@ -104,8 +106,10 @@ func setup(t *testing.T) (handler http.Handler, finalizer func()) {
 	fmt.Println("Setting up")
 	mockController := gomock.NewController(t)
 	userServiceMock := users.NewMockService(mockController)
-	validator, _ := validation.NewValidate()
-	controller := api.NewController(config.Config{}, userServiceMock, validator)
+	validator, _ := validation.NewValidate("DE")
+	configStore := config.NewStore(config.Config{})
+	toggles := featuretoggles.NewClient(featuretoggles.NewConfigProvider(configStore, nil), nil)
+	controller := api.NewController(configStore, userServiceMock, validator, nil, nil, toggles, metrics.NewMetrics())
 	router := api.NewRouter(controller)
 
 	return router, func() {
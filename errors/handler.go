@@ -1,27 +1,67 @@
 package errors
 
 import (
+	"context"
+	"errors"
 	"github.com/emicklei/go-restful/v3"
 	"github.com/go-playground/log"
+	"github.com/jenpaff/golang-microservices/requestid"
 )
 
+// ErrorIDAttribute is the request attribute holding the error id of a failed request, e.g. for metrics
+const ErrorIDAttribute = "error_id"
+
 type ErrorRouteFunction func(req *restful.Request, resp *restful.Response) error
 
-func logAndReturnHttpError(err error) (int, ErrorResponse) {
-	log.Error(err.Error())
+func logAndReturnHttpError(ctx context.Context, err error) (int, ErrorResponse) {
+	log.GetContext(ctx).Error(err.Error())
 
 	httpError := httpErrorFromError(err)
-	return httpError.toErrorResponse(err.Error())
+	status, errorResponse := httpError.toErrorResponse(err.Error())
+
+	var conflictError *ConflictError
+	if errors.As(err, &conflictError) {
+		errorResponse.Field = conflictError.Field
+	}
+
+	var validationError *ValidationError
+	if errors.As(err, &validationError) {
+		errorResponse.Errors = validationError.Errors
+	}
+
+	errorResponse.RequestID = requestid.FromContext(ctx)
+	return status, errorResponse
 }
 
 func ErrorHandler(errorRouteFunction ErrorRouteFunction) restful.RouteFunction {
 	return func(req *restful.Request, res *restful.Response) {
 		err := errorRouteFunction(req, res)
 		if err != nil {
-			err2 := res.WriteHeaderAndEntity(logAndReturnHttpError(err))
-			if err2 != nil {
-				log.Errorf("could not write error response: %s", err.Error())
-			}
+			WriteError(req, res, err)
 		}
 	}
 }
+
+// WriteError writes the error response matching err, it is used by filters which cannot return an error.
+// Clients accepting application/problem+json receive RFC 7807 problem details, all others the ErrorResponse.
+func WriteError(req *restful.Request, res *restful.Response, err error) {
+	ctx := context.Background()
+	if req != nil {
+		ctx = req.Request.Context()
+	}
+
+	status, errorResponse := logAndReturnHttpError(ctx, err)
+	if req != nil {
+		req.SetAttribute(ErrorIDAttribute, errorResponse.ErrorID)
+	}
+
+	var err2 error
+	if acceptsProblemJSON(req) {
+		err2 = res.WriteHeaderAndJson(status, newProblemDetails(req, status, errorResponse), MIME_PROBLEM_JSON)
+	} else {
+		err2 = res.WriteHeaderAndEntity(status, errorResponse)
+	}
+	if err2 != nil {
+		log.GetContext(ctx).Errorf("could not write error response: %s", err.Error())
+	}
+}
@@ -3,9 +3,11 @@
 package errors_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/emicklei/go-restful/v3"
 	"github.com/jenpaff/golang-microservices/errors"
+	"github.com/jenpaff/golang-microservices/requestid"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
@@ -31,5 +33,111 @@ var _ = Describe("ErrorHandler", func() {
 	},
 		Entry("returns expected http status given a known error", fmt.Errorf("error happened: %w", errors.UserNotFound), http.StatusNotFound),
 		Entry("returns internal server error given a unknown error", fmt.Errorf("error happened"), http.StatusInternalServerError),
+		Entry("returns conflict given a conflict error", fmt.Errorf("error happened: %w", errors.NewConflictError("email")), http.StatusConflict),
 	)
+
+	It("returns the colliding field given a conflict error", func() {
+		rr := httptest.NewRecorder()
+
+		errorHandler := errors.ErrorHandler(func(_ *restful.Request, _ *restful.Response) error {
+			return fmt.Errorf("error happened: %w", errors.NewConflictError("email"))
+		})
+
+		res := restful.NewResponse(rr)
+		res.SetRequestAccepts(restful.MIME_JSON)
+
+		errorHandler(nil, res)
+
+		var errorResponse errors.ErrorResponse
+		err := json.Unmarshal(rr.Body.Bytes(), &errorResponse)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(errorResponse.ErrorID).To(Equal(errors.Conflict.Error()))
+		Expect(errorResponse.Field).To(Equal("email"))
+	})
+
+	It("returns every invalid field given a validation error", func() {
+		rr := httptest.NewRecorder()
+
+		fieldErrors := []errors.FieldError{
+			{Field: "name", Tag: "required", Message: "The field name is required."},
+			{Field: "email", Tag: "required", Message: "The field email is required."},
+		}
+		errorHandler := errors.ErrorHandler(func(_ *restful.Request, _ *restful.Response) error {
+			return fmt.Errorf("error happened: %w", errors.NewValidationError(fieldErrors))
+		})
+
+		res := restful.NewResponse(rr)
+		res.SetRequestAccepts(restful.MIME_JSON)
+
+		errorHandler(nil, res)
+
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		var errorResponse errors.ErrorResponse
+		err := json.Unmarshal(rr.Body.Bytes(), &errorResponse)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(errorResponse.ErrorID).To(Equal(errors.InvalidInput.Error()))
+		Expect(errorResponse.Errors).To(Equal(fieldErrors))
+	})
+
+	Context("content negotiation", func() {
+
+		handle := func(accept string) *httptest.ResponseRecorder {
+			rr := httptest.NewRecorder()
+
+			errorHandler := errors.ErrorHandler(func(_ *restful.Request, _ *restful.Response) error {
+				return fmt.Errorf("user test does not exist: %w", errors.UserNotFound)
+			})
+
+			httpRequest := httptest.NewRequest(http.MethodGet, "/users/test", nil)
+			httpRequest.Header.Set("Accept", accept)
+			httpRequest = httpRequest.WithContext(requestid.NewContext(httpRequest.Context(), "request-1"))
+			res := restful.NewResponse(rr)
+			res.SetRequestAccepts(restful.MIME_JSON)
+
+			errorHandler(restful.NewRequest(httpRequest), res)
+			return rr
+		}
+
+		It("returns problem details to clients accepting application/problem+json", func() {
+			rr := handle("application/json, application/problem+json")
+
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+			Expect(rr.Header().Get("Content-Type")).To(Equal(errors.MIME_PROBLEM_JSON))
+			var problem errors.ProblemDetails
+			err := json.Unmarshal(rr.Body.Bytes(), &problem)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(problem.Type).To(Equal("/problems/user-not-found"))
+			Expect(problem.Title).To(Equal("User not found"))
+			Expect(problem.Status).To(Equal(http.StatusNotFound))
+			Expect(problem.Detail).To(ContainSubstring("user test does not exist"))
+			Expect(problem.Instance).To(Equal("/users/test"))
+			Expect(problem.ErrorID).To(Equal(errors.UserNotFound.Error()))
+			Expect(problem.RequestID).To(Equal("request-1"))
+		})
+
+		It("returns the error response to all other clients", func() {
+			rr := handle(restful.MIME_JSON)
+
+			Expect(rr.Code).To(Equal(http.StatusNotFound))
+			Expect(rr.Header().Get("Content-Type")).To(Equal(restful.MIME_JSON))
+			var errorResponse errors.ErrorResponse
+			err := json.Unmarshal(rr.Body.Bytes(), &errorResponse)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(errorResponse.ErrorID).To(Equal(errors.UserNotFound.Error()))
+			Expect(errorResponse.RequestID).To(Equal("request-1"))
+		})
+
+		DescribeTable("respects the quality of the media ranges",
+			func(accept string, expectedContentType string) {
+				rr := handle(accept)
+
+				Expect(rr.Header().Get("Content-Type")).To(Equal(expectedContentType))
+			},
+			Entry("refuses problem details with q=0", "application/problem+json;q=0, application/json", restful.MIME_JSON),
+			Entry("prefers the error response with a higher quality", "application/problem+json; q=0.5, application/json", restful.MIME_JSON),
+			Entry("prefers problem details with a higher quality", "application/problem+json, application/json;q=0.9", errors.MIME_PROBLEM_JSON),
+			Entry("ignores invalid q-values", "application/problem+json;q=abc, application/json", restful.MIME_JSON),
+			Entry("does not match other media types containing the name", "application/problem+json-seq", restful.MIME_JSON),
+		)
+	})
 })
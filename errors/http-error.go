@@ -2,7 +2,9 @@ package errors
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 )
 
 type httpError struct {
@@ -11,8 +13,29 @@ type httpError struct {
 }
 
 type ErrorResponse struct {
-	ErrorID      string `json:"error_id"`
-	ErrorMessage string `json:"error_message"`
+	ErrorID      string       `json:"error_id"`
+	ErrorMessage string       `json:"error_message"`
+	Field        string       `json:"field,omitempty"`
+	Errors       []FieldError `json:"errors,omitempty"`
+	RequestID    string       `json:"request_id,omitempty"`
+}
+
+// FieldError describes why a single field of a request is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationError carries every invalid field of a request, it is an InvalidInput error
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// ConflictError is returned when a value has to be unique but is already taken, Field names the colliding field
+type ConflictError struct {
+	Field string
 }
 
 var httpErrors = make(map[error]*httpError)
@@ -23,6 +46,13 @@ var UserNotFound = newHttpError("USER_NOT_FOUND", http.StatusNotFound)
 var UserClientError = newHttpError("USER_CLIENT_ERROR", http.StatusInternalServerError)
 var DatabaseError = newHttpError("DATABASE_ERROR", http.StatusInternalServerError)
 var InvalidInput = newHttpError("INVALID_INPUT", http.StatusBadRequest)
+var Conflict = newHttpError("CONFLICT", http.StatusConflict)
+var IdempotencyKeyReused = newHttpError("IDEMPOTENCY_KEY_REUSED", http.StatusUnprocessableEntity)
+var IdempotentRequestInProgress = newHttpError("IDEMPOTENT_REQUEST_IN_PROGRESS", http.StatusConflict)
+var RequestTooLarge = newHttpError("REQUEST_TOO_LARGE", http.StatusRequestEntityTooLarge)
+var InvalidToggleOverride = newHttpError("INVALID_TOGGLE_OVERRIDE", http.StatusBadRequest)
+var ToggleNotFound = newHttpError("TOGGLE_NOT_FOUND", http.StatusNotFound)
+var Unauthorized = newHttpError("UNAUTHORIZED", http.StatusUnauthorized)
 
 func newHttpError(errorID string, status int) *httpError {
 	error := &httpError{
@@ -37,6 +67,34 @@ func (e *httpError) Error() string {
 	return e.error
 }
 
+func NewConflictError(field string) error {
+	return &ConflictError{Field: field}
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s is already taken", e.Field)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return Conflict
+}
+
+func NewValidationError(fieldErrors []FieldError) error {
+	return &ValidationError{Errors: fieldErrors}
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldError := range e.Errors {
+		messages[i] = fieldError.Message
+	}
+	return strings.Join(messages, ";")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return InvalidInput
+}
+
 func (e *httpError) toErrorResponse(details string) (int, ErrorResponse) {
 	errorResponse := ErrorResponse{
 		ErrorID:      e.error,
@@ -0,0 +1,85 @@
+package errors
+
+import (
+	"github.com/emicklei/go-restful/v3"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// MIME_PROBLEM_JSON is the media type of RFC 7807 problem details, clients opt in to it with their Accept header
+const MIME_PROBLEM_JSON = "application/problem+json"
+
+// ProblemDetails is the RFC 7807 representation of an ErrorResponse
+type ProblemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// extension members
+	ErrorID   string       `json:"error_id"`
+	Field     string       `json:"field,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// acceptsProblemJSON tells whether the client listed application/problem+json in its Accept header
+// with a quality above 0 and not below the quality of application/json
+func acceptsProblemJSON(req *restful.Request) bool {
+	if req == nil {
+		return false
+	}
+	qualities := acceptedQualities(req.HeaderParameter("Accept"))
+	problemQuality, ok := qualities[MIME_PROBLEM_JSON]
+	return ok && problemQuality > 0 && problemQuality >= qualities[restful.MIME_JSON]
+}
+
+// acceptedQualities maps the media ranges of an Accept header to their q-value, the default q-value is 1
+func acceptedQualities(accept string) map[string]float64 {
+	qualities := make(map[string]float64)
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+		qualities[mediaType] = quality
+	}
+	return qualities
+}
+
+func newProblemDetails(req *restful.Request, status int, errorResponse ErrorResponse) ProblemDetails {
+	return ProblemDetails{
+		Type:      problemType(errorResponse.ErrorID),
+		Title:     problemTitle(errorResponse.ErrorID),
+		Status:    status,
+		Detail:    errorResponse.ErrorMessage,
+		Instance:  req.Request.URL.Path,
+		ErrorID:   errorResponse.ErrorID,
+		Field:     errorResponse.Field,
+		RequestID: errorResponse.RequestID,
+		Errors:    errorResponse.Errors,
+	}
+}
+
+// problemType turns an error id such as USER_NOT_FOUND into the relative type URI /problems/user-not-found
+func problemType(errorID string) string {
+	return "/problems/" + strings.ReplaceAll(strings.ToLower(errorID), "_", "-")
+}
+
+// problemTitle turns an error id such as USER_NOT_FOUND into the title "User not found"
+func problemTitle(errorID string) string {
+	title := strings.ReplaceAll(strings.ToLower(errorID), "_", " ")
+	if title == "" {
+		return title
+	}
+	return strings.ToUpper(title[:1]) + title[1:]
+}
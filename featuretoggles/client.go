@@ -0,0 +1,38 @@
+//go:generate mockgen -destination=client_mock.go -package=featuretoggles -self_package=github.com/jenpaff/golang-microservices/featuretoggles github.com/jenpaff/golang-microservices/featuretoggles Client
+
+package featuretoggles
+
+import (
+	"github.com/emicklei/go-restful/v3"
+)
+
+// Client evaluates flags with a provider, it is created once and shared by all requests
+type Client interface {
+	// ForRequest evaluates the flags of a request, it fails with an InvalidToggleOverride error
+	// if the request overrides toggles in a way that is not allowed
+	ForRequest(httpRequest *restful.Request) (FeatureToggles, error)
+}
+
+type client struct {
+	provider  Provider
+	exposures ExposureSink
+	hooks     []Hook
+}
+
+// NewClient records exposures to variants in the sink if it is not nil, the hooks are called
+// around every evaluation in the given order
+func NewClient(provider Provider, exposures ExposureSink, hooks ...Hook) Client {
+	return &client{provider: provider, exposures: exposures, hooks: hooks}
+}
+
+func (c *client) ForRequest(httpRequest *restful.Request) (FeatureToggles, error) {
+	ctx := httpRequest.Request.Context()
+	if requestProvider, ok := c.provider.(RequestProvider); ok {
+		var err error
+		ctx, err = requestProvider.WithRequest(ctx, httpRequest)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &featureToggles{client: c, ctx: ctx, exposed: make(map[string]bool)}, nil
+}
@@ -0,0 +1,49 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/jenpaff/golang-microservices/featuretoggles (interfaces: Client)
+
+// Package featuretoggles is a generated GoMock package.
+package featuretoggles
+
+import (
+	v3 "github.com/emicklei/go-restful/v3"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+)
+
+// MockClient is a mock of Client interface
+type MockClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockClientMockRecorder
+}
+
+// MockClientMockRecorder is the mock recorder for MockClient
+type MockClientMockRecorder struct {
+	mock *MockClient
+}
+
+// NewMockClient creates a new mock instance
+func NewMockClient(ctrl *gomock.Controller) *MockClient {
+	mock := &MockClient{ctrl: ctrl}
+	mock.recorder = &MockClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockClient) EXPECT() *MockClientMockRecorder {
+	return m.recorder
+}
+
+// ForRequest mocks base method
+func (m *MockClient) ForRequest(arg0 *v3.Request) (FeatureToggles, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForRequest", arg0)
+	ret0, _ := ret[0].(FeatureToggles)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ForRequest indicates an expected call of ForRequest
+func (mr *MockClientMockRecorder) ForRequest(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForRequest", reflect.TypeOf((*MockClient)(nil).ForRequest), arg0)
+}
@@ -0,0 +1,163 @@
+//+build unit
+
+package featuretoggles
+
+import (
+	"context"
+	"errors"
+	"github.com/jenpaff/golang-microservices/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+
+	var provider *InMemoryProvider
+
+	BeforeEach(func() {
+		provider = NewInMemoryProvider(map[string]InMemoryFlag{
+			"enabled": {Value: true, Variant: "on"},
+			"color":   {Value: "green", Variant: "green"},
+		})
+	})
+
+	forRequest := func(client Client) FeatureToggles {
+		ft, err := client.ForRequest(createFakeRequest(""))
+		Expect(err).ToNot(HaveOccurred())
+		return ft
+	}
+
+	It("should tell how a flag was evaluated", func() {
+		ft := forRequest(NewClient(provider, nil))
+
+		enabled := false
+		Expect(ft.Details(EvaluationContext{}, "enabled", &enabled)).To(Equal(EvaluationDetails{
+			FlagKey: "enabled",
+			Value:   true,
+			Variant: "on",
+			Reason:  ReasonStatic,
+		}))
+		Expect(enabled).To(BeTrue())
+	})
+
+	It("should return the default value with an error code if the flag cannot be evaluated", func() {
+		ft := forRequest(NewClient(provider, nil))
+
+		count := 3
+		details := ft.Details(EvaluationContext{}, "color", &count)
+		Expect(details.Value).To(Equal(3))
+		Expect(details.Reason).To(Equal(ReasonError))
+		Expect(details.ErrorCode).To(Equal(ErrorTypeMismatch))
+		Expect(count).To(Equal(3))
+
+		details = ft.Details(EvaluationContext{}, "unknown", &count)
+		Expect(details.ErrorCode).To(Equal(ErrorFlagNotFound))
+		Expect(ft.StringValue(EvaluationContext{}, "unknown", "default")).To(Equal("default"))
+	})
+
+	It("should call the hooks around every evaluation", func() {
+		var calls []string
+		recordingHook := func(name string) Hook {
+			return &testHook{
+				before: func(hookCtx HookContext) (*EvaluationContext, error) {
+					calls = append(calls, name+" before")
+					return nil, nil
+				},
+				after:   func(EvaluationDetails) error { calls = append(calls, name+" after"); return nil },
+				error:   func(error) { calls = append(calls, name+" error") },
+				finally: func(EvaluationDetails) { calls = append(calls, name+" finally") },
+			}
+		}
+		ft := forRequest(NewClient(provider, nil, recordingHook("first"), recordingHook("second")))
+
+		ft.IsEnabled(EvaluationContext{}, "enabled")
+		Expect(calls).To(Equal([]string{"first before", "second before", "second after", "first after", "second finally", "first finally"}))
+
+		calls = nil
+		ft.IsEnabled(EvaluationContext{}, "unknown")
+		Expect(calls).To(Equal([]string{"first before", "second before", "second error", "first error", "second finally", "first finally"}))
+	})
+
+	It("should let hooks change the evaluation context and fail the evaluation", func() {
+		var userKey string
+		provider := providerFunc(func(_ context.Context, _ string, evalCtx EvaluationContext) Resolution {
+			userKey = evalCtx.UserKey
+			return Resolution{Value: []byte("true"), Reason: ReasonStatic}
+		})
+		hook := &testHook{
+			before: func(hookCtx HookContext) (*EvaluationContext, error) {
+				return &EvaluationContext{UserKey: "anonymous"}, nil
+			},
+			after: func(EvaluationDetails) error { return errors.New("not allowed") },
+		}
+		ft := forRequest(NewClient(provider, nil, hook))
+
+		enabled := false
+		details := ft.Details(EvaluationContext{}, "enabled", &enabled)
+
+		Expect(userKey).To(Equal("anonymous"))
+		Expect(enabled).To(BeFalse())
+		Expect(details.ErrorCode).To(Equal(ErrorGeneral))
+		Expect(details.ErrorMessage).To(Equal("not allowed"))
+	})
+
+	It("should tell which rule of a config toggle decided", func() {
+		percentage := 100.0
+		appConfig := config.Config{FeatureToggles: config.FeatureToggles{
+			"plain":   {Enabled: true},
+			"allowed": {AllowUsers: []string{"tester"}},
+			"rollout": {Percentage: &percentage},
+		}}
+		ft := forRequest(newClient(appConfig, nil, nil))
+
+		var enabled bool
+		Expect(ft.Details(EvaluationContext{}, "plain", &enabled).Reason).To(Equal(ReasonStatic))
+		Expect(ft.Details(EvaluationContext{UserKey: "tester"}, "allowed", &enabled).Reason).To(Equal(ReasonTargetingMatch))
+		Expect(ft.Details(EvaluationContext{UserKey: "user-1"}, "rollout", &enabled).Reason).To(Equal(ReasonSplit))
+		Expect(enabled).To(BeTrue())
+	})
+})
+
+type providerFunc func(ctx context.Context, flag string, evalCtx EvaluationContext) Resolution
+
+func (f providerFunc) Metadata() ProviderMetadata {
+	return ProviderMetadata{Name: "test"}
+}
+
+func (f providerFunc) Resolve(ctx context.Context, flag string, evalCtx EvaluationContext) Resolution {
+	return f(ctx, flag, evalCtx)
+}
+
+type testHook struct {
+	BaseHook
+	before  func(hookCtx HookContext) (*EvaluationContext, error)
+	after   func(details EvaluationDetails) error
+	error   func(err error)
+	finally func(details EvaluationDetails)
+}
+
+func (h *testHook) Before(_ context.Context, hookCtx HookContext) (*EvaluationContext, error) {
+	if h.before == nil {
+		return nil, nil
+	}
+	return h.before(hookCtx)
+}
+
+func (h *testHook) After(_ context.Context, _ HookContext, details EvaluationDetails) error {
+	if h.after == nil {
+		return nil
+	}
+	return h.after(details)
+}
+
+func (h *testHook) Error(_ context.Context, _ HookContext, err error) {
+	if h.error != nil {
+		h.error(err)
+	}
+}
+
+func (h *testHook) Finally(_ context.Context, _ HookContext, details EvaluationDetails) {
+	if h.finally != nil {
+		h.finally(details)
+	}
+}
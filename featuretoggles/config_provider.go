@@ -0,0 +1,102 @@
+package featuretoggles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/go-playground/log"
+	"github.com/jenpaff/golang-microservices/config"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ConfigProvider resolves the toggles of the config files, replaced by the toggles of the store if it is not nil
+type ConfigProvider struct {
+	configStore *config.Store
+	store       *Store
+}
+
+func NewConfigProvider(configStore *config.Store, store *Store) *ConfigProvider {
+	return &ConfigProvider{configStore: configStore, store: store}
+}
+
+// requestToggles is what WithRequest keeps for the evaluations of a request
+type requestToggles struct {
+	toggles        config.FeatureToggles
+	overrides      map[string]override
+	overrideSource string
+	headers        http.Header
+}
+
+type requestTogglesKey struct{}
+
+func (p *ConfigProvider) Metadata() ProviderMetadata {
+	return ProviderMetadata{Name: "config"}
+}
+
+// WithRequest reads the toggles once for the request, such that they stay the same if the config is reloaded
+// in the meantime, and the overrides of the request. Request headers are matched by attribute rules.
+// It fails with an InvalidToggleOverride error if the request overrides toggles in a way that is not allowed.
+func (p *ConfigProvider) WithRequest(ctx context.Context, httpRequest *restful.Request) (context.Context, error) {
+	cfg := p.configStore.Get()
+	toggles := EffectiveToggles(cfg.FeatureToggles, p.store)
+	overrides, err := parseOverrides(toggles, cfg.ToggleOverrides, httpRequest, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, requestTogglesKey{}, &requestToggles{
+		toggles:        toggles,
+		overrides:      overrides,
+		overrideSource: cfg.ToggleOverrides.Source,
+		headers:        httpRequest.Request.Header,
+	}), nil
+}
+
+// Resolve returns true or false for toggles without variants and the value of the chosen variant otherwise,
+// toggles with variants are still enabled or disabled by their rules
+func (p *ConfigProvider) Resolve(ctx context.Context, flag string, evalCtx EvaluationContext) Resolution {
+	request, ok := ctx.Value(requestTogglesKey{}).(*requestToggles)
+	if !ok {
+		request = &requestToggles{toggles: EffectiveToggles(p.configStore.Get().FeatureToggles, p.store)}
+	}
+
+	toggle, ok := request.toggles[flag]
+	if !ok {
+		return resolutionError(ErrorFlagNotFound, fmt.Sprintf("toggle %s is not defined", flag))
+	}
+
+	toggleState, reason := evaluate(flag, toggle, evalCtx, request.headers)
+	var overriddenVariant string
+	if toggleOverride, ok := request.overrides[flag]; ok {
+		log.GetContext(ctx).
+			WithFields(
+				log.F("audit", "toggle_override"),
+				log.F("toggle", flag),
+				log.F("source", request.overrideSource),
+				log.F("from", toggleState),
+				log.F("to", toggleOverride.value()),
+			).
+			Noticef("overriding toggle '%v' from request - switching from '%v' to '%v'", flag, toggleState, toggleOverride.value())
+		toggleState, overriddenVariant, reason = toggleOverride.enabled, toggleOverride.variant, ReasonOverride
+	}
+
+	if !toggle.HasVariants() {
+		return Resolution{Value: json.RawMessage(strconv.FormatBool(toggleState)), Reason: reason}
+	}
+
+	variant, ok := toggle.FindVariant(overriddenVariant)
+	if !ok {
+		var variantReason Reason
+		variant, variantReason, ok = chooseVariant(flag, toggle, toggleState, evalCtx.UserKey)
+		if reason != ReasonOverride {
+			reason = variantReason
+		}
+	}
+	if !ok {
+		// without a default variant the caller's default applies
+		return Resolution{Enabled: &toggleState, Reason: ReasonDefault}
+	}
+	return Resolution{Value: variant.Value, Variant: variant.Name, Enabled: &toggleState, Reason: reason}
+}
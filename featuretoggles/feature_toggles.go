@@ -1,36 +1,197 @@
-//go:generate mockgen -destination=feature_toggles_mock.go -package=featuretoggles github.com/jenpaff/golang-microservices/featuretoggles FeatureToggles
+//go:generate mockgen -destination=feature_toggles_mock.go -package=featuretoggles -self_package=github.com/jenpaff/golang-microservices/featuretoggles github.com/jenpaff/golang-microservices/featuretoggles FeatureToggles
 
 package featuretoggles
 
 import (
-	"github.com/emicklei/go-restful/v3"
+	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/go-playground/log"
-	"github.com/jenpaff/golang-microservices/config"
+	"reflect"
 	"strconv"
+	"time"
 )
 
+// FeatureToggles evaluates flags for a single request, see Client.ForRequest
 type FeatureToggles interface {
-	IsEnabled(toggleName string) bool
+	IsEnabled(evalCtx EvaluationContext, toggleName string) bool
+	// Variant chooses the variant of the toggle for the user and records the exposure, the same user
+	// always gets the same variant as long as the variants and their weights are not changed
+	Variant(evalCtx EvaluationContext, toggleName string) Variant
+	// StringValue returns the value of the variant or defaultValue if there is no variant or it is not a string
+	StringValue(evalCtx EvaluationContext, toggleName string, defaultValue string) string
+	// IntValue returns the value of the variant or defaultValue if there is no variant or it is not an integer
+	IntValue(evalCtx EvaluationContext, toggleName string, defaultValue int) int
+	// JSONValue decodes the value of the variant into the pointer target, target keeps its default if there is
+	// no variant or the value does not fit, the result tells whether it was decoded
+	JSONValue(evalCtx EvaluationContext, toggleName string, target interface{}) bool
+	// Details evaluates the flag like JSONValue and tells how it was evaluated
+	Details(evalCtx EvaluationContext, toggleName string, target interface{}) EvaluationDetails
+}
+
+// EvaluationContext describes who a toggle is evaluated for
+type EvaluationContext struct {
+	// UserKey identifies the user, e.g. the username, it is matched against the allow and deny lists
+	// and hashed for percentage rollouts, it is the targeting key of OpenFeature
+	UserKey string
+	// Attributes are matched by attribute rules, request headers are matched as well
+	Attributes map[string]string
 }
 
 type featureToggles struct {
-	appConfig   *config.Config
-	httpRequest *restful.Request
+	client *client
+	ctx    context.Context
+	// exposed avoids recording the exposure of a user to a toggle again for every evaluation of the request
+	exposed map[string]bool
+}
+
+func (ft *featureToggles) IsEnabled(evalCtx EvaluationContext, toggleName string) bool {
+	toggleState := false
+	ft.Details(evalCtx, toggleName, &toggleState)
+
+	log.GetContext(ft.ctx).Debugf("toggle '%v' state set to '%v'", toggleName, toggleState)
+	return toggleState
+}
+
+func (ft *featureToggles) Variant(evalCtx EvaluationContext, toggleName string) Variant {
+	var value json.RawMessage
+	details, decoded := ft.evaluate(evalCtx, toggleName, &value)
+	if !decoded || details.Variant == "" {
+		log.GetContext(ft.ctx).Debugf("toggle '%v' has no variant for the user", toggleName)
+		return Variant{}
+	}
+
+	log.GetContext(ft.ctx).Debugf("toggle '%v' variant set to '%v'", toggleName, details.Variant)
+	return Variant{Name: details.Variant, Value: value}
+}
+
+func (ft *featureToggles) StringValue(evalCtx EvaluationContext, toggleName string, defaultValue string) string {
+	value := defaultValue
+	ft.Details(evalCtx, toggleName, &value)
+	return value
+}
+
+func (ft *featureToggles) IntValue(evalCtx EvaluationContext, toggleName string, defaultValue int) int {
+	value := defaultValue
+	ft.Details(evalCtx, toggleName, &value)
+	return value
 }
 
-func NewFeatureToggles(appConfig *config.Config, httpRequest *restful.Request) FeatureToggles {
-	return &featureToggles{appConfig: appConfig, httpRequest: httpRequest}
+func (ft *featureToggles) JSONValue(evalCtx EvaluationContext, toggleName string, target interface{}) bool {
+	_, decoded := ft.evaluate(evalCtx, toggleName, target)
+	return decoded
 }
 
-func (ft *featureToggles) IsEnabled(toggleName string) bool {
-	toggleState := ft.appConfig.FeatureToggles[toggleName]
+func (ft *featureToggles) Details(evalCtx EvaluationContext, toggleName string, target interface{}) EvaluationDetails {
+	details, _ := ft.evaluate(evalCtx, toggleName, target)
+	return details
+}
 
-	toggleOverride := ft.httpRequest.QueryParameters(toggleName)
-	if len(toggleOverride) > 0 {
-		log.Infof("overriding toggle '%v' from request - switching from '%v' to '%v'", toggleName, toggleState, toggleOverride[0])
-		toggleState, _ = strconv.ParseBool(toggleOverride[0])
+// evaluate runs the hooks around the provider and decodes the value into target, target keeps its default
+// if the flag cannot be evaluated, the second result tells whether the value was decoded
+func (ft *featureToggles) evaluate(evalCtx EvaluationContext, toggleName string, target interface{}) (EvaluationDetails, bool) {
+	if reflect.TypeOf(target) == nil || reflect.TypeOf(target).Kind() != reflect.Ptr {
+		log.GetContext(ft.ctx).Errorf("value of toggle '%v' cannot be decoded into %T, it is not a pointer", toggleName, target)
+		return EvaluationDetails{FlagKey: toggleName, Value: target, Reason: ReasonError, ErrorCode: ErrorGeneral}, false
 	}
 
-	log.Infof("toggle '%v' state set to '%v'", toggleName, toggleState)
-	return toggleState
+	hookCtx := HookContext{
+		FlagKey:           toggleName,
+		DefaultValue:      reflect.ValueOf(target).Elem().Interface(),
+		EvaluationContext: evalCtx,
+		ProviderMetadata:  ft.client.provider.Metadata(),
+	}
+
+	details, decoded, err := ft.resolve(&hookCtx, target)
+	if err != nil {
+		code, message := describeError(err)
+		details = EvaluationDetails{FlagKey: toggleName, Value: hookCtx.DefaultValue, Reason: ReasonError, ErrorCode: code, ErrorMessage: message}
+		log.GetContext(ft.ctx).Warnf("could not evaluate toggle '%v', using the default: %s", toggleName, err.Error())
+		for i := len(ft.client.hooks) - 1; i >= 0; i-- {
+			ft.client.hooks[i].Error(ft.ctx, hookCtx, err)
+		}
+	}
+	for i := len(ft.client.hooks) - 1; i >= 0; i-- {
+		ft.client.hooks[i].Finally(ft.ctx, hookCtx, details)
+	}
+	return details, decoded
+}
+
+// resolve only decodes into target if the provider and all hooks succeed
+func (ft *featureToggles) resolve(hookCtx *HookContext, target interface{}) (EvaluationDetails, bool, error) {
+	for _, hook := range ft.client.hooks {
+		evalCtx, err := hook.Before(ft.ctx, *hookCtx)
+		if err != nil {
+			return EvaluationDetails{}, false, err
+		}
+		if evalCtx != nil {
+			hookCtx.EvaluationContext = *evalCtx
+		}
+	}
+
+	resolution := ft.client.provider.Resolve(ft.ctx, hookCtx.FlagKey, hookCtx.EvaluationContext)
+	if resolution.ErrorCode != "" {
+		return EvaluationDetails{}, false, &evaluationError{code: resolution.ErrorCode, message: resolution.ErrorMessage}
+	}
+
+	details := EvaluationDetails{FlagKey: hookCtx.FlagKey, Value: hookCtx.DefaultValue, Variant: resolution.Variant, Reason: resolution.Reason}
+	value := resolution.Value
+	// variant toggles keep working as on/off toggles, the state is not an exposure to the variant
+	_, wantsState := target.(*bool)
+	wantsState = wantsState && resolution.Enabled != nil
+	if wantsState {
+		value = json.RawMessage(strconv.FormatBool(*resolution.Enabled))
+	}
+	hasValue := len(value) > 0 && string(value) != "null"
+	// decoding into a copy keeps the default if the value only fits partially
+	decoded := reflect.New(reflect.TypeOf(target).Elem())
+	if hasValue {
+		err := json.Unmarshal(value, decoded.Interface())
+		if err != nil {
+			return EvaluationDetails{}, false, &evaluationError{code: ErrorTypeMismatch, message: fmt.Sprintf("value of toggle %s does not fit %T: %s", hookCtx.FlagKey, target, err.Error())}
+		}
+		details.Value = decoded.Elem().Interface()
+	}
+
+	for i := len(ft.client.hooks) - 1; i >= 0; i-- {
+		err := ft.client.hooks[i].After(ft.ctx, *hookCtx, details)
+		if err != nil {
+			return EvaluationDetails{}, false, err
+		}
+	}
+
+	if !hasValue {
+		return details, false, nil
+	}
+	reflect.ValueOf(target).Elem().Set(decoded.Elem())
+	if details.Variant != "" && !wantsState {
+		ft.expose(hookCtx.EvaluationContext, hookCtx.FlagKey, details.Variant)
+	}
+	return details, true, nil
+}
+
+func (ft *featureToggles) expose(evalCtx EvaluationContext, toggleName string, variantName string) {
+	key := toggleName + ":" + variantName + ":" + evalCtx.UserKey
+	if ft.client.exposures == nil || ft.exposed[key] {
+		return
+	}
+	ft.exposed[key] = true
+	ft.client.exposures.Record(ft.ctx, Exposure{Toggle: toggleName, Variant: variantName, UserKey: evalCtx.UserKey, Time: time.Now().UTC()})
+}
+
+// evaluationError carries the error code of a provider, errors of hooks are general errors
+type evaluationError struct {
+	code    ErrorCode
+	message string
+}
+
+func (e *evaluationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.code, e.message)
+}
+
+func describeError(err error) (ErrorCode, string) {
+	if evaluationErr, ok := err.(*evaluationError); ok {
+		return evaluationErr.code, evaluationErr.message
+	}
+	return ErrorGeneral, err.Error()
 }
@@ -32,16 +32,86 @@ func (m *MockFeatureToggles) EXPECT() *MockFeatureTogglesMockRecorder {
 	return m.recorder
 }
 
+// Details mocks base method
+func (m *MockFeatureToggles) Details(arg0 EvaluationContext, arg1 string, arg2 interface{}) EvaluationDetails {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Details", arg0, arg1, arg2)
+	ret0, _ := ret[0].(EvaluationDetails)
+	return ret0
+}
+
+// Details indicates an expected call of Details
+func (mr *MockFeatureTogglesMockRecorder) Details(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Details", reflect.TypeOf((*MockFeatureToggles)(nil).Details), arg0, arg1, arg2)
+}
+
+// IntValue mocks base method
+func (m *MockFeatureToggles) IntValue(arg0 EvaluationContext, arg1 string, arg2 int) int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IntValue", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// IntValue indicates an expected call of IntValue
+func (mr *MockFeatureTogglesMockRecorder) IntValue(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IntValue", reflect.TypeOf((*MockFeatureToggles)(nil).IntValue), arg0, arg1, arg2)
+}
+
 // IsEnabled mocks base method
-func (m *MockFeatureToggles) IsEnabled(arg0 string) bool {
+func (m *MockFeatureToggles) IsEnabled(arg0 EvaluationContext, arg1 string) bool {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "IsEnabled", arg0)
+	ret := m.ctrl.Call(m, "IsEnabled", arg0, arg1)
 	ret0, _ := ret[0].(bool)
 	return ret0
 }
 
 // IsEnabled indicates an expected call of IsEnabled
-func (mr *MockFeatureTogglesMockRecorder) IsEnabled(arg0 interface{}) *gomock.Call {
+func (mr *MockFeatureTogglesMockRecorder) IsEnabled(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsEnabled", reflect.TypeOf((*MockFeatureToggles)(nil).IsEnabled), arg0, arg1)
+}
+
+// JSONValue mocks base method
+func (m *MockFeatureToggles) JSONValue(arg0 EvaluationContext, arg1 string, arg2 interface{}) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JSONValue", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// JSONValue indicates an expected call of JSONValue
+func (mr *MockFeatureTogglesMockRecorder) JSONValue(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JSONValue", reflect.TypeOf((*MockFeatureToggles)(nil).JSONValue), arg0, arg1, arg2)
+}
+
+// StringValue mocks base method
+func (m *MockFeatureToggles) StringValue(arg0 EvaluationContext, arg1, arg2 string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StringValue", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// StringValue indicates an expected call of StringValue
+func (mr *MockFeatureTogglesMockRecorder) StringValue(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StringValue", reflect.TypeOf((*MockFeatureToggles)(nil).StringValue), arg0, arg1, arg2)
+}
+
+// Variant mocks base method
+func (m *MockFeatureToggles) Variant(arg0 EvaluationContext, arg1 string) Variant {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Variant", arg0, arg1)
+	ret0, _ := ret[0].(Variant)
+	return ret0
+}
+
+// Variant indicates an expected call of Variant
+func (mr *MockFeatureTogglesMockRecorder) Variant(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsEnabled", reflect.TypeOf((*MockFeatureToggles)(nil).IsEnabled), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Variant", reflect.TypeOf((*MockFeatureToggles)(nil).Variant), arg0, arg1)
 }
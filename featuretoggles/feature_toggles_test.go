@@ -3,13 +3,18 @@
 package featuretoggles
 
 import (
+	"errors"
+	"fmt"
 	"github.com/emicklei/go-restful/v3"
+	"github.com/go-playground/log"
 	"github.com/golang/mock/gomock"
 	"github.com/jenpaff/golang-microservices/config"
+	custom_errors "github.com/jenpaff/golang-microservices/errors"
 	"github.com/jenpaff/golang-microservices/test-helper"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"net/http/httptest"
+	"time"
 )
 
 var _ = Describe("Feature Toggles", func() {
@@ -27,45 +32,223 @@ var _ = Describe("Feature Toggles", func() {
 	Context("IsEnabled", func() {
 		It("should return the toggle value from Config if Request does not contain it", func() {
 			appConfig := config.Config{
-				FeatureToggles: map[string]bool{
-					"toggle1": true,
-					"toggle2": false,
+				FeatureToggles: config.FeatureToggles{
+					"toggle1": {Enabled: true},
+					"toggle2": {Enabled: false},
 				},
 			}
 
-			ft := NewFeatureToggles(&appConfig, createFakeRequest(""))
+			ft := newFeatureToggles(&appConfig, createFakeRequest(""))
 
-			Expect(ft.IsEnabled("toggle1")).To(BeTrue())
-			Expect(ft.IsEnabled("toggle2")).To(BeFalse())
+			Expect(ft.IsEnabled(EvaluationContext{}, "toggle1")).To(BeTrue())
+			Expect(ft.IsEnabled(EvaluationContext{}, "toggle2")).To(BeFalse())
 		})
 
 		It("should return false if the toggle is not defined", func() {
 			appConfig := config.Config{
-				FeatureToggles: map[string]bool{
-					"toggle1": true,
+				FeatureToggles: config.FeatureToggles{
+					"toggle1": {Enabled: true},
 				},
 			}
 
-			ft := NewFeatureToggles(&appConfig, createFakeRequest(""))
+			ft := newFeatureToggles(&appConfig, createFakeRequest(""))
 
-			Expect(ft.IsEnabled("undefinedToggle")).To(BeFalse())
+			Expect(ft.IsEnabled(EvaluationContext{}, "undefinedToggle")).To(BeFalse())
 		})
+	})
+
+	Context("rules", func() {
+		percentage := func(value float64) *float64 {
+			return &value
+		}
 
-		It("should override the toggle value based on Request query params", func() {
+		It("should deny users before allowing them", func() {
 			appConfig := config.Config{
-				FeatureToggles: map[string]bool{
-					"toggle1": false,
+				FeatureToggles: config.FeatureToggles{
+					"toggle1": {Enabled: true, AllowUsers: []string{"alice", "bob"}, DenyUsers: []string{"bob"}},
 				},
 			}
 
-			ft := NewFeatureToggles(&appConfig, createFakeRequest("toggle1=true"))
+			ft := newFeatureToggles(&appConfig, createFakeRequest(""))
 
-			Expect(ft.IsEnabled("toggle1")).To(BeTrue())
+			Expect(ft.IsEnabled(EvaluationContext{UserKey: "alice"}, "toggle1")).To(BeTrue())
+			Expect(ft.IsEnabled(EvaluationContext{UserKey: "bob"}, "toggle1")).To(BeFalse())
+			Expect(ft.IsEnabled(EvaluationContext{UserKey: "carol"}, "toggle1")).To(BeTrue())
 		})
 
+		It("should match attributes and request headers", func() {
+			appConfig := config.Config{
+				FeatureToggles: config.FeatureToggles{
+					"toggle1": {Attributes: map[string][]string{"X-Beta-Tester": {"true"}, "plan": {"premium", "gold"}}},
+				},
+			}
+			request := createFakeRequest("")
+			request.Request.Header.Set("x-beta-tester", "true")
+
+			Expect(newFeatureToggles(&appConfig, request).IsEnabled(EvaluationContext{}, "toggle1")).To(BeTrue())
+
+			ft := newFeatureToggles(&appConfig, createFakeRequest(""))
+
+			Expect(ft.IsEnabled(EvaluationContext{Attributes: map[string]string{"plan": "gold"}}, "toggle1")).To(BeTrue())
+			Expect(ft.IsEnabled(EvaluationContext{Attributes: map[string]string{"plan": "free"}}, "toggle1")).To(BeFalse())
+		})
+
+		It("should enable a stable share of users for a percentage rollout", func() {
+			appConfig := config.Config{
+				FeatureToggles: config.FeatureToggles{
+					"toggle1": {Percentage: percentage(5)},
+				},
+			}
+			ft := newFeatureToggles(&appConfig, createFakeRequest(""))
+
+			var enabled []string
+			for i := 0; i < 10000; i++ {
+				user := fmt.Sprintf("user%d", i)
+				if ft.IsEnabled(EvaluationContext{UserKey: user}, "toggle1") {
+					enabled = append(enabled, user)
+				}
+			}
+			Expect(len(enabled)).To(BeNumerically("~", 500, 100))
+
+			appConfig.FeatureToggles["toggle1"] = config.FeatureToggle{Percentage: percentage(20)}
+			for _, user := range enabled {
+				Expect(ft.IsEnabled(EvaluationContext{UserKey: user}, "toggle1")).To(BeTrue(), user)
+			}
+		})
+
+		It("should fall back to enabled without a user for a percentage rollout", func() {
+			appConfig := config.Config{
+				FeatureToggles: config.FeatureToggles{
+					"toggle1": {Enabled: true, Percentage: percentage(0)},
+				},
+			}
+
+			ft := newFeatureToggles(&appConfig, createFakeRequest(""))
+
+			Expect(ft.IsEnabled(EvaluationContext{}, "toggle1")).To(BeTrue())
+			Expect(ft.IsEnabled(EvaluationContext{UserKey: "alice"}, "toggle1")).To(BeFalse())
+		})
+	})
+	Context("overrides", func() {
+		var appConfig config.Config
+
+		BeforeEach(func() {
+			appConfig = config.Config{
+				FeatureToggles: config.FeatureToggles{
+					"toggle1": {Enabled: false, Overridable: true},
+					"toggle2": {Enabled: false},
+				},
+				ToggleOverrides: config.ToggleOverridesConfig{Source: OverridesQuery, SigningKey: "key"},
+			}
+			auditLog.entries = nil
+		})
+
+		It("should override overridable toggles based on Request query params and audit it", func() {
+			ft := newFeatureToggles(&appConfig, createFakeRequest("toggle1=true&toggle2=true&limit=10"))
+
+			Expect(ft.IsEnabled(EvaluationContext{}, "toggle1")).To(BeTrue())
+			Expect(ft.IsEnabled(EvaluationContext{}, "toggle2")).To(BeFalse())
+
+			Expect(auditLog.entries).To(HaveLen(1))
+			Expect(auditLog.entries[0].Level).To(Equal(log.NoticeLevel))
+			Expect(auditLog.entries[0].Fields).To(ContainElements(
+				log.F("audit", "toggle_override"),
+				log.F("toggle", "toggle1"),
+				log.F("source", OverridesQuery),
+				log.F("to", true),
+			))
+		})
+
+		It("should reject invalid override values", func() {
+			for _, value := range []string{"1", "t", "yes", ""} {
+				_, err := newClient(appConfig, nil, nil).ForRequest(createFakeRequest("toggle1="+value))
+
+				Expect(errors.Is(err, custom_errors.InvalidToggleOverride)).To(BeTrue(), value)
+			}
+		})
+
+		It("should ignore overrides if they are disabled", func() {
+			for _, source := range []string{OverridesDisabled, ""} {
+				appConfig.ToggleOverrides.Source = source
+
+				ft := newFeatureToggles(&appConfig, createFakeRequest("toggle1=true"))
+
+				Expect(ft.IsEnabled(EvaluationContext{}, "toggle1")).To(BeFalse())
+			}
+		})
+
+		Context("signed header", func() {
+			BeforeEach(func() {
+				appConfig.ToggleOverrides.Source = OverridesHeader
+			})
+
+			requestWithHeader := func(header string) *restful.Request {
+				request := createFakeRequest("toggle1=false")
+				request.Request.Header.Set(HeaderOverrides, header)
+				return request
+			}
+
+			It("should override toggles from a header signed with the key", func() {
+				header := SignOverrides("key", map[string]bool{"toggle1": true, "toggle2": true}, time.Now().Add(time.Hour))
+
+				ft := newFeatureToggles(&appConfig, requestWithHeader(header))
+
+				Expect(ft.IsEnabled(EvaluationContext{}, "toggle1")).To(BeTrue())
+				Expect(ft.IsEnabled(EvaluationContext{}, "toggle2")).To(BeFalse())
+				Expect(auditLog.entries).To(HaveLen(1))
+			})
+
+			It("should reject headers with a wrong signature or that have expired", func() {
+				for _, header := range []string{
+					SignOverrides("other key", map[string]bool{"toggle1": true}, time.Now().Add(time.Hour)),
+					SignOverrides("key", map[string]bool{"toggle1": true}, time.Now().Add(-time.Minute)),
+					"toggle1=true",
+				} {
+					_, err := newClient(appConfig, nil, nil).ForRequest(requestWithHeader(header))
+
+					Expect(errors.Is(err, custom_errors.InvalidToggleOverride)).To(BeTrue(), header)
+				}
+			})
+
+			It("should ignore query params", func() {
+				ft := newFeatureToggles(&appConfig, createFakeRequest("toggle1=true"))
+
+				Expect(ft.IsEnabled(EvaluationContext{}, "toggle1")).To(BeFalse())
+			})
+		})
 	})
 })
 
+func newFeatureToggles(appConfig *config.Config, request *restful.Request) FeatureToggles {
+	ft, err := newClient(*appConfig, nil, nil).ForRequest(request)
+	Expect(err).ToNot(HaveOccurred())
+	return ft
+}
+
+func newClient(appConfig config.Config, store *Store, exposures ExposureSink) Client {
+	return NewClient(NewConfigProvider(config.NewStore(appConfig), store), exposures)
+}
+
+// auditLog collects the audit entries, log handlers cannot be removed, so it is registered once for the suite
+var auditLog = &auditHandler{}
+
+func init() {
+	log.AddHandler(auditLog, log.AllLevels...)
+}
+
+type auditHandler struct {
+	entries []log.Entry
+}
+
+func (h *auditHandler) Log(e log.Entry) {
+	for _, field := range e.Fields {
+		if field.Key == "audit" {
+			h.entries = append(h.entries, e)
+			return
+		}
+	}
+}
+
 func createFakeRequest(queryParams string) *restful.Request {
 	httpRequest := httptest.NewRequest("GET", "http://www.test.com?"+queryParams, nil)
 	request := restful.NewRequest(httpRequest)
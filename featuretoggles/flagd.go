@@ -0,0 +1,209 @@
+package featuretoggles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	flagdEnabled  = "ENABLED"
+	flagdDisabled = "DISABLED"
+)
+
+// flagdFlags is a flag definition file of flagd, see https://flagd.dev/reference/flag-definitions/
+type flagdFlags struct {
+	Flags map[string]flagdFlag `json:"flags"`
+}
+
+type flagdFlag struct {
+	State          string                     `json:"state"`
+	Variants       map[string]json.RawMessage `json:"variants"`
+	DefaultVariant *string                    `json:"defaultVariant"`
+	// Targeting is the JsonLogic rule choosing the variant, it is cleared if the flag has none
+	Targeting json.RawMessage `json:"targeting"`
+}
+
+// FlagdFileProvider resolves the flags of a flagd flag definition file, targeting rules may use the JsonLogic
+// operations and starts_with, ends_with and fractional of flagd, $ref to shared $evaluators is not supported
+type FlagdFileProvider struct {
+	path  string
+	flags atomic.Value
+}
+
+// NewFlagdFileProvider fails if the file cannot be read or has invalid flags
+func NewFlagdFileProvider(path string) (*FlagdFileProvider, error) {
+	provider := &FlagdFileProvider{path: path}
+	err := provider.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// Reload reads the file again, the current flags are kept if it is invalid
+func (p *FlagdFileProvider) Reload() error {
+	content, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("could not read flagd flags %s: %s", p.path, err.Error())
+	}
+	var definitions flagdFlags
+	err = json.Unmarshal(content, &definitions)
+	if err != nil {
+		return fmt.Errorf("could not parse flagd flags %s: %s", p.path, err.Error())
+	}
+
+	for name, flag := range definitions.Flags {
+		if flag.State != flagdEnabled && flag.State != flagdDisabled {
+			return fmt.Errorf("flag %s of %s must have the state %s or %s", name, p.path, flagdEnabled, flagdDisabled)
+		}
+		if _, ok := flag.Variants[stringValue(flag.DefaultVariant)]; flag.DefaultVariant != nil && !ok {
+			return fmt.Errorf("default variant of flag %s of %s is not one of its variants", name, p.path)
+		}
+		flag.Targeting, err = parseTargeting(flag.Targeting)
+		if err != nil {
+			return fmt.Errorf("invalid targeting of flag %s of %s: %s", name, p.path, err.Error())
+		}
+		definitions.Flags[name] = flag
+	}
+
+	p.flags.Store(definitions.Flags)
+	return nil
+}
+
+func (p *FlagdFileProvider) Metadata() ProviderMetadata {
+	return ProviderMetadata{Name: "flagd-file"}
+}
+
+func (p *FlagdFileProvider) Resolve(_ context.Context, flagKey string, evalCtx EvaluationContext) Resolution {
+	flag, ok := p.flags.Load().(map[string]flagdFlag)[flagKey]
+	if !ok {
+		return resolutionError(ErrorFlagNotFound, fmt.Sprintf("flag %s is not defined", flagKey))
+	}
+	if flag.State == flagdDisabled {
+		return Resolution{Reason: ReasonDisabled}
+	}
+
+	if flag.Targeting == nil {
+		return flag.resolveVariant(stringValue(flag.DefaultVariant), ReasonStatic)
+	}
+
+	data := make(map[string]interface{}, len(evalCtx.Attributes)+2)
+	for name, value := range evalCtx.Attributes {
+		data[name] = value
+	}
+	data["targetingKey"] = evalCtx.UserKey
+	data["$flagd"] = map[string]interface{}{"flagKey": flagKey, "timestamp": float64(time.Now().Unix())}
+
+	variant, err := evalTargeting(flag.Targeting, data)
+	if err != nil {
+		return resolutionError(ErrorParse, fmt.Sprintf("could not evaluate targeting of flag %s: %s", flagKey, err.Error()))
+	}
+	switch v := variant.(type) {
+	case nil:
+		return flag.resolveVariant(stringValue(flag.DefaultVariant), ReasonDefault)
+	case string:
+		return flag.resolveVariant(v, ReasonTargetingMatch)
+	case bool:
+		// flagd accepts booleans for the variants "true" and "false"
+		return flag.resolveVariant(fmt.Sprint(v), ReasonTargetingMatch)
+	default:
+		return resolutionError(ErrorGeneral, fmt.Sprintf("targeting of flag %s returned %v instead of a variant", flagKey, variant))
+	}
+}
+
+func (f flagdFlag) resolveVariant(variant string, reason Reason) Resolution {
+	if variant == "" {
+		// without a default variant the caller's default applies
+		return Resolution{Reason: reason}
+	}
+	value, ok := f.Variants[variant]
+	if !ok {
+		return resolutionError(ErrorGeneral, fmt.Sprintf("variant %s is not defined", variant))
+	}
+	return Resolution{Value: value, Variant: variant, Reason: reason}
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// FlagdHTTPProvider resolves flags remotely with the OpenFeature Remote Evaluation Protocol (OFREP) served by flagd
+type FlagdHTTPProvider struct {
+	address string
+	client  *http.Client
+}
+
+func NewFlagdHTTPProvider(address string, timeout time.Duration) *FlagdHTTPProvider {
+	return &FlagdHTTPProvider{address: strings.TrimSuffix(address, "/"), client: &http.Client{Timeout: timeout}}
+}
+
+type ofrepRequest struct {
+	Context map[string]string `json:"context"`
+}
+
+type ofrepResponse struct {
+	Value        json.RawMessage `json:"value"`
+	Variant      string          `json:"variant"`
+	Reason       Reason          `json:"reason"`
+	ErrorCode    ErrorCode       `json:"errorCode"`
+	ErrorDetails string          `json:"errorDetails"`
+}
+
+func (p *FlagdHTTPProvider) Metadata() ProviderMetadata {
+	return ProviderMetadata{Name: "flagd-http"}
+}
+
+func (p *FlagdHTTPProvider) Resolve(ctx context.Context, flagKey string, evalCtx EvaluationContext) Resolution {
+	evaluationContext := make(map[string]string, len(evalCtx.Attributes)+1)
+	for name, value := range evalCtx.Attributes {
+		evaluationContext[name] = value
+	}
+	if evalCtx.UserKey != "" {
+		evaluationContext["targetingKey"] = evalCtx.UserKey
+	}
+	body, err := json.Marshal(ofrepRequest{Context: evaluationContext})
+	if err != nil {
+		return resolutionError(ErrorInvalidContext, err.Error())
+	}
+
+	flagURL := fmt.Sprintf("%s/ofrep/v1/evaluate/flags/%s", p.address, url.PathEscape(flagKey))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, flagURL, bytes.NewReader(body))
+	if err != nil {
+		return resolutionError(ErrorGeneral, err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return resolutionError(ErrorProviderNotReady, fmt.Sprintf("could not reach flagd: %s", err.Error()))
+	}
+	defer resp.Body.Close()
+
+	var response ofrepResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil && resp.StatusCode == http.StatusOK {
+		return resolutionError(ErrorParse, fmt.Sprintf("could not decode flagd response: %s", err.Error()))
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return Resolution{Value: response.Value, Variant: response.Variant, Reason: response.Reason}
+	case response.ErrorCode != "":
+		return resolutionError(response.ErrorCode, response.ErrorDetails)
+	case resp.StatusCode == http.StatusNotFound:
+		return resolutionError(ErrorFlagNotFound, fmt.Sprintf("flag %s is not defined", flagKey))
+	default:
+		return resolutionError(ErrorGeneral, fmt.Sprintf("flagd returned status %d for flag %s", resp.StatusCode, flagKey))
+	}
+}
@@ -0,0 +1,150 @@
+package featuretoggles
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/diegoholiveira/jsonlogic/v3"
+	"github.com/twmb/murmur3"
+	"math"
+	"strings"
+)
+
+// flagd evaluates targeting rules with the same JsonLogic library, only the operations flagd adds are registered
+func init() {
+	jsonlogic.AddOperator("starts_with", startsWith)
+	jsonlogic.AddOperator("ends_with", endsWith)
+	jsonlogic.AddOperator("fractional", fractional)
+}
+
+// parseTargeting returns no rule if the targeting is empty and rejects rules using operations neither JsonLogic
+// nor the registered flagd operations know, such that they fail when the flags are loaded
+func parseTargeting(rule json.RawMessage) (json.RawMessage, error) {
+	if len(rule) == 0 {
+		return nil, nil
+	}
+	var decoded interface{}
+	err := json.Unmarshal(rule, &decoded)
+	if err != nil {
+		return nil, err
+	}
+	// an empty rule is how flagd files usually say there is no targeting
+	if decodedRule, ok := decoded.(map[string]interface{}); decoded == nil || ok && len(decodedRule) == 0 {
+		return nil, nil
+	}
+	if !jsonlogic.ValidateJsonLogic(decoded) {
+		return nil, fmt.Errorf("only the JsonLogic operations and starts_with, ends_with and fractional are supported")
+	}
+	return rule, nil
+}
+
+// evalTargeting evaluates the JsonLogic rule of a flagd targeting against data
+func evalTargeting(rule json.RawMessage, data map[string]interface{}) (interface{}, error) {
+	encodedData, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	result, err := jsonlogic.ApplyRaw(rule, encodedData)
+	if err != nil {
+		return nil, err
+	}
+	var variant interface{}
+	err = json.Unmarshal(result, &variant)
+	if err != nil {
+		return nil, err
+	}
+	return variant, nil
+}
+
+// startsWith and endsWith compare strings like flagd, other arguments are no match
+func startsWith(values, _ interface{}) interface{} {
+	value, prefix, ok := stringArguments(values)
+	if !ok {
+		return nil
+	}
+	return strings.HasPrefix(value, prefix)
+}
+
+func endsWith(values, _ interface{}) interface{} {
+	value, suffix, ok := stringArguments(values)
+	if !ok {
+		return nil
+	}
+	return strings.HasSuffix(value, suffix)
+}
+
+func stringArguments(values interface{}) (string, string, bool) {
+	args, ok := values.([]interface{})
+	if !ok || len(args) != 2 {
+		return "", "", false
+	}
+	value, ok := args[0].(string)
+	if !ok {
+		return "", "", false
+	}
+	compared, ok := args[1].(string)
+	return value, compared, ok
+}
+
+// fractional splits users into weighted buckets like flagd, e.g. [["red", 50], ["blue", 50]], the users are
+// bucketed by the flag key and their targeting key unless the first argument is the value to bucket by.
+// Like flagd it returns no variant if the buckets are invalid, so the default variant applies
+func fractional(values, data interface{}) interface{} {
+	args, ok := values.([]interface{})
+	if !ok || len(args) == 0 {
+		return nil
+	}
+	bucketBy, ok := args[0].(string)
+	if ok {
+		args = args[1:]
+	} else {
+		dataMap, _ := data.(map[string]interface{})
+		targetingKey, _ := dataMap["targetingKey"].(string)
+		if targetingKey == "" {
+			return nil
+		}
+		flagdProperties, _ := dataMap["$flagd"].(map[string]interface{})
+		flagKey, _ := flagdProperties["flagKey"].(string)
+		bucketBy = flagKey + targetingKey
+	}
+
+	type distribution struct {
+		variant string
+		weight  float64
+	}
+	distributions := make([]distribution, 0, len(args))
+	var totalWeight float64
+	for _, arg := range args {
+		bucket, ok := arg.([]interface{})
+		if !ok || len(bucket) == 0 {
+			return nil
+		}
+		variant, ok := bucket[0].(string)
+		if !ok {
+			return nil
+		}
+		weight := 1.0
+		if len(bucket) > 1 {
+			weight, ok = bucket[1].(float64)
+			if !ok || weight < 0 {
+				return nil
+			}
+		}
+		distributions = append(distributions, distribution{variant: variant, weight: weight})
+		totalWeight += weight
+	}
+	if totalWeight == 0 {
+		return nil
+	}
+
+	// the hash is mapped to a bucket exactly like flagd does, such that both put a user in the same bucket
+	hashRatio := math.Abs(float64(int32(murmur3.StringSum32(bucketBy)))) / math.MaxInt32
+	point := hashRatio * totalWeight
+	var rangeEnd float64
+	for _, d := range distributions {
+		rangeEnd += d.weight
+		if point < rangeEnd {
+			return d.variant
+		}
+	}
+	return distributions[len(distributions)-1].variant
+}
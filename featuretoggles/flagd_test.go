@@ -0,0 +1,214 @@
+//+build unit
+
+package featuretoggles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+)
+
+const flagdDefinitions = `{
+  "$schema": "https://flagd.dev/schema/v0/flags.json",
+  "flags": {
+    "newCheckout": {
+      "state": "ENABLED",
+      "variants": {"on": true, "off": false},
+      "defaultVariant": "off",
+      "targeting": {}
+    },
+    "buttonColor": {
+      "state": "ENABLED",
+      "variants": {"red": "#f00", "green": "#0f0", "blue": "#00f"},
+      "defaultVariant": "red",
+      "targeting": {
+        "if": [
+          {"in": [{"var": "country"}, ["DE", "AT"]]}, "blue",
+          {"ends_with": [{"var": "email"}, "@example.com"]}, "green",
+          null
+        ]
+      }
+    },
+    "pageSize": {
+      "state": "ENABLED",
+      "variants": {"small": 10, "large": 50},
+      "defaultVariant": "small",
+      "targeting": {"fractional": [["small", 50], ["large", 50]]}
+    },
+    "retired": {
+      "state": "DISABLED",
+      "variants": {"on": true, "off": false},
+      "defaultVariant": "on"
+    }
+  }
+}`
+
+var _ = Describe("flagd", func() {
+
+	ctx := context.Background()
+
+	Context("file provider", func() {
+
+		var dir string
+		var path string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "flagd")
+			Expect(err).ToNot(HaveOccurred())
+			path = filepath.Join(dir, "flags.json")
+			Expect(ioutil.WriteFile(path, []byte(flagdDefinitions), 0600)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("should resolve the default variant of flags without targeting", func() {
+			provider, err := NewFlagdFileProvider(path)
+			Expect(err).ToNot(HaveOccurred())
+
+			resolution := provider.Resolve(ctx, "newCheckout", EvaluationContext{})
+			Expect(resolution).To(Equal(Resolution{Value: json.RawMessage("false"), Variant: "off", Reason: ReasonStatic}))
+		})
+
+		It("should evaluate the targeting rules", func() {
+			provider, err := NewFlagdFileProvider(path)
+			Expect(err).ToNot(HaveOccurred())
+
+			german := provider.Resolve(ctx, "buttonColor", EvaluationContext{Attributes: map[string]string{"country": "DE"}})
+			Expect(german.Variant).To(Equal("blue"))
+			Expect(german.Reason).To(Equal(ReasonTargetingMatch))
+
+			example := provider.Resolve(ctx, "buttonColor", EvaluationContext{Attributes: map[string]string{"email": "jane@example.com"}})
+			Expect(example.Variant).To(Equal("green"))
+
+			other := provider.Resolve(ctx, "buttonColor", EvaluationContext{Attributes: map[string]string{"country": "FR"}})
+			Expect(other.Variant).To(Equal("red"))
+			Expect(other.Reason).To(Equal(ReasonDefault))
+		})
+
+		It("should split users into fractional buckets", func() {
+			provider, err := NewFlagdFileProvider(path)
+			Expect(err).ToNot(HaveOccurred())
+
+			counts := make(map[string]int)
+			for i := 0; i < 2000; i++ {
+				user := EvaluationContext{UserKey: fmt.Sprintf("user-%d", i)}
+				resolution := provider.Resolve(ctx, "pageSize", user)
+				Expect(provider.Resolve(ctx, "pageSize", user)).To(Equal(resolution))
+				counts[resolution.Variant]++
+			}
+			Expect(counts["small"]).To(BeNumerically("~", 1000, 100))
+			Expect(counts["large"]).To(BeNumerically("~", 1000, 100))
+
+			Expect(provider.Resolve(ctx, "pageSize", EvaluationContext{}).Variant).To(Equal("small"))
+		})
+
+		It("should not resolve disabled or unknown flags", func() {
+			provider, err := NewFlagdFileProvider(path)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(provider.Resolve(ctx, "retired", EvaluationContext{})).To(Equal(Resolution{Reason: ReasonDisabled}))
+			Expect(provider.Resolve(ctx, "unknown", EvaluationContext{}).ErrorCode).To(Equal(ErrorFlagNotFound))
+		})
+
+		It("should keep the flags if the reloaded file is invalid", func() {
+			provider, err := NewFlagdFileProvider(path)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(ioutil.WriteFile(path, []byte(`{"flags": {"newCheckout": {"state": "ON"}}}`), 0600)).To(Succeed())
+			Expect(provider.Reload()).To(MatchError(ContainSubstring("must have the state ENABLED or DISABLED")))
+			Expect(provider.Resolve(ctx, "newCheckout", EvaluationContext{}).Variant).To(Equal("off"))
+
+			Expect(ioutil.WriteFile(path, []byte(`{"flags": {"newCheckout": {"state": "ENABLED", "variants": {"on": true}, "defaultVariant": "on", "targeting": {"sem_ver": [{"var": "version"}, ">=", "1.0.0"]}}}}`), 0600)).To(Succeed())
+			Expect(provider.Reload()).To(MatchError(ContainSubstring("invalid targeting of flag newCheckout")))
+			Expect(provider.Resolve(ctx, "newCheckout", EvaluationContext{}).Variant).To(Equal("off"))
+
+			Expect(ioutil.WriteFile(path, []byte(`{"flags": {"newCheckout": {"state": "ENABLED", "variants": {"on": true}, "defaultVariant": "on"}}}`), 0600)).To(Succeed())
+			Expect(provider.Reload()).To(Succeed())
+			Expect(provider.Resolve(ctx, "newCheckout", EvaluationContext{}).Variant).To(Equal("on"))
+		})
+	})
+
+	Context("HTTP provider", func() {
+
+		var server *httptest.Server
+		var requests []ofrepRequest
+
+		BeforeEach(func() {
+			requests = nil
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var request ofrepRequest
+				Expect(json.NewDecoder(r.Body).Decode(&request)).To(Succeed())
+				requests = append(requests, request)
+
+				w.Header().Set("Content-Type", "application/json")
+				switch r.URL.Path {
+				case "/ofrep/v1/evaluate/flags/buttonColor":
+					_, _ = w.Write([]byte(`{"key": "buttonColor", "value": "#0f0", "variant": "green", "reason": "TARGETING_MATCH"}`))
+				case "/ofrep/v1/evaluate/flags/pageSize":
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = w.Write([]byte(`{"key": "pageSize", "errorCode": "TARGETING_KEY_MISSING", "errorDetails": "targeting key is required"}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = w.Write([]byte(`{"key": "unknown", "errorCode": "FLAG_NOT_FOUND"}`))
+				}
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should resolve flags remotely", func() {
+			provider := NewFlagdHTTPProvider(server.URL+"/", 0)
+
+			resolution := provider.Resolve(ctx, "buttonColor", EvaluationContext{UserKey: "user-1", Attributes: map[string]string{"country": "DE"}})
+
+			Expect(resolution).To(Equal(Resolution{Value: json.RawMessage(`"#0f0"`), Variant: "green", Reason: ReasonTargetingMatch}))
+			Expect(requests).To(ConsistOf(ofrepRequest{Context: map[string]string{"targetingKey": "user-1", "country": "DE"}}))
+		})
+
+		It("should return the error codes of flagd", func() {
+			provider := NewFlagdHTTPProvider(server.URL, 0)
+
+			Expect(provider.Resolve(ctx, "pageSize", EvaluationContext{})).To(Equal(Resolution{Reason: ReasonError, ErrorCode: ErrorTargetingKeyMissing, ErrorMessage: "targeting key is required"}))
+			Expect(provider.Resolve(ctx, "unknown", EvaluationContext{}).ErrorCode).To(Equal(ErrorFlagNotFound))
+
+			server.Close()
+			Expect(provider.Resolve(ctx, "buttonColor", EvaluationContext{}).ErrorCode).To(Equal(ErrorProviderNotReady))
+		})
+	})
+
+	// the users and buckets of the fractional tests of flagd, flagd puts them in these buckets
+	DescribeTable("should put users in the fractional buckets flagd puts them in",
+		func(email string, variant string) {
+			targeting := json.RawMessage(`{"fractional": [
+				{"cat": [{"var": "$flagd.flagKey"}, {"var": "email"}]},
+				["red", 25], ["blue", 25], ["green", 25], ["yellow", 25]
+			]}`)
+			data := map[string]interface{}{"email": email, "$flagd": map[string]interface{}{"flagKey": "headerColor"}}
+
+			Expect(evalTargeting(targeting, data)).To(Equal(variant))
+
+			// without a value to bucket by, the flag key and the targeting key are bucketed by
+			targeting = json.RawMessage(`{"fractional": [["red", 25], ["blue", 25], ["green", 25], ["yellow", 25]]}`)
+			data = map[string]interface{}{"targetingKey": email, "$flagd": map[string]interface{}{"flagKey": "headerColor"}}
+
+			Expect(evalTargeting(targeting, data)).To(Equal(variant))
+		},
+		Entry("rachel", "rachel@faas.com", "yellow"),
+		Entry("monica", "monica@faas.com", "blue"),
+		Entry("joey", "joey@faas.com", "red"),
+		Entry("ross", "ross@faas.com", "green"),
+	)
+})
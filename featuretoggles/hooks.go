@@ -0,0 +1,39 @@
+package featuretoggles
+
+import (
+	"context"
+)
+
+// HookContext describes the evaluation a hook is called for
+type HookContext struct {
+	FlagKey           string
+	DefaultValue      interface{}
+	EvaluationContext EvaluationContext
+	ProviderMetadata  ProviderMetadata
+}
+
+// Hook is called around every evaluation of a flag like an OpenFeature hook, the stages run in this order:
+// Before, After or Error if the flag could not be resolved or a hook failed, and Finally
+type Hook interface {
+	// Before may return a new evaluation context to resolve the flag with, nil keeps the current one
+	Before(ctx context.Context, hookCtx HookContext) (*EvaluationContext, error)
+	// After may fail the evaluation, the caller gets the default value then
+	After(ctx context.Context, hookCtx HookContext, details EvaluationDetails) error
+	Error(ctx context.Context, hookCtx HookContext, err error)
+	Finally(ctx context.Context, hookCtx HookContext, details EvaluationDetails)
+}
+
+// BaseHook implements every stage as no-op, embedding it lets hooks implement only the stages they need
+type BaseHook struct{}
+
+func (BaseHook) Before(context.Context, HookContext) (*EvaluationContext, error) {
+	return nil, nil
+}
+
+func (BaseHook) After(context.Context, HookContext, EvaluationDetails) error {
+	return nil
+}
+
+func (BaseHook) Error(context.Context, HookContext, error) {}
+
+func (BaseHook) Finally(context.Context, HookContext, EvaluationDetails) {}
@@ -0,0 +1,54 @@
+package featuretoggles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// InMemoryFlag is a flag of the InMemoryProvider, Value is anything that can be written as JSON
+type InMemoryFlag struct {
+	Value   interface{}
+	Variant string
+}
+
+// InMemoryProvider serves flags set by code, e.g. in tests, every user gets the same value
+type InMemoryProvider struct {
+	mu    sync.RWMutex
+	flags map[string]InMemoryFlag
+}
+
+func NewInMemoryProvider(flags map[string]InMemoryFlag) *InMemoryProvider {
+	provider := &InMemoryProvider{flags: make(map[string]InMemoryFlag, len(flags))}
+	for name, flag := range flags {
+		provider.flags[name] = flag
+	}
+	return provider
+}
+
+// Set adds or replaces a flag
+func (p *InMemoryProvider) Set(name string, flag InMemoryFlag) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flags[name] = flag
+}
+
+func (p *InMemoryProvider) Metadata() ProviderMetadata {
+	return ProviderMetadata{Name: "in-memory"}
+}
+
+func (p *InMemoryProvider) Resolve(_ context.Context, flag string, _ EvaluationContext) Resolution {
+	p.mu.RLock()
+	inMemoryFlag, ok := p.flags[flag]
+	p.mu.RUnlock()
+	if !ok {
+		return resolutionError(ErrorFlagNotFound, fmt.Sprintf("flag %s is not set", flag))
+	}
+
+	value, err := json.Marshal(inMemoryFlag.Value)
+	if err != nil {
+		return resolutionError(ErrorParse, fmt.Sprintf("value of flag %s cannot be written as JSON: %s", flag, err.Error()))
+	}
+	return Resolution{Value: value, Variant: inMemoryFlag.Variant, Reason: ReasonStatic}
+}
@@ -0,0 +1,36 @@
+package featuretoggles
+
+import (
+	"github.com/jenpaff/golang-microservices/config"
+	"sort"
+	"time"
+)
+
+// StaleToggle is a toggle past its expiry date that should be removed from the code
+type StaleToggle struct {
+	Name        string
+	Owner       string
+	Description string
+	Expires     config.Date
+	DaysOverdue int
+}
+
+// StaleToggles lists the expired toggles sorted by name, permanent toggles never expire
+func StaleToggles(toggles config.FeatureToggles, now time.Time) []StaleToggle {
+	stale := make([]StaleToggle, 0)
+	for name, toggle := range toggles {
+		if !toggle.IsExpired(now) {
+			continue
+		}
+		overdue := now.Sub(toggle.Expires.Time())
+		stale = append(stale, StaleToggle{
+			Name:        name,
+			Owner:       toggle.Owner,
+			Description: toggle.Description,
+			Expires:     *toggle.Expires,
+			DaysOverdue: int(overdue / (24 * time.Hour)),
+		})
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Name < stale[j].Name })
+	return stale
+}
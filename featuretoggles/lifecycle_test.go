@@ -0,0 +1,116 @@
+//+build unit
+
+package featuretoggles
+
+import (
+	"github.com/jenpaff/golang-microservices/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var _ = Describe("Lifecycle", func() {
+
+	date := func(year int, month time.Month, day int) *config.Date {
+		d := config.NewDate(time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+		return &d
+	}
+
+	Context("stale toggles", func() {
+
+		It("lists the expired toggles that are not permanent", func() {
+			toggles := config.FeatureToggles{
+				"expired":    {Owner: "checkout-team", Description: "new checkout", Expires: date(2021, 6, 1)},
+				"expiresNow": {Expires: date(2021, 6, 10)},
+				"current":    {Expires: date(2021, 6, 11)},
+				"noExpiry":   {Enabled: true},
+				"killSwitch": {Permanent: true},
+			}
+
+			stale := StaleToggles(toggles, time.Date(2021, 6, 10, 12, 0, 0, 0, time.UTC))
+
+			Expect(stale).To(Equal([]StaleToggle{
+				{Name: "expired", Owner: "checkout-team", Description: "new checkout", Expires: *date(2021, 6, 1), DaysOverdue: 9},
+				{Name: "expiresNow", Expires: *date(2021, 6, 10), DaysOverdue: 0},
+			}))
+		})
+
+		It("lists nothing if no toggle expired", func() {
+			Expect(StaleToggles(config.FeatureToggles{"enableNewFeature": {Enabled: true}}, time.Now())).To(BeEmpty())
+		})
+	})
+
+	Context("usages", func() {
+
+		var dir string
+
+		writeFile := func(name, content string) {
+			path := filepath.Join(dir, name)
+			Expect(os.MkdirAll(filepath.Dir(path), 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "usages")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("finds toggles evaluated with a literal name", func() {
+			writeFile("api/controller.go", `package api
+
+func create(ft FeatureToggles, name string) {
+	if ft.IsEnabled(EvaluationContext{}, "enableNewFeature") {
+	}
+	_ = ft.StringValue(EvaluationContext{}, "checkoutButton", "blue")
+	_ = ft.IsEnabled(EvaluationContext{}, name)
+	_ = strings.Variant("notAToggle")
+}
+`)
+			writeFile("api/controller_test.go", `package api
+
+func test(ft FeatureToggles) { ft.IsEnabled(EvaluationContext{}, "testToggle") }
+`)
+			writeFile("vendor/lib/lib.go", `package lib
+
+func lib(ft FeatureToggles) { ft.IsEnabled(EvaluationContext{}, "vendoredToggle") }
+`)
+
+			usages, err := ScanUsages(dir)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(usages).To(HaveLen(2))
+			Expect(usages[0].Toggle).To(Equal("enableNewFeature"))
+			Expect(usages[0].Position.Filename).To(Equal(filepath.Join(dir, "api/controller.go")))
+			Expect(usages[0].Position.Line).To(Equal(4))
+			Expect(usages[1].Toggle).To(Equal("checkoutButton"))
+		})
+
+		It("fails for files that cannot be parsed", func() {
+			writeFile("broken.go", "package broken\n\nfunc {")
+
+			_, err := ScanUsages(dir)
+
+			Expect(err).To(MatchError(ContainSubstring("could not parse")))
+		})
+
+		It("reports unused and undefined toggles", func() {
+			toggles := config.FeatureToggles{"enableNewFeature": {}, "forgotten": {}, "alsoForgotten": {}}
+			usages := []Usage{{Toggle: "enableNewFeature"}, {Toggle: "misspelt"}, {Toggle: "enableNewFeature"}}
+
+			report := CheckUsages(toggles, usages)
+
+			Expect(report.HasFindings()).To(BeTrue())
+			Expect(report.Unused).To(Equal([]string{"alsoForgotten", "forgotten"}))
+			Expect(report.Undefined).To(Equal([]Usage{{Toggle: "misspelt"}}))
+			Expect(CheckUsages(config.FeatureToggles{"enableNewFeature": {}}, usages[:1]).HasFindings()).To(BeFalse())
+		})
+	})
+})
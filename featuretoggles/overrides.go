@@ -0,0 +1,177 @@
+package featuretoggles
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/go-playground/log"
+	"github.com/jenpaff/golang-microservices/config"
+	"github.com/jenpaff/golang-microservices/errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	OverridesQuery    = "query"
+	OverridesHeader   = "header"
+	OverridesDisabled = "disabled"
+
+	// HeaderOverrides carries signed overrides such as "enableNewFeature=true,checkoutButton=green; expires=1767225600; signature=3f2a...",
+	// the signature is the hex encoded HMAC-SHA256 of everything before "; signature=", see SignOverrides
+	HeaderOverrides = "X-Feature-Overrides"
+
+	signatureSeparator = "; signature="
+)
+
+// SignOverrides creates a value for the X-Feature-Overrides header that is valid until expires
+func SignOverrides(signingKey string, overrides map[string]bool, expires time.Time) string {
+	values := make(map[string]string, len(overrides))
+	for name, enabled := range overrides {
+		values[name] = strconv.FormatBool(enabled)
+	}
+	return SignVariantOverrides(signingKey, values, expires)
+}
+
+// SignVariantOverrides is SignOverrides for overrides that choose a variant, e.g. {"checkoutButton": "green"},
+// the values "true" and "false" switch a toggle like SignOverrides does
+func SignVariantOverrides(signingKey string, overrides map[string]string, expires time.Time) string {
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=%s", name, overrides[name])
+	}
+	payload := fmt.Sprintf("%s; expires=%d", strings.Join(pairs, ","), expires.Unix())
+	return payload + signatureSeparator + sign(signingKey, payload)
+}
+
+func sign(signingKey string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	_, _ = mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// override is what a request sets a toggle to, variant is empty unless the request chooses a variant
+type override struct {
+	enabled bool
+	variant string
+}
+
+// value is logged as the new state of the toggle
+func (o override) value() interface{} {
+	if o.variant != "" {
+		return o.variant
+	}
+	return o.enabled
+}
+
+// parseOverrides reads the overrides of the request from the source configured for the environment,
+// overrides of toggles that are not overridable are ignored, malformed overrides fail the request
+func parseOverrides(toggles config.FeatureToggles, overridesConfig config.ToggleOverridesConfig, httpRequest *restful.Request, now time.Time) (map[string]override, error) {
+	var requested map[string]string
+	var err error
+	switch overridesConfig.Source {
+	case OverridesQuery:
+		requested, err = queryOverrides(toggles, httpRequest)
+	case OverridesHeader:
+		requested, err = headerOverrides(overridesConfig.SigningKey, httpRequest.HeaderParameter(HeaderOverrides), now)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[string]override)
+	for toggleName, value := range requested {
+		toggle, ok := toggles[toggleName]
+		if !ok || !toggle.Overridable {
+			log.GetContext(httpRequest.Request.Context()).Warnf("ignoring override of toggle '%v', it is not overridable", toggleName)
+			continue
+		}
+		overrides[toggleName], err = parseOverrideValue(toggleName, toggle, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return overrides, nil
+}
+
+// queryOverrides only looks at parameters named like a toggle, any other parameter belongs to the endpoint
+func queryOverrides(toggles config.FeatureToggles, httpRequest *restful.Request) (map[string]string, error) {
+	requested := make(map[string]string)
+	for toggleName := range toggles {
+		values := httpRequest.QueryParameters(toggleName)
+		if len(values) == 0 {
+			continue
+		}
+		if len(values) > 1 {
+			return nil, fmt.Errorf("toggle '%s' is overridden more than once: %w", toggleName, errors.InvalidToggleOverride)
+		}
+		requested[toggleName] = values[0]
+	}
+	return requested, nil
+}
+
+func headerOverrides(signingKey string, header string, now time.Time) (map[string]string, error) {
+	if header == "" {
+		return nil, nil
+	}
+
+	separator := strings.LastIndex(header, signatureSeparator)
+	if separator < 0 {
+		return nil, fmt.Errorf("%s is not signed: %w", HeaderOverrides, errors.InvalidToggleOverride)
+	}
+	payload, signature := header[:separator], header[separator+len(signatureSeparator):]
+	if !hmac.Equal([]byte(sign(signingKey, payload)), []byte(strings.TrimSpace(signature))) {
+		return nil, fmt.Errorf("%s has an invalid signature: %w", HeaderOverrides, errors.InvalidToggleOverride)
+	}
+
+	parts := strings.Split(payload, ";")
+	if len(parts) != 2 || !strings.HasPrefix(strings.TrimSpace(parts[1]), "expires=") {
+		return nil, fmt.Errorf("%s must have the form toggle=value,...; expires=unix time: %w", HeaderOverrides, errors.InvalidToggleOverride)
+	}
+	expires, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(parts[1]), "expires="), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s has an invalid expiry: %w", HeaderOverrides, errors.InvalidToggleOverride)
+	}
+	if now.After(time.Unix(expires, 0)) {
+		return nil, fmt.Errorf("%s has expired: %w", HeaderOverrides, errors.InvalidToggleOverride)
+	}
+
+	requested := make(map[string]string)
+	for _, pair := range strings.Split(parts[0], ",") {
+		nameAndValue := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(nameAndValue) != 2 {
+			return nil, fmt.Errorf("%s entry '%s' must have the form toggle=value: %w", HeaderOverrides, pair, errors.InvalidToggleOverride)
+		}
+		requested[nameAndValue[0]] = nameAndValue[1]
+	}
+	return requested, nil
+}
+
+// parseOverrideValue only accepts true, false and the variants of the toggle,
+// strconv.ParseBool would also take values such as "t" or "0"
+func parseOverrideValue(toggleName string, toggle config.FeatureToggle, value string) (override, error) {
+	switch value {
+	case "true":
+		return override{enabled: true}, nil
+	case "false":
+		return override{enabled: false}, nil
+	}
+	if _, ok := toggle.FindVariant(value); ok {
+		return override{enabled: true, variant: value}, nil
+	}
+	if toggle.HasVariants() {
+		return override{}, fmt.Errorf("override of toggle '%s' must be true, false or a variant but is '%s': %w", toggleName, value, errors.InvalidToggleOverride)
+	}
+	return override{}, fmt.Errorf("override of toggle '%s' must be true or false but is '%s': %w", toggleName, value, errors.InvalidToggleOverride)
+}
@@ -0,0 +1,101 @@
+package featuretoggles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/jenpaff/golang-microservices/config"
+	"time"
+)
+
+// Reason tells why a flag resolved to its value, the values follow the OpenFeature specification
+type Reason string
+
+const (
+	ReasonStatic         Reason = "STATIC"
+	ReasonDefault        Reason = "DEFAULT"
+	ReasonTargetingMatch Reason = "TARGETING_MATCH"
+	ReasonSplit          Reason = "SPLIT"
+	ReasonDisabled       Reason = "DISABLED"
+	ReasonError          Reason = "ERROR"
+	ReasonUnknown        Reason = "UNKNOWN"
+	// ReasonOverride is set if the request overrode the flag, see config.ToggleOverridesConfig
+	ReasonOverride Reason = "OVERRIDE"
+)
+
+// ErrorCode tells why a flag could not be resolved, the caller gets the default value then
+type ErrorCode string
+
+const (
+	ErrorProviderNotReady    ErrorCode = "PROVIDER_NOT_READY"
+	ErrorFlagNotFound        ErrorCode = "FLAG_NOT_FOUND"
+	ErrorParse               ErrorCode = "PARSE_ERROR"
+	ErrorTypeMismatch        ErrorCode = "TYPE_MISMATCH"
+	ErrorTargetingKeyMissing ErrorCode = "TARGETING_KEY_MISSING"
+	ErrorInvalidContext      ErrorCode = "INVALID_CONTEXT"
+	ErrorGeneral             ErrorCode = "GENERAL"
+)
+
+// Provider resolves flags from a source such as the config files or flagd, it follows the OpenFeature provider model
+// but resolves any JSON value, the client checks whether it has the type the caller asked for
+type Provider interface {
+	Metadata() ProviderMetadata
+	Resolve(ctx context.Context, flag string, evalCtx EvaluationContext) Resolution
+}
+
+// RequestProvider is a Provider that uses more of the request than the evaluation context,
+// WithRequest returns the context the flags of the request are resolved with
+type RequestProvider interface {
+	Provider
+	WithRequest(ctx context.Context, httpRequest *restful.Request) (context.Context, error)
+}
+
+// NewProvider creates the provider configured in ToggleProvider, the config provider reads the config files
+// from configStore and the stored toggles from store if it is not nil
+func NewProvider(cfg config.ToggleProviderConfig, configStore *config.Store, store *Store) (Provider, error) {
+	switch cfg.Type {
+	case "", "config":
+		return NewConfigProvider(configStore, store), nil
+	case "flagd-file":
+		provider, err := NewFlagdFileProvider(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	case "flagd-http":
+		return NewFlagdHTTPProvider(cfg.Address, time.Duration(cfg.Timeout)), nil
+	default:
+		return nil, fmt.Errorf("unknown toggle provider %s", cfg.Type)
+	}
+}
+
+type ProviderMetadata struct {
+	Name string
+}
+
+// Resolution is the answer of a provider, a resolution without value makes the client return the default value
+type Resolution struct {
+	Value   json.RawMessage
+	Variant string
+	// Enabled is the state of a toggle whose value is a variant, IsEnabled returns it instead of decoding the value
+	Enabled      *bool
+	Reason       Reason
+	ErrorCode    ErrorCode
+	ErrorMessage string
+}
+
+// resolutionError is the resolution of a flag that could not be resolved
+func resolutionError(code ErrorCode, message string) Resolution {
+	return Resolution{Reason: ReasonError, ErrorCode: code, ErrorMessage: message}
+}
+
+// EvaluationDetails describe how a flag was evaluated, Value has the type the caller asked for
+type EvaluationDetails struct {
+	FlagKey      string
+	Value        interface{}
+	Variant      string
+	Reason       Reason
+	ErrorCode    ErrorCode
+	ErrorMessage string
+}
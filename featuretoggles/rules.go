@@ -0,0 +1,55 @@
+package featuretoggles
+
+import (
+	"github.com/jenpaff/golang-microservices/config"
+	"hash/fnv"
+	"net/http"
+)
+
+// evaluate applies the rules of the toggle in the order documented on config.FeatureToggle,
+// the reason tells which rule decided
+func evaluate(toggleName string, toggle config.FeatureToggle, evalCtx EvaluationContext, headers http.Header) (bool, Reason) {
+	if evalCtx.UserKey != "" && contains(toggle.DenyUsers, evalCtx.UserKey) {
+		return false, ReasonTargetingMatch
+	}
+	if evalCtx.UserKey != "" && contains(toggle.AllowUsers, evalCtx.UserKey) {
+		return true, ReasonTargetingMatch
+	}
+	if matchesAttributes(toggle.Attributes, evalCtx.Attributes, headers) {
+		return true, ReasonTargetingMatch
+	}
+	if toggle.Percentage != nil && evalCtx.UserKey != "" {
+		return bucket(toggleName, evalCtx.UserKey) < *toggle.Percentage, ReasonSplit
+	}
+	return toggle.Enabled, ReasonStatic
+}
+
+// bucket maps the user to a stable number in [0, 100), the toggle name is hashed as well
+// such that a 5% rollout of one toggle does not hit the same users as 5% of another
+func bucket(toggleName string, userKey string) float64 {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(toggleName + ":" + userKey))
+	return float64(hash.Sum32()%10000) / 100
+}
+
+func matchesAttributes(rules map[string][]string, attributes map[string]string, headers http.Header) bool {
+	for name, values := range rules {
+		value, ok := attributes[name]
+		if !ok {
+			value = headers.Get(name)
+		}
+		if value != "" && contains(values, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
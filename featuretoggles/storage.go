@@ -0,0 +1,183 @@
+//go:generate mockgen -destination=storage_mock.go -package=featuretoggles -self_package=github.com/jenpaff/golang-microservices/featuretoggles github.com/jenpaff/golang-microservices/featuretoggles Storage
+
+package featuretoggles
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jenpaff/golang-microservices/config"
+	custom_errors "github.com/jenpaff/golang-microservices/errors"
+	"github.com/volatiletech/sqlboiler/v4/boil"
+	"time"
+)
+
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionDeleted = "deleted"
+)
+
+// StoredToggle is a toggle saved in the database, it replaces the toggle of the same name in the config files
+type StoredToggle struct {
+	Name      string
+	Toggle    config.FeatureToggle
+	UpdatedBy string
+	UpdatedAt time.Time
+}
+
+// Change records who created, updated or deleted a toggle and when, Toggle is nil for deletions
+type Change struct {
+	ID        int64
+	Name      string
+	Action    string
+	Toggle    *config.FeatureToggle
+	ChangedBy string
+	ChangedAt time.Time
+}
+
+type Storage interface {
+	List(ctx context.Context) ([]StoredToggle, error)
+	// Create fails with a Conflict error if the toggle is stored already
+	Create(ctx context.Context, toggle StoredToggle) error
+	// Save creates or replaces the toggle
+	Save(ctx context.Context, toggle StoredToggle) error
+	// Delete fails with a ToggleNotFound error if the toggle is not stored
+	Delete(ctx context.Context, name string, deletedBy string, deletedAt time.Time) error
+	Changes(ctx context.Context, name string) ([]Change, error)
+}
+
+type storage struct {
+	db boil.ContextExecutor
+}
+
+// NewStorage takes a *sql.DB or a tracing.DB
+func NewStorage(db boil.ContextExecutor) Storage {
+	return &storage{db: db}
+}
+
+func (p *storage) List(ctx context.Context) ([]StoredToggle, error) {
+	rows, err := p.db.QueryContext(ctx, "SELECT name, definition, updated_by, updated_at FROM feature_toggles ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("error listing feature toggles: %s: %w", err.Error(), custom_errors.DatabaseError)
+	}
+	defer rows.Close()
+
+	var toggles []StoredToggle
+	for rows.Next() {
+		var toggle StoredToggle
+		var definition []byte
+		err = rows.Scan(&toggle.Name, &definition, &toggle.UpdatedBy, &toggle.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error reading feature toggle: %s: %w", err.Error(), custom_errors.DatabaseError)
+		}
+		err = json.Unmarshal(definition, &toggle.Toggle)
+		if err != nil {
+			return nil, fmt.Errorf("invalid definition of feature toggle %s: %s: %w", toggle.Name, err.Error(), custom_errors.DatabaseError)
+		}
+		toggles = append(toggles, toggle)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing feature toggles: %s: %w", err.Error(), custom_errors.DatabaseError)
+	}
+	return toggles, nil
+}
+
+func (p *storage) Create(ctx context.Context, toggle StoredToggle) error {
+	definition, err := json.Marshal(toggle.Toggle)
+	if err != nil {
+		return err
+	}
+	// the toggle and its change are written by one statement such that they cannot get out of sync
+	var id int64
+	err = p.db.QueryRowContext(ctx,
+		`WITH created AS (
+			INSERT INTO feature_toggles (name, definition, updated_by, updated_at) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (name) DO NOTHING
+			RETURNING name, definition, updated_by, updated_at
+		)
+		INSERT INTO feature_toggle_changes (name, action, definition, changed_by, changed_at)
+		SELECT name, $5, definition, updated_by, updated_at FROM created
+		RETURNING id`,
+		toggle.Name, definition, toggle.UpdatedBy, toggle.UpdatedAt, ActionCreated).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return custom_errors.NewConflictError("name")
+		}
+		return fmt.Errorf("error creating feature toggle %s: %s: %w", toggle.Name, err.Error(), custom_errors.DatabaseError)
+	}
+	return nil
+}
+
+func (p *storage) Save(ctx context.Context, toggle StoredToggle) error {
+	definition, err := json.Marshal(toggle.Toggle)
+	if err != nil {
+		return err
+	}
+	// xmax is 0 for inserted rows, it tells creations and updates apart
+	_, err = p.db.ExecContext(ctx,
+		`WITH saved AS (
+			INSERT INTO feature_toggles (name, definition, updated_by, updated_at) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (name) DO UPDATE SET definition = EXCLUDED.definition, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+			RETURNING name, definition, updated_by, updated_at, xmax = 0 AS created
+		)
+		INSERT INTO feature_toggle_changes (name, action, definition, changed_by, changed_at)
+		SELECT name, CASE WHEN created THEN $5 ELSE $6 END, definition, updated_by, updated_at FROM saved`,
+		toggle.Name, definition, toggle.UpdatedBy, toggle.UpdatedAt, ActionCreated, ActionUpdated)
+	if err != nil {
+		return fmt.Errorf("error saving feature toggle %s: %s: %w", toggle.Name, err.Error(), custom_errors.DatabaseError)
+	}
+	return nil
+}
+
+func (p *storage) Delete(ctx context.Context, name string, deletedBy string, deletedAt time.Time) error {
+	var id int64
+	err := p.db.QueryRowContext(ctx,
+		`WITH deleted AS (
+			DELETE FROM feature_toggles WHERE name = $1 RETURNING name
+		)
+		INSERT INTO feature_toggle_changes (name, action, changed_by, changed_at)
+		SELECT name, $2, $3, $4 FROM deleted
+		RETURNING id`,
+		name, ActionDeleted, deletedBy, deletedAt).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("feature toggle %s is not stored: %w", name, custom_errors.ToggleNotFound)
+		}
+		return fmt.Errorf("error deleting feature toggle %s: %s: %w", name, err.Error(), custom_errors.DatabaseError)
+	}
+	return nil
+}
+
+func (p *storage) Changes(ctx context.Context, name string) ([]Change, error) {
+	rows, err := p.db.QueryContext(ctx,
+		"SELECT id, name, action, definition, changed_by, changed_at FROM feature_toggle_changes WHERE name = $1 ORDER BY id", name)
+	if err != nil {
+		return nil, fmt.Errorf("error listing changes of feature toggle %s: %s: %w", name, err.Error(), custom_errors.DatabaseError)
+	}
+	defer rows.Close()
+
+	var changes []Change
+	for rows.Next() {
+		var change Change
+		var definition []byte
+		err = rows.Scan(&change.ID, &change.Name, &change.Action, &definition, &change.ChangedBy, &change.ChangedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error reading change of feature toggle %s: %s: %w", name, err.Error(), custom_errors.DatabaseError)
+		}
+		if definition != nil {
+			change.Toggle = &config.FeatureToggle{}
+			err = json.Unmarshal(definition, change.Toggle)
+			if err != nil {
+				return nil, fmt.Errorf("invalid definition in change %d of feature toggle %s: %s: %w", change.ID, name, err.Error(), custom_errors.DatabaseError)
+			}
+		}
+		changes = append(changes, change)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing changes of feature toggle %s: %s: %w", name, err.Error(), custom_errors.DatabaseError)
+	}
+	return changes, nil
+}
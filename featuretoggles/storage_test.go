@@ -0,0 +1,85 @@
+//+build integration
+
+package featuretoggles_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jenpaff/golang-microservices/config"
+	custom_errors "github.com/jenpaff/golang-microservices/errors"
+	"github.com/jenpaff/golang-microservices/featuretoggles"
+	"github.com/jenpaff/golang-microservices/persistence"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("Storage", func() {
+
+	ctx := context.Background()
+	var sqlDB *sql.DB
+	var storage featuretoggles.Storage
+	var err error
+	var cfg config.Config
+
+	BeforeSuite(func() {
+		cfg, err = config.BuildConfig("../config/test.json", "", "")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	BeforeEach(func() {
+		sqlDB, err = persistence.ConnectPostgres(cfg.Persistence)
+		Expect(err).ToNot(HaveOccurred())
+		storage = featuretoggles.NewStorage(sqlDB)
+	})
+
+	AfterEach(func() {
+		_, err = sqlDB.Exec("delete from feature_toggles")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = sqlDB.Exec("delete from feature_toggle_changes")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sqlDB.Close()).To(Succeed())
+	})
+
+	It("should record who created, updated and deleted a toggle", func() {
+		percentage := 5.0
+		toggle := featuretoggles.StoredToggle{Name: "toggle1", Toggle: config.FeatureToggle{Percentage: &percentage}, UpdatedBy: "jane", UpdatedAt: time.Now()}
+		Expect(storage.Create(ctx, toggle)).To(Succeed())
+
+		toggle.Toggle = config.FeatureToggle{Enabled: true}
+		toggle.UpdatedBy = "john"
+		Expect(storage.Save(ctx, toggle)).To(Succeed())
+
+		toggles, err := storage.List(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(toggles).To(HaveLen(1))
+		Expect(toggles[0].Toggle).To(Equal(config.FeatureToggle{Enabled: true}))
+		Expect(toggles[0].UpdatedBy).To(Equal("john"))
+
+		Expect(storage.Delete(ctx, "toggle1", "jane", time.Now())).To(Succeed())
+
+		changes, err := storage.Changes(ctx, "toggle1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changes).To(HaveLen(3))
+		Expect(changes[0].Action).To(Equal(featuretoggles.ActionCreated))
+		Expect(*changes[0].Toggle.Percentage).To(Equal(5.0))
+		Expect(changes[1].Action).To(Equal(featuretoggles.ActionUpdated))
+		Expect(changes[1].ChangedBy).To(Equal("john"))
+		Expect(changes[2].Action).To(Equal(featuretoggles.ActionDeleted))
+		Expect(changes[2].Toggle).To(BeNil())
+	})
+
+	It("should not create a toggle twice", func() {
+		toggle := featuretoggles.StoredToggle{Name: "toggle1", UpdatedBy: "jane", UpdatedAt: time.Now()}
+		Expect(storage.Create(ctx, toggle)).To(Succeed())
+
+		err := storage.Create(ctx, toggle)
+		Expect(errors.Is(err, custom_errors.Conflict)).To(BeTrue())
+	})
+
+	It("should not delete a toggle that is not stored", func() {
+		err := storage.Delete(ctx, "unknown", "jane", time.Now())
+		Expect(errors.Is(err, custom_errors.ToggleNotFound)).To(BeTrue())
+	})
+})
@@ -0,0 +1,126 @@
+package featuretoggles
+
+import (
+	"context"
+	"github.com/go-playground/log"
+	"github.com/jenpaff/golang-microservices/config"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Store keeps the toggles of the storage in memory such that evaluating toggles does not query the database,
+// Poll picks up changes made by other instances
+type Store struct {
+	storage Storage
+	toggles atomic.Value
+
+	mu sync.Mutex
+}
+
+func NewStore(storage Storage) *Store {
+	store := &Store{storage: storage}
+	store.toggles.Store([]StoredToggle{})
+	return store
+}
+
+// Toggles returns the stored toggles by name, a nil Store has none
+func (s *Store) Toggles() config.FeatureToggles {
+	if s == nil {
+		return nil
+	}
+	stored := s.List()
+	toggles := make(config.FeatureToggles, len(stored))
+	for _, toggle := range stored {
+		toggles[toggle.Name] = toggle.Toggle
+	}
+	return toggles
+}
+
+// List returns the stored toggles with who changed them last, ordered by name
+func (s *Store) List() []StoredToggle {
+	return s.toggles.Load().([]StoredToggle)
+}
+
+// Refresh reloads the toggles, there are few of them, so they are always read instead of tracking changes:
+// the ids of changes are assigned in insert order but committed in any order, a change committed after a change
+// with a higher id would never be picked up
+func (s *Store) Refresh(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	toggles, err := s.storage.List(ctx)
+	if err != nil {
+		return err
+	}
+	if toggles == nil {
+		toggles = []StoredToggle{}
+	}
+	s.toggles.Store(toggles)
+	return nil
+}
+
+// Poll refreshes the toggles every interval until ctx is done
+func (s *Store) Poll(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := s.Refresh(ctx)
+			if err != nil {
+				log.WithError(err).Error("could not refresh the stored feature toggles, keeping the current ones")
+			}
+		}
+	}
+}
+
+// Create stores a new toggle, it fails with a Conflict error if the toggle is stored already
+func (s *Store) Create(ctx context.Context, name string, toggle config.FeatureToggle, author string) error {
+	err := s.storage.Create(ctx, StoredToggle{Name: name, Toggle: toggle, UpdatedBy: author, UpdatedAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	return s.Refresh(ctx)
+}
+
+// Save creates or replaces the stored toggle
+func (s *Store) Save(ctx context.Context, name string, toggle config.FeatureToggle, author string) error {
+	err := s.storage.Save(ctx, StoredToggle{Name: name, Toggle: toggle, UpdatedBy: author, UpdatedAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	return s.Refresh(ctx)
+}
+
+// Delete removes the stored toggle, the toggle of the config files applies again
+func (s *Store) Delete(ctx context.Context, name string, author string) error {
+	err := s.storage.Delete(ctx, name, author, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return s.Refresh(ctx)
+}
+
+func (s *Store) Changes(ctx context.Context, name string) ([]Change, error) {
+	return s.storage.Changes(ctx, name)
+}
+
+// EffectiveToggles are the toggles of the config files replaced by the stored toggles of the same name
+func EffectiveToggles(defaults config.FeatureToggles, store *Store) config.FeatureToggles {
+	stored := store.Toggles()
+	if len(stored) == 0 {
+		return defaults
+	}
+	toggles := make(config.FeatureToggles, len(defaults)+len(stored))
+	for name, toggle := range defaults {
+		toggles[name] = toggle
+	}
+	for name, toggle := range stored {
+		toggles[name] = toggle
+	}
+	return toggles
+}
@@ -0,0 +1,86 @@
+//+build unit
+
+package featuretoggles
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/jenpaff/golang-microservices/config"
+	"github.com/jenpaff/golang-microservices/test-helper"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store", func() {
+
+	ctx := context.Background()
+	var mockCtrl *gomock.Controller
+	var storage *MockStorage
+	var store *Store
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(test_helper.GinkgoTestReporter{})
+		storage = NewMockStorage(mockCtrl)
+		store = NewStore(storage)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("should reload the toggles on every refresh", func() {
+		storage.EXPECT().List(ctx).Return([]StoredToggle{{Name: "toggle1", Toggle: config.FeatureToggle{Enabled: true}}}, nil)
+		Expect(store.Refresh(ctx)).To(Succeed())
+		Expect(store.Toggles()).To(Equal(config.FeatureToggles{"toggle1": {Enabled: true}}))
+
+		storage.EXPECT().List(ctx).Return(nil, nil)
+		Expect(store.Refresh(ctx)).To(Succeed())
+		Expect(store.Toggles()).To(BeEmpty())
+	})
+
+	It("should keep the loaded toggles if the storage fails", func() {
+		storage.EXPECT().List(ctx).Return([]StoredToggle{{Name: "toggle1", Toggle: config.FeatureToggle{Enabled: true}}}, nil)
+		Expect(store.Refresh(ctx)).To(Succeed())
+
+		storage.EXPECT().List(ctx).Return(nil, errors.New("connection refused"))
+		Expect(store.Refresh(ctx)).ToNot(Succeed())
+		Expect(store.Toggles()).To(HaveKey("toggle1"))
+	})
+
+	It("should refresh after saving a toggle", func() {
+		storage.EXPECT().Save(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, toggle StoredToggle) error {
+			Expect(toggle.Name).To(Equal("toggle1"))
+			Expect(toggle.UpdatedBy).To(Equal("jane"))
+			Expect(toggle.UpdatedAt).ToNot(BeZero())
+			return nil
+		})
+		storage.EXPECT().List(ctx).Return([]StoredToggle{{Name: "toggle1", Toggle: config.FeatureToggle{Enabled: true}}}, nil)
+
+		Expect(store.Save(ctx, "toggle1", config.FeatureToggle{Enabled: true}, "jane")).To(Succeed())
+		Expect(store.Toggles()).To(HaveKey("toggle1"))
+	})
+
+	It("should use the config toggles as defaults for the stored toggles", func() {
+		storage.EXPECT().List(ctx).Return([]StoredToggle{{Name: "toggle1", Toggle: config.FeatureToggle{Enabled: true}}}, nil)
+		Expect(store.Refresh(ctx)).To(Succeed())
+
+		appConfig := config.Config{
+			FeatureToggles: config.FeatureToggles{
+				"toggle1": {Enabled: false},
+				"toggle2": {Enabled: true},
+			},
+		}
+		ft, err := newClient(appConfig, store, nil).ForRequest(createFakeRequest(""))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ft.IsEnabled(EvaluationContext{}, "toggle1")).To(BeTrue())
+		Expect(ft.IsEnabled(EvaluationContext{}, "toggle2")).To(BeTrue())
+	})
+
+	It("should use the config toggles without a store", func() {
+		appConfig := config.Config{FeatureToggles: config.FeatureToggles{"toggle1": {Enabled: true}}}
+		ft, err := newClient(appConfig, nil, nil).ForRequest(createFakeRequest(""))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ft.IsEnabled(EvaluationContext{}, "toggle1")).To(BeTrue())
+	})
+})
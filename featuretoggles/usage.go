@@ -0,0 +1,121 @@
+package featuretoggles
+
+import (
+	"fmt"
+	"github.com/jenpaff/golang-microservices/config"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// evaluationMethods are the methods of FeatureToggles that take the toggle name after the evaluation context
+var evaluationMethods = map[string]bool{
+	"IsEnabled":   true,
+	"Variant":     true,
+	"StringValue": true,
+	"IntValue":    true,
+	"JSONValue":   true,
+	"Details":     true,
+}
+
+// Usage is a place in the source that evaluates a toggle
+type Usage struct {
+	Toggle   string
+	Position token.Position
+}
+
+// UsageReport compares the toggles evaluated in the source with the defined toggles
+type UsageReport struct {
+	// Unused toggles are defined but never evaluated, they can probably be removed
+	Unused []string
+	// Undefined toggles are evaluated but not defined, they always get the default
+	Undefined []Usage
+}
+
+// HasFindings tells whether there is anything to clean up
+func (r UsageReport) HasFindings() bool {
+	return len(r.Unused) > 0 || len(r.Undefined) > 0
+}
+
+// ScanUsages finds the toggles evaluated with a string literal such as IsEnabled(evalCtx, "enableNewFeature")
+// in the Go files below root, tests, vendor, testdata and hidden directories are skipped, toggles whose name
+// is not a literal cannot be found
+func ScanUsages(root string) ([]Usage, error) {
+	fileSet := token.NewFileSet()
+	usages := make([]Usage, 0)
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			name := entry.Name()
+			if path != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+
+		file, err := parser.ParseFile(fileSet, path, nil, 0)
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %s", path, err.Error())
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			if toggle, ok := evaluatedToggle(node); ok {
+				usages = append(usages, Usage{Toggle: toggle, Position: fileSet.Position(node.Pos())})
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
+
+func evaluatedToggle(node ast.Node) (string, bool) {
+	call, ok := node.(*ast.CallExpr)
+	if !ok || len(call.Args) < 2 {
+		return "", false
+	}
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || !evaluationMethods[selector.Sel.Name] {
+		return "", false
+	}
+	literal, ok := call.Args[1].(*ast.BasicLit)
+	if !ok || literal.Kind != token.STRING {
+		return "", false
+	}
+	toggle, err := strconv.Unquote(literal.Value)
+	if err != nil {
+		return "", false
+	}
+	return toggle, true
+}
+
+// CheckUsages reports the defined toggles without usages and the usages of undefined toggles
+func CheckUsages(toggles config.FeatureToggles, usages []Usage) UsageReport {
+	used := make(map[string]bool, len(usages))
+	report := UsageReport{Unused: make([]string, 0), Undefined: make([]Usage, 0)}
+	for _, usage := range usages {
+		used[usage.Toggle] = true
+		if _, ok := toggles[usage.Toggle]; !ok {
+			report.Undefined = append(report.Undefined, usage)
+		}
+	}
+	for name := range toggles {
+		if !used[name] {
+			report.Unused = append(report.Unused, name)
+		}
+	}
+	sort.Strings(report.Unused)
+	return report
+}
@@ -0,0 +1,79 @@
+package featuretoggles
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-playground/log"
+	"github.com/jenpaff/golang-microservices/config"
+	"time"
+)
+
+// Variant is the variant of a toggle chosen for a user, Name is empty if the toggle has none for the user
+type Variant struct {
+	Name  string
+	Value json.RawMessage
+}
+
+// chooseVariant splits the users a toggle is enabled for among the variants by their weight,
+// the default variant is chosen if the toggle is disabled or there is no user to split by
+func chooseVariant(toggleName string, toggle config.FeatureToggle, enabled bool, userKey string) (config.Variant, Reason, bool) {
+	if !toggle.HasVariants() {
+		return config.Variant{}, ReasonDefault, false
+	}
+	defaultVariant, hasDefault := toggle.FindVariant(toggle.DefaultVariant)
+	if !enabled || userKey == "" {
+		return defaultVariant, ReasonDefault, hasDefault
+	}
+
+	var totalWeight float64
+	for _, variant := range toggle.Variants {
+		totalWeight += variant.Weight
+	}
+	if totalWeight == 0 {
+		return defaultVariant, ReasonDefault, hasDefault
+	}
+
+	// the percentage rollout hashes the toggle name alone, an own salt keeps the variants
+	// evenly split among the users of a partial rollout
+	point := bucket(toggleName+":variants", userKey) / 100 * totalWeight
+	for _, variant := range toggle.Variants {
+		if point < variant.Weight {
+			return variant, ReasonSplit, true
+		}
+		point -= variant.Weight
+	}
+	return toggle.Variants[len(toggle.Variants)-1], ReasonSplit, true
+}
+
+// Exposure records that a user was shown a variant, experiments are evaluated from these events
+type Exposure struct {
+	Toggle  string
+	Variant string
+	UserKey string
+	Time    time.Time
+}
+
+type ExposureSink interface {
+	Record(ctx context.Context, exposure Exposure)
+}
+
+// ExposureSinkFunc adapts a function to an ExposureSink
+type ExposureSinkFunc func(ctx context.Context, exposure Exposure)
+
+func (f ExposureSinkFunc) Record(ctx context.Context, exposure Exposure) {
+	f(ctx, exposure)
+}
+
+// LogExposureSink writes exposures to the log, marked with the field "event" for log based analytics
+type LogExposureSink struct{}
+
+func (LogExposureSink) Record(ctx context.Context, exposure Exposure) {
+	log.GetContext(ctx).
+		WithFields(
+			log.F("event", "toggle_exposure"),
+			log.F("toggle", exposure.Toggle),
+			log.F("variant", exposure.Variant),
+			log.F("user", exposure.UserKey),
+		).
+		Infof("user '%v' exposed to variant '%v' of toggle '%v'", exposure.UserKey, exposure.Variant, exposure.Toggle)
+}
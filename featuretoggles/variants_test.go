@@ -0,0 +1,177 @@
+//+build unit
+
+package featuretoggles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jenpaff/golang-microservices/config"
+	custom_errors "github.com/jenpaff/golang-microservices/errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("Variants", func() {
+
+	var appConfig config.Config
+	var exposures []Exposure
+	var sink ExposureSink
+
+	BeforeEach(func() {
+		appConfig = config.Config{
+			FeatureToggles: config.FeatureToggles{
+				"plain": {Enabled: true},
+				"checkoutButton": {
+					Enabled:        true,
+					DefaultVariant: "blue",
+					Overridable:    true,
+					Variants: []config.Variant{
+						{Name: "blue", Value: json.RawMessage(`"#00f"`), Weight: 50},
+						{Name: "green", Value: json.RawMessage(`"#0f0"`), Weight: 30},
+						{Name: "red", Value: json.RawMessage(`"#f00"`), Weight: 20},
+					},
+				},
+				"pageSize": {
+					Enabled:  true,
+					Variants: []config.Variant{{Name: "large", Value: json.RawMessage(`50`), Weight: 1}},
+				},
+				"limits": {
+					Enabled:  true,
+					Variants: []config.Variant{{Name: "strict", Value: json.RawMessage(`{"perMinute": 10}`), Weight: 1}},
+				},
+			},
+			ToggleOverrides: config.ToggleOverridesConfig{Source: OverridesQuery},
+		}
+		exposures = nil
+		sink = ExposureSinkFunc(func(_ context.Context, exposure Exposure) {
+			exposures = append(exposures, exposure)
+		})
+	})
+
+	newToggles := func(queryParams string) FeatureToggles {
+		ft, err := newClient(appConfig, nil, sink).ForRequest(createFakeRequest(queryParams))
+		Expect(err).ToNot(HaveOccurred())
+		return ft
+	}
+
+	It("should split users by the weight of the variants and keep their variant", func() {
+		counts := make(map[string]int)
+		for i := 0; i < 10000; i++ {
+			user := EvaluationContext{UserKey: fmt.Sprintf("user-%d", i)}
+			variant := newToggles("").Variant(user, "checkoutButton")
+			Expect(newToggles("").Variant(user, "checkoutButton")).To(Equal(variant))
+			counts[variant.Name]++
+		}
+
+		Expect(counts["blue"]).To(BeNumerically("~", 5000, 300))
+		Expect(counts["green"]).To(BeNumerically("~", 3000, 300))
+		Expect(counts["red"]).To(BeNumerically("~", 2000, 300))
+	})
+
+	It("should choose the default variant if the toggle is disabled or there is no user", func() {
+		Expect(newToggles("").StringValue(EvaluationContext{}, "checkoutButton", "#000")).To(Equal("#00f"))
+
+		toggle := appConfig.FeatureToggles["checkoutButton"]
+		toggle.Enabled = false
+		toggle.AllowUsers = []string{"tester"}
+		appConfig.FeatureToggles["checkoutButton"] = toggle
+		for i := 0; i < 100; i++ {
+			Expect(newToggles("").Variant(EvaluationContext{UserKey: fmt.Sprintf("user-%d", i)}, "checkoutButton").Name).To(Equal("blue"))
+		}
+		Expect(newToggles("").Variant(EvaluationContext{UserKey: "tester"}, "checkoutButton").Name).ToNot(BeEmpty())
+	})
+
+	It("should keep variant toggles working as on/off toggles", func() {
+		var errs []error
+		hook := &testHook{error: func(err error) { errs = append(errs, err) }}
+		newHookedToggles := func() FeatureToggles {
+			ft, err := NewClient(NewConfigProvider(config.NewStore(appConfig), nil), sink, hook).ForRequest(createFakeRequest(""))
+			Expect(err).ToNot(HaveOccurred())
+			return ft
+		}
+
+		Expect(newHookedToggles().IsEnabled(EvaluationContext{UserKey: "user-1"}, "checkoutButton")).To(BeTrue())
+		Expect(newHookedToggles().IsEnabled(EvaluationContext{UserKey: "user-1"}, "pageSize")).To(BeTrue())
+
+		toggle := appConfig.FeatureToggles["checkoutButton"]
+		toggle.Enabled = false
+		toggle.AllowUsers = []string{"tester"}
+		appConfig.FeatureToggles["checkoutButton"] = toggle
+		Expect(newHookedToggles().IsEnabled(EvaluationContext{UserKey: "user-1"}, "checkoutButton")).To(BeFalse())
+		Expect(newHookedToggles().IsEnabled(EvaluationContext{UserKey: "tester"}, "checkoutButton")).To(BeTrue())
+
+		Expect(errs).To(BeEmpty())
+		Expect(exposures).To(BeEmpty())
+	})
+
+	It("should return the typed defaults if there is no variant or it does not fit", func() {
+		ft := newToggles("")
+		user := EvaluationContext{UserKey: "user-1"}
+
+		Expect(ft.Variant(user, "plain")).To(Equal(Variant{}))
+		Expect(ft.StringValue(user, "plain", "default")).To(Equal("default"))
+		Expect(ft.StringValue(user, "unknown", "default")).To(Equal("default"))
+		Expect(ft.IntValue(user, "pageSize", 20)).To(Equal(50))
+		Expect(ft.IntValue(user, "checkoutButton", 20)).To(Equal(20))
+		Expect(ft.StringValue(user, "pageSize", "default")).To(Equal("default"))
+	})
+
+	It("should decode JSON values", func() {
+		limits := struct {
+			PerMinute int `json:"perMinute"`
+		}{PerMinute: 100}
+
+		Expect(newToggles("").JSONValue(EvaluationContext{UserKey: "user-1"}, "limits", &limits)).To(BeTrue())
+		Expect(limits.PerMinute).To(Equal(10))
+
+		Expect(newToggles("").JSONValue(EvaluationContext{UserKey: "user-1"}, "limits", limits)).To(BeFalse())
+	})
+
+	It("should record the exposure of a user once per request", func() {
+		ft := newToggles("")
+		user := EvaluationContext{UserKey: "user-1"}
+
+		variant := ft.Variant(user, "checkoutButton")
+		ft.StringValue(user, "checkoutButton", "#000")
+		ft.IsEnabled(user, "checkoutButton")
+		ft.Variant(user, "plain")
+
+		Expect(exposures).To(HaveLen(1))
+		Expect(exposures[0].Toggle).To(Equal("checkoutButton"))
+		Expect(exposures[0].Variant).To(Equal(variant.Name))
+		Expect(exposures[0].UserKey).To(Equal("user-1"))
+		Expect(exposures[0].Time).To(BeTemporally("~", time.Now(), time.Minute))
+	})
+
+	It("should override the variant from the request", func() {
+		ft := newToggles("checkoutButton=red")
+
+		for i := 0; i < 10; i++ {
+			Expect(ft.StringValue(EvaluationContext{UserKey: fmt.Sprintf("user-%d", i)}, "checkoutButton", "#000")).To(Equal("#f00"))
+		}
+		Expect(ft.IsEnabled(EvaluationContext{}, "checkoutButton")).To(BeTrue())
+		var color string
+		details := ft.Details(EvaluationContext{}, "checkoutButton", &color)
+		Expect(details.Reason).To(Equal(ReasonOverride))
+		Expect(details.Variant).To(Equal("red"))
+
+		Expect(newToggles("checkoutButton=false").Variant(EvaluationContext{UserKey: "user-1"}, "checkoutButton").Name).To(Equal("blue"))
+
+		_, err := newClient(appConfig, nil, sink).ForRequest(createFakeRequest("checkoutButton=purple"))
+		Expect(errors.Is(err, custom_errors.InvalidToggleOverride)).To(BeTrue())
+	})
+
+	It("should override the variant from a signed header", func() {
+		appConfig.ToggleOverrides = config.ToggleOverridesConfig{Source: OverridesHeader, SigningKey: "key"}
+		request := createFakeRequest("")
+		request.Request.Header.Set(HeaderOverrides, SignVariantOverrides("key", map[string]string{"checkoutButton": "green"}, time.Now().Add(time.Hour)))
+
+		ft, err := newClient(appConfig, nil, sink).ForRequest(request)
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ft.Variant(EvaluationContext{UserKey: "user-1"}, "checkoutButton").Name).To(Equal("green"))
+	})
+})
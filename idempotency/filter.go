@@ -0,0 +1,155 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/go-playground/log"
+	"github.com/jenpaff/golang-microservices/errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	HeaderReplayed       = "Idempotent-Replayed"
+	maxKeyLength         = 255
+)
+
+// Filter makes a route idempotent for requests carrying an Idempotency-Key header:
+// the first response is stored and replayed for retries with the same key and request within ttl,
+// reusing the key for a different request is rejected. The key is reserved before the request is processed,
+// a retry arriving while the first request is still processed gets a conflict and can retry later
+func Filter(storage Storage, ttl time.Duration) restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		key := req.HeaderParameter(HeaderIdempotencyKey)
+		if key == "" {
+			chain.ProcessFilter(req, resp)
+			return
+		}
+
+		if len(key) > maxKeyLength {
+			errors.WriteError(req, resp, fmt.Errorf("idempotency key must not be longer than %d characters: %w", maxKeyLength, errors.BadRequest))
+			return
+		}
+
+		body, err := ioutil.ReadAll(req.Request.Body)
+		if err != nil {
+			errors.WriteError(req, resp, fmt.Errorf("could not read request body: %w", err))
+			return
+		}
+		// the route still needs to read the body
+		req.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		requestHash := hashRequest(req.Request, body)
+
+		ctx := req.Request.Context()
+		now := time.Now()
+		reserved, err := storage.Reserve(ctx, key, requestHash, now, now.Add(-ttl))
+		if err != nil {
+			errors.WriteError(req, resp, err)
+			return
+		}
+
+		storedResponses, err := storage.Find(ctx, key)
+		if err != nil {
+			if reserved {
+				release(storage, req, key, requestHash)
+			}
+			errors.WriteError(req, resp, err)
+			return
+		}
+
+		var own *StoredResponse
+		for i, storedResponse := range storedResponses {
+			if now.Sub(storedResponse.CreatedAt) >= ttl {
+				continue
+			}
+			if storedResponse.RequestHash != requestHash {
+				// if two different requests reserve the key at the same time, both are rejected
+				if reserved {
+					release(storage, req, key, requestHash)
+				}
+				errors.WriteError(req, resp, fmt.Errorf("idempotency key %s was already used for a different request: %w", key, errors.IdempotencyKeyReused))
+				return
+			}
+			own = &storedResponses[i]
+		}
+
+		if !reserved {
+			if own == nil || own.State != StateCompleted {
+				errors.WriteError(req, resp, fmt.Errorf("request with idempotency key %s is still being processed: %w", key, errors.IdempotentRequestInProgress))
+				return
+			}
+			log.Infof("replaying stored response for idempotency key %s", key)
+			replay(resp, own)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: resp.ResponseWriter}
+		resp.ResponseWriter = recorder
+
+		chain.ProcessFilter(req, resp)
+
+		if resp.StatusCode() >= http.StatusInternalServerError {
+			// server errors are not stored such that the client can retry the request
+			release(storage, req, key, requestHash)
+			return
+		}
+
+		err = storage.Complete(ctx, key, StoredResponse{
+			RequestHash: requestHash,
+			State:       StateCompleted,
+			StatusCode:  resp.StatusCode(),
+			ContentType: resp.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+			CreatedAt:   now,
+		})
+		if err != nil {
+			// the response was already sent, a retry will be processed again
+			log.Errorf("could not store response for idempotency key %s: %s", key, err.Error())
+			release(storage, req, key, requestHash)
+		}
+	}
+}
+
+// release lets retries process the request again, a reservation that cannot be released expires after the ttl
+func release(storage Storage, req *restful.Request, key string, requestHash string) {
+	err := storage.Release(req.Request.Context(), key, requestHash)
+	if err != nil {
+		log.Errorf("could not release idempotency key %s: %s", key, err.Error())
+	}
+}
+
+func hashRequest(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(req.Method))
+	hash.Write([]byte(req.URL.Path))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func replay(resp *restful.Response, storedResponse *StoredResponse) {
+	if storedResponse.ContentType != "" {
+		resp.Header().Set("Content-Type", storedResponse.ContentType)
+	}
+	resp.Header().Set(HeaderReplayed, "true")
+	resp.WriteHeader(storedResponse.StatusCode)
+	_, err := resp.Write(storedResponse.Body)
+	if err != nil {
+		log.Errorf("could not write stored response: %s", err.Error())
+	}
+}
+
+// responseRecorder passes the response through while keeping a copy of the body
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(bytes []byte) (int, error) {
+	r.body.Write(bytes)
+	return r.ResponseWriter.Write(bytes)
+}
@@ -0,0 +1,267 @@
+//+build unit
+
+package idempotency_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/golang/mock/gomock"
+	custom_errors "github.com/jenpaff/golang-microservices/errors"
+	"github.com/jenpaff/golang-microservices/idempotency"
+	test_helper "github.com/jenpaff/golang-microservices/test-helper"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+var _ = Describe("Filter", func() {
+
+	var mockCtrl *gomock.Controller
+	var storageMock *idempotency.MockStorage
+	var container *restful.Container
+	var invocations int
+
+	ttl := time.Hour
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(test_helper.GinkgoTestReporter{})
+		storageMock = idempotency.NewMockStorage(mockCtrl)
+		invocations = 0
+
+		ws := new(restful.WebService)
+		ws.Route(ws.POST("/things").
+			Filter(idempotency.Filter(storageMock, ttl)).
+			Produces(restful.MIME_JSON).
+			To(func(req *restful.Request, resp *restful.Response) {
+				invocations++
+				body, _ := ioutil.ReadAll(req.Request.Body)
+				if string(body) == "fail" {
+					resp.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				_ = resp.WriteHeaderAndEntity(http.StatusCreated, map[string]string{"received": string(body)})
+			}))
+		container = restful.NewContainer()
+		container.Add(ws)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	post := func(key string, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/things", bytes.NewReader([]byte(body)))
+		if key != "" {
+			req.Header.Set(idempotency.HeaderIdempotencyKey, key)
+		}
+		container.ServeHTTP(rr, req)
+		return rr
+	}
+
+	It("passes requests without an idempotency key through", func() {
+		rr := post("", "body")
+
+		Expect(rr.Code).To(Equal(http.StatusCreated))
+		Expect(invocations).To(Equal(1))
+	})
+
+	It("reserves the key and stores the first response", func() {
+		storageMock.EXPECT().Reserve(gomock.Any(), "key-1", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
+		storageMock.EXPECT().Find(gomock.Any(), "key-1").Return(nil, nil)
+		storageMock.EXPECT().Complete(gomock.Any(), "key-1", gomock.Any()).DoAndReturn(
+			func(_ interface{}, _ string, response idempotency.StoredResponse) error {
+				Expect(response.StatusCode).To(Equal(http.StatusCreated))
+				Expect(string(response.Body)).To(ContainSubstring("body"))
+				Expect(response.RequestHash).ToNot(BeEmpty())
+				return nil
+			})
+
+		rr := post("key-1", "body")
+
+		Expect(rr.Code).To(Equal(http.StatusCreated))
+		Expect(invocations).To(Equal(1))
+	})
+
+	It("replays the stored response for a retry with the same request", func() {
+		var stored idempotency.StoredResponse
+		storageMock.EXPECT().Reserve(gomock.Any(), "key-1", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
+		storageMock.EXPECT().Find(gomock.Any(), "key-1").Return(nil, nil)
+		storageMock.EXPECT().Complete(gomock.Any(), "key-1", gomock.Any()).DoAndReturn(
+			func(_ interface{}, _ string, response idempotency.StoredResponse) error {
+				stored = response
+				return nil
+			})
+		first := post("key-1", "body")
+
+		storageMock.EXPECT().Reserve(gomock.Any(), "key-1", stored.RequestHash, gomock.Any(), gomock.Any()).Return(false, nil)
+		storageMock.EXPECT().Find(gomock.Any(), "key-1").Return([]idempotency.StoredResponse{stored}, nil)
+		retry := post("key-1", "body")
+
+		Expect(invocations).To(Equal(1))
+		Expect(retry.Code).To(Equal(first.Code))
+		Expect(retry.Body.String()).To(Equal(first.Body.String()))
+		Expect(retry.Header().Get(idempotency.HeaderReplayed)).To(Equal("true"))
+	})
+
+	It("rejects a retry with the same key but a different request", func() {
+		storageMock.EXPECT().Reserve(gomock.Any(), "key-1", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
+		storageMock.EXPECT().Find(gomock.Any(), "key-1").Return([]idempotency.StoredResponse{{
+			RequestHash: "another-hash",
+			State:       idempotency.StateCompleted,
+			StatusCode:  http.StatusCreated,
+			CreatedAt:   time.Now(),
+		}}, nil)
+		storageMock.EXPECT().Release(gomock.Any(), "key-1", gomock.Any()).Return(nil)
+
+		rr := post("key-1", "other body")
+
+		Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(invocations).To(Equal(0))
+		var errorResponse custom_errors.ErrorResponse
+		err := json.Unmarshal(rr.Body.Bytes(), &errorResponse)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(errorResponse.ErrorID).To(Equal(custom_errors.IdempotencyKeyReused.Error()))
+	})
+
+	It("processes the request again once the stored response of another request expired", func() {
+		storageMock.EXPECT().Reserve(gomock.Any(), "key-1", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
+		storageMock.EXPECT().Find(gomock.Any(), "key-1").Return([]idempotency.StoredResponse{{
+			RequestHash: "another-hash",
+			State:       idempotency.StateCompleted,
+			StatusCode:  http.StatusCreated,
+			CreatedAt:   time.Now().Add(-2 * ttl),
+		}}, nil)
+		storageMock.EXPECT().Complete(gomock.Any(), "key-1", gomock.Any()).Return(nil)
+
+		rr := post("key-1", "body")
+
+		Expect(rr.Code).To(Equal(http.StatusCreated))
+		Expect(invocations).To(Equal(1))
+	})
+
+	It("releases the key if the request failed such that it can be retried", func() {
+		storageMock.EXPECT().Reserve(gomock.Any(), "key-1", gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
+		storageMock.EXPECT().Find(gomock.Any(), "key-1").Return(nil, nil)
+		storageMock.EXPECT().Release(gomock.Any(), "key-1", gomock.Any()).Return(nil)
+
+		rr := post("key-1", "fail")
+
+		Expect(rr.Code).To(Equal(http.StatusInternalServerError))
+	})
+
+	It("processes only one of two concurrent requests with the same key", func() {
+		inMemory := newInMemoryStorage()
+		handlerStarted := make(chan struct{})
+		finishHandler := make(chan struct{})
+		ws := new(restful.WebService)
+		ws.Route(ws.POST("/slow").
+			Filter(idempotency.Filter(inMemory, ttl)).
+			Produces(restful.MIME_JSON).
+			To(func(req *restful.Request, resp *restful.Response) {
+				invocations++
+				close(handlerStarted)
+				<-finishHandler
+				_ = resp.WriteHeaderAndEntity(http.StatusCreated, map[string]string{"created": "once"})
+			}))
+		slowContainer := restful.NewContainer()
+		slowContainer.Add(ws)
+		send := func() *httptest.ResponseRecorder {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/slow", bytes.NewReader([]byte("body")))
+			req.Header.Set(idempotency.HeaderIdempotencyKey, "key-1")
+			slowContainer.ServeHTTP(rr, req)
+			return rr
+		}
+
+		firstDone := make(chan *httptest.ResponseRecorder)
+		go func() {
+			defer GinkgoRecover()
+			firstDone <- send()
+		}()
+		<-handlerStarted
+
+		concurrent := send()
+		Expect(concurrent.Code).To(Equal(http.StatusConflict))
+		var errorResponse custom_errors.ErrorResponse
+		Expect(json.Unmarshal(concurrent.Body.Bytes(), &errorResponse)).To(Succeed())
+		Expect(errorResponse.ErrorID).To(Equal(custom_errors.IdempotentRequestInProgress.Error()))
+
+		close(finishHandler)
+		first := <-firstDone
+		Expect(first.Code).To(Equal(http.StatusCreated))
+
+		retry := send()
+		Expect(retry.Code).To(Equal(http.StatusCreated))
+		Expect(retry.Header().Get(idempotency.HeaderReplayed)).To(Equal("true"))
+		Expect(invocations).To(Equal(1))
+	})
+})
+
+// inMemoryStorage claims keys like the Postgres storage, it lets requests really run at the same time
+type inMemoryStorage struct {
+	mutex     sync.Mutex
+	responses map[string]map[string]idempotency.StoredResponse
+}
+
+func newInMemoryStorage() *inMemoryStorage {
+	return &inMemoryStorage{responses: make(map[string]map[string]idempotency.StoredResponse)}
+}
+
+func (s *inMemoryStorage) Reserve(_ context.Context, key string, requestHash string, createdAt time.Time, expiredBefore time.Time) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if existing, ok := s.responses[key][requestHash]; ok && !existing.CreatedAt.Before(expiredBefore) {
+		return false, nil
+	}
+	if s.responses[key] == nil {
+		s.responses[key] = make(map[string]idempotency.StoredResponse)
+	}
+	s.responses[key][requestHash] = idempotency.StoredResponse{RequestHash: requestHash, State: idempotency.StatePending, CreatedAt: createdAt}
+	return true, nil
+}
+
+func (s *inMemoryStorage) Find(_ context.Context, key string) ([]idempotency.StoredResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	responses := make([]idempotency.StoredResponse, 0)
+	for _, response := range s.responses[key] {
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+func (s *inMemoryStorage) Complete(_ context.Context, key string, response idempotency.StoredResponse) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.responses[key][response.RequestHash] = response
+	return nil
+}
+
+func (s *inMemoryStorage) Release(_ context.Context, key string, requestHash string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.responses[key], requestHash)
+	return nil
+}
+
+func (s *inMemoryStorage) Purge(_ context.Context, expiredBefore time.Time) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var purged int64
+	for _, responses := range s.responses {
+		for requestHash, response := range responses {
+			if response.CreatedAt.Before(expiredBefore) {
+				delete(responses, requestHash)
+				purged++
+			}
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,28 @@
+package idempotency
+
+import (
+	"context"
+	"github.com/go-playground/log"
+	"time"
+)
+
+// PurgeExpired deletes the keys older than ttl every interval until ctx is done, expired keys are never replayed
+// but would otherwise stay in the database forever
+func PurgeExpired(ctx context.Context, storage Storage, ttl time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := storage.Purge(ctx, time.Now().Add(-ttl))
+			if err != nil {
+				log.WithError(err).Error("could not purge the expired idempotency keys")
+				continue
+			}
+			log.Debugf("purged %d expired idempotency keys", purged)
+		}
+	}
+}
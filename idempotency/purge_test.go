@@ -0,0 +1,36 @@
+//+build unit
+
+package idempotency_test
+
+import (
+	"context"
+	"github.com/jenpaff/golang-microservices/idempotency"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("PurgeExpired", func() {
+
+	It("purges the keys older than the ttl until it is stopped", func() {
+		storage := newInMemoryStorage()
+		now := time.Now()
+		_, _ = storage.Reserve(context.Background(), "old-key", "hash", now.Add(-2*time.Hour), now.Add(-3*time.Hour))
+		_, _ = storage.Reserve(context.Background(), "new-key", "hash", now, now.Add(-time.Hour))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			idempotency.PurgeExpired(ctx, storage, time.Hour, 10*time.Millisecond)
+			close(done)
+		}()
+
+		Eventually(func() ([]idempotency.StoredResponse, error) {
+			return storage.Find(context.Background(), "old-key")
+		}).Should(BeEmpty())
+		Expect(storage.Find(context.Background(), "new-key")).To(HaveLen(1))
+
+		cancel()
+		Eventually(done).Should(BeClosed())
+	})
+})
@@ -0,0 +1,132 @@
+//go:generate mockgen -destination=storage_mock.go -package=idempotency -self_package=github.com/jenpaff/golang-microservices/idempotency github.com/jenpaff/golang-microservices/idempotency Storage
+
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	custom_errors "github.com/jenpaff/golang-microservices/errors"
+	"github.com/volatiletech/sqlboiler/v4/boil"
+	"time"
+)
+
+const (
+	// StatePending marks a key whose first request is still being processed
+	StatePending = "pending"
+	// StateCompleted marks a key whose response is stored
+	StateCompleted = "completed"
+)
+
+// StoredResponse is the first response that was sent for an Idempotency-Key and request,
+// the status code, content type and body are only set once the state is completed
+type StoredResponse struct {
+	RequestHash string
+	State       string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+type Storage interface {
+	// Reserve claims the key for the request before it is processed, it returns false if another request
+	// with the same key and request hash claimed it after expiredBefore, an older claim is taken over
+	Reserve(ctx context.Context, key string, requestHash string, createdAt time.Time, expiredBefore time.Time) (bool, error)
+	// Find returns the pending and completed requests stored for the given key
+	Find(ctx context.Context, key string) ([]StoredResponse, error)
+	// Complete stores the response of a reserved request
+	Complete(ctx context.Context, key string, response StoredResponse) error
+	// Release removes the reservation of a request that failed such that it can be retried
+	Release(ctx context.Context, key string, requestHash string) error
+	// Purge deletes the requests created before expiredBefore and returns how many were deleted
+	Purge(ctx context.Context, expiredBefore time.Time) (int64, error)
+}
+
+type storage struct {
+	db boil.ContextExecutor
+}
+
+// NewStorage takes a *sql.DB or a tracing.DB
+func NewStorage(db boil.ContextExecutor) Storage {
+	return &storage{db: db}
+}
+
+func (p *storage) Reserve(ctx context.Context, key string, requestHash string, createdAt time.Time, expiredBefore time.Time) (bool, error) {
+	// the insert and the takeover of an expired claim are atomic, so only one of concurrent requests gets the key
+	var reservedKey string
+	err := p.db.QueryRowContext(ctx,
+		`INSERT INTO idempotency_keys (key, request_hash, state, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key, request_hash) DO UPDATE SET state = EXCLUDED.state, status_code = NULL, content_type = NULL,
+		body = NULL, created_at = EXCLUDED.created_at WHERE idempotency_keys.created_at < $5
+		RETURNING key`,
+		key, requestHash, StatePending, createdAt, expiredBefore).Scan(&reservedKey)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error reserving idempotency key %s: %s: %w", key, err.Error(), custom_errors.DatabaseError)
+	}
+	return true, nil
+}
+
+func (p *storage) Find(ctx context.Context, key string) ([]StoredResponse, error) {
+	rows, err := p.db.QueryContext(ctx,
+		"SELECT request_hash, state, status_code, content_type, body, created_at FROM idempotency_keys WHERE key = $1", key)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving responses for idempotency key %s: %s: %w", key, err.Error(), custom_errors.DatabaseError)
+	}
+	defer rows.Close()
+
+	responses := make([]StoredResponse, 0)
+	for rows.Next() {
+		var response StoredResponse
+		var statusCode sql.NullInt64
+		var contentType sql.NullString
+		err = rows.Scan(&response.RequestHash, &response.State, &statusCode, &contentType, &response.Body, &response.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error reading response for idempotency key %s: %s: %w", key, err.Error(), custom_errors.DatabaseError)
+		}
+		response.StatusCode = int(statusCode.Int64)
+		response.ContentType = contentType.String
+		responses = append(responses, response)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving responses for idempotency key %s: %s: %w", key, err.Error(), custom_errors.DatabaseError)
+	}
+	return responses, nil
+}
+
+func (p *storage) Complete(ctx context.Context, key string, response StoredResponse) error {
+	_, err := p.db.ExecContext(ctx,
+		`UPDATE idempotency_keys SET state = $3, status_code = $4, content_type = $5, body = $6
+		WHERE key = $1 AND request_hash = $2 AND state = $7`,
+		key, response.RequestHash, StateCompleted, response.StatusCode, response.ContentType, response.Body, StatePending)
+	if err != nil {
+		return fmt.Errorf("error saving response for idempotency key %s: %s: %w", key, err.Error(), custom_errors.DatabaseError)
+	}
+	return nil
+}
+
+func (p *storage) Release(ctx context.Context, key string, requestHash string) error {
+	_, err := p.db.ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE key = $1 AND request_hash = $2 AND state = $3", key, requestHash, StatePending)
+	if err != nil {
+		return fmt.Errorf("error releasing idempotency key %s: %s: %w", key, err.Error(), custom_errors.DatabaseError)
+	}
+	return nil
+}
+
+func (p *storage) Purge(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	result, err := p.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE created_at < $1", expiredBefore)
+	if err != nil {
+		return 0, fmt.Errorf("error purging expired idempotency keys: %s: %w", err.Error(), custom_errors.DatabaseError)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error purging expired idempotency keys: %s: %w", err.Error(), custom_errors.DatabaseError)
+	}
+	return purged, nil
+}
@@ -0,0 +1,137 @@
+//+build integration
+
+package idempotency_test
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jenpaff/golang-microservices/config"
+	"github.com/jenpaff/golang-microservices/idempotency"
+	"github.com/jenpaff/golang-microservices/persistence"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sync"
+	"time"
+)
+
+var _ = Describe("Storage", func() {
+
+	ctx := context.Background()
+	var sqlDB *sql.DB
+	var storage idempotency.Storage
+	var err error
+	var cfg config.Config
+
+	BeforeSuite(func() {
+		cfg, err = config.BuildConfig("../config/test.json", "", "")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	BeforeEach(func() {
+		sqlDB, err = persistence.ConnectPostgres(cfg.Persistence)
+		Expect(err).ToNot(HaveOccurred())
+		storage = idempotency.NewStorage(sqlDB)
+	})
+
+	AfterEach(func() {
+		_, err = sqlDB.Exec("delete from idempotency_keys")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sqlDB.Close()).To(Succeed())
+	})
+
+	It("should return nothing if no response is stored for a key", func() {
+		storedResponses, err := storage.Find(ctx, "unknown")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(storedResponses).To(BeEmpty())
+	})
+
+	It("should reserve a key, complete it and find the response by its key", func() {
+		now := time.Now()
+		reserved, err := storage.Reserve(ctx, "key-1", "hash", now, now.Add(-time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reserved).To(BeTrue())
+
+		storedResponses, err := storage.Find(ctx, "key-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(storedResponses).To(HaveLen(1))
+		Expect(storedResponses[0].State).To(Equal(idempotency.StatePending))
+
+		err = storage.Complete(ctx, "key-1", idempotency.StoredResponse{
+			RequestHash: "hash",
+			StatusCode:  200,
+			ContentType: "application/json",
+			Body:        []byte(`{"user_name":"test"}`),
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		storedResponses, err = storage.Find(ctx, "key-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(storedResponses).To(HaveLen(1))
+		Expect(storedResponses[0].RequestHash).To(Equal("hash"))
+		Expect(storedResponses[0].State).To(Equal(idempotency.StateCompleted))
+		Expect(storedResponses[0].StatusCode).To(Equal(200))
+		Expect(string(storedResponses[0].Body)).To(Equal(`{"user_name":"test"}`))
+	})
+
+	It("should let only one of concurrent requests reserve a key", func() {
+		now := time.Now()
+		results := make(chan bool, 10)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				reserved, err := storage.Reserve(ctx, "key-1", "hash", now, now.Add(-time.Hour))
+				Expect(err).ToNot(HaveOccurred())
+				results <- reserved
+			}()
+		}
+		wg.Wait()
+		close(results)
+
+		reservations := 0
+		for reserved := range results {
+			if reserved {
+				reservations++
+			}
+		}
+		Expect(reservations).To(Equal(1))
+	})
+
+	It("should take over an expired reservation and release a reservation", func() {
+		old := time.Now().Add(-2 * time.Hour)
+		reserved, err := storage.Reserve(ctx, "key-1", "hash", old, old.Add(-time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reserved).To(BeTrue())
+
+		now := time.Now()
+		reserved, err = storage.Reserve(ctx, "key-1", "hash", now, now.Add(-time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reserved).To(BeTrue())
+
+		Expect(storage.Release(ctx, "key-1", "hash")).To(Succeed())
+		storedResponses, err := storage.Find(ctx, "key-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(storedResponses).To(BeEmpty())
+	})
+
+	It("should purge the expired keys", func() {
+		now := time.Now()
+		_, err := storage.Reserve(ctx, "old-key", "hash", now.Add(-2*time.Hour), now.Add(-3*time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+		_, err = storage.Reserve(ctx, "new-key", "hash", now, now.Add(-time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+
+		purged, err := storage.Purge(ctx, now.Add(-time.Hour))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(purged).To(Equal(int64(1)))
+
+		storedResponses, err := storage.Find(ctx, "old-key")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(storedResponses).To(BeEmpty())
+		storedResponses, err = storage.Find(ctx, "new-key")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(storedResponses).To(HaveLen(1))
+	})
+})
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/go-playground/log"
 	"github.com/jenpaff/golang-microservices/app"
+	"github.com/jenpaff/golang-microservices/config"
 	. "github.com/onsi/gomega"
 	"golang.org/x/sync/errgroup"
 	"io"
@@ -26,6 +27,7 @@ type GolangService interface {
 	PostWithAuth(url string, body io.Reader) *http.Response
 	Put(url string, headers map[string]string, body io.Reader) *http.Response
 	PutWithAuth(url string, body io.Reader) *http.Response
+	Delete(url string, headers map[string]string) *http.Response
 }
 
 type golangService struct {
@@ -35,7 +37,7 @@ type golangService struct {
 
 func NewGolangService() GolangService {
 
-	application, err := app.NewApp("12345", "../config/test.json", "", "")
+	application, err := app.NewApp(config.Sources{Files: []string{"../config/test.json"}})
 	Expect(err).ToNot(HaveOccurred())
 
 	return &golangService{
@@ -45,7 +47,8 @@ func NewGolangService() GolangService {
 }
 
 func (p golangService) baseUrl() string {
-	return fmt.Sprintf("localhost:%s", "12345")
+	// test.json binds to port 0, the address is only known once the app is started
+	return p.app.Addr()
 }
 
 func (p golangService) Start() {
@@ -53,8 +56,6 @@ func (p golangService) Start() {
 
 	err := ensureServiceUpAndRunning(ctx, p.app)
 	Expect(err).ToNot(HaveOccurred())
-	//give the server some time to start
-	time.Sleep(5 * time.Second)
 }
 
 func (p golangService) Stop() {
@@ -107,6 +108,16 @@ func (p golangService) PutWithAuth(url string, body io.Reader) *http.Response {
 	return p.Put(url, headers, body)
 }
 
+func (p golangService) Delete(url string, headers map[string]string) *http.Response {
+	req, _ := http.NewRequest("DELETE", fmt.Sprintf("http://%s%s", p.baseUrl(), url), nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	Expect(err).ToNot(HaveOccurred())
+	return resp
+}
+
 func (p *golangService) getHeaders() map[string]string {
 	if len(p.headers) == 0 {
 		token := os.Getenv("AUTH")
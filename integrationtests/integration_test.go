@@ -50,7 +50,7 @@ var _ = Describe("Golang Service", func() {
 				user, err := json.Marshal(&api.UserCreationRequest{
 					UserName:    "jenpaff1",
 					Email:       "jenpaff1@test.com",
-					PhoneNumber: "0123456781",
+					PhoneNumber: "030 12345671",
 				})
 				Expect(err).ToNot(HaveOccurred())
 				response := golangService.Post("/users?enableNewFeature=true", map[string]string{}, bytes.NewReader(user))
@@ -76,7 +76,7 @@ var _ = Describe("Golang Service", func() {
 				user, err := json.Marshal(&api.UserCreationRequest{
 					UserName:    "jenpaff",
 					Email:       "jenpaff@test.com",
-					PhoneNumber: "012345678",
+					PhoneNumber: "030 12345678",
 				})
 				Expect(err).ToNot(HaveOccurred())
 				response := golangService.Post("/users", map[string]string{}, bytes.NewReader(user))
@@ -94,5 +94,42 @@ var _ = Describe("Golang Service", func() {
 				Expect(userResponse.UserName).To(Equal("jenpaff"))
 			})
 		})
+
+		Context("lifecycle", func() {
+			It("should update and delete a given user", func() {
+				By("By returning a 200 status code when creating a user")
+				user, err := json.Marshal(&api.UserCreationRequest{
+					UserName:    "jenpaff2",
+					Email:       "jenpaff2@test.com",
+					PhoneNumber: "030 12345672",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				response := golangService.Post("/users", map[string]string{}, bytes.NewReader(user))
+				Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+				By("By returning a 200 status code when replacing the user")
+				update, err := json.Marshal(&api.UserUpdateRequest{
+					Email:       "jenpaff2@example.com",
+					PhoneNumber: "030 12345673",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				response = golangService.Put("/users/jenpaff2", map[string]string{}, bytes.NewReader(update))
+				Expect(response.StatusCode).To(Equal(http.StatusOK))
+				bodyBytes, err := ioutil.ReadAll(response.Body)
+				Expect(err).ToNot(HaveOccurred())
+				userResponse := common.User{}
+				err = json.Unmarshal(bodyBytes, &userResponse)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(userResponse.Email).To(Equal("jenpaff2@example.com"))
+
+				By("By returning a 204 status code when deleting the user")
+				response = golangService.Delete("/users/jenpaff2", map[string]string{})
+				Expect(response.StatusCode).To(Equal(http.StatusNoContent))
+
+				By("By returning a 404 status code when retrieving the deleted user")
+				response = golangService.Get("/users/jenpaff2", map[string]string{})
+				Expect(response.StatusCode).To(Equal(http.StatusNotFound))
+			})
+		})
 	})
 })
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-playground/log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formatJSON writes an entry as a single JSON object with the fields as top level keys
+func formatJSON(e log.Entry) []byte {
+	object := make(map[string]interface{}, len(e.Fields)+3)
+	for _, field := range e.Fields {
+		object[field.Key] = jsonValue(field.Value)
+	}
+	object["timestamp"] = e.Timestamp.Format(time.RFC3339Nano)
+	object["level"] = strings.ToLower(e.Level.String())
+	object["message"] = e.Message
+
+	line, err := json.Marshal(object)
+	if err != nil {
+		line, _ = json.Marshal(map[string]string{
+			"timestamp": e.Timestamp.Format(time.RFC3339Nano),
+			"level":     strings.ToLower(e.Level.String()),
+			"message":   e.Message,
+			"log_error": err.Error(),
+		})
+	}
+	return append(line, '\n')
+}
+
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return v
+	}
+}
+
+// formatLogfmt writes an entry as key=value pairs, values are quoted if necessary
+func formatLogfmt(e log.Entry) []byte {
+	var b strings.Builder
+	b.WriteString("timestamp=")
+	b.WriteString(e.Timestamp.Format(time.RFC3339Nano))
+	b.WriteString(" level=")
+	b.WriteString(strings.ToLower(e.Level.String()))
+	b.WriteString(" message=")
+	b.WriteString(logfmtValue(e.Message))
+	for _, field := range e.Fields {
+		b.WriteByte(' ')
+		b.WriteString(field.Key)
+		b.WriteByte('=')
+		b.WriteString(logfmtValue(fmt.Sprint(field.Value)))
+	}
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
+
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\t\r\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
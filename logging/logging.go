@@ -0,0 +1,174 @@
+package logging
+
+import (
+	"fmt"
+	"github.com/go-playground/log"
+	"github.com/go-playground/log/handlers/console"
+	"github.com/jenpaff/golang-microservices/config"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+	FormatLogfmt  = "logfmt"
+)
+
+// settings is swapped as a whole such that entries logged concurrently with Configure see a consistent state
+type settings struct {
+	format         string
+	level          log.Level
+	packageLevels  map[string]log.Level
+	redactedFields map[string]bool
+}
+
+var (
+	registerOnce sync.Once
+	current      atomic.Value
+
+	writerMutex   sync.Mutex
+	writer        io.Writer = os.Stderr
+	consoleLogger *console.Console
+)
+
+// Configure applies the logging config, the handler is registered for all levels on the first call
+// such that later calls can change format and levels without logging entries twice
+func Configure(cfg config.LoggingConfig) error {
+	newSettings, err := newSettings(cfg)
+	if err != nil {
+		return err
+	}
+	current.Store(newSettings)
+
+	registerOnce.Do(func() {
+		consoleLogger = console.New(true)
+		consoleLogger.SetWriter(writer)
+		log.AddHandler(handlerFunc(handle), log.AllLevels...)
+	})
+	return nil
+}
+
+// SetWriter sets the writer all formats write to, default is os.Stderr
+func SetWriter(w io.Writer) {
+	writerMutex.Lock()
+	defer writerMutex.Unlock()
+	writer = w
+	if consoleLogger != nil {
+		consoleLogger.SetWriter(w)
+	}
+}
+
+func newSettings(cfg config.LoggingConfig) (*settings, error) {
+	switch cfg.Format {
+	case FormatConsole, FormatJSON, FormatLogfmt:
+	default:
+		return nil, fmt.Errorf("unknown log format %q, must be one of %s, %s or %s", cfg.Format, FormatConsole, FormatJSON, FormatLogfmt)
+	}
+
+	level, err := parseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	packageLevels := make(map[string]log.Level, len(cfg.Packages))
+	for pkg, packageLevel := range cfg.Packages {
+		packageLevels[pkg], err = parseLevel(packageLevel)
+		if err != nil {
+			return nil, fmt.Errorf("invalid level for package %s: %w", pkg, err)
+		}
+	}
+
+	redactedFields := make(map[string]bool, len(cfg.RedactedFields))
+	for _, field := range cfg.RedactedFields {
+		redactedFields[strings.ToLower(field)] = true
+	}
+
+	return &settings{format: cfg.Format, level: level, packageLevels: packageLevels, redactedFields: redactedFields}, nil
+}
+
+func parseLevel(level string) (log.Level, error) {
+	for _, l := range log.AllLevels {
+		if strings.EqualFold(l.String(), level) {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", level)
+}
+
+type handlerFunc func(e log.Entry)
+
+func (f handlerFunc) Log(e log.Entry) {
+	f(e)
+}
+
+func handle(e log.Entry) {
+	s := current.Load().(*settings)
+
+	if e.Level < s.minimumLevel() {
+		return
+	}
+
+	e = s.redact(e)
+
+	writerMutex.Lock()
+	defer writerMutex.Unlock()
+
+	switch s.format {
+	case FormatJSON:
+		_, _ = writer.Write(formatJSON(e))
+	case FormatLogfmt:
+		_, _ = writer.Write(formatLogfmt(e))
+	default:
+		consoleLogger.Log(e)
+	}
+}
+
+// minimumLevel is the level of the most specific package override matching the caller, or the global level
+func (s *settings) minimumLevel() log.Level {
+	if len(s.packageLevels) == 0 {
+		return s.level
+	}
+
+	pkg := callerPackage()
+	level, matchLength := s.level, 0
+	for overriddenPackage, packageLevel := range s.packageLevels {
+		if (pkg == overriddenPackage || strings.HasSuffix(pkg, "/"+overriddenPackage)) && len(overriddenPackage) > matchLength {
+			level, matchLength = packageLevel, len(overriddenPackage)
+		}
+	}
+	return level
+}
+
+// callerPackage returns the import path of the package that logged the entry, i.e. of the first frame
+// outside of the log library and this package
+func callerPackage() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		pkg := packageOf(frame.Function)
+		if pkg != "github.com/go-playground/log" && pkg != "github.com/jenpaff/golang-microservices/logging" {
+			return pkg
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// packageOf strips the function name, e.g. github.com/a/b.(*T).F is in package github.com/a/b
+func packageOf(function string) string {
+	lastSlash := strings.LastIndex(function, "/")
+	if lastSlash < 0 {
+		lastSlash = 0
+	}
+	if dot := strings.Index(function[lastSlash:], "."); dot >= 0 {
+		return function[:lastSlash+dot]
+	}
+	return function
+}
@@ -0,0 +1,92 @@
+//+build unit
+
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/go-playground/log"
+	"github.com/jenpaff/golang-microservices/config"
+	"github.com/jenpaff/golang-microservices/logging"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"strings"
+)
+
+var _ = Describe("Logging", func() {
+
+	var output *bytes.Buffer
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+		logging.SetWriter(output)
+	})
+
+	configure := func(cfg config.LoggingConfig) {
+		Expect(logging.Configure(cfg)).To(Succeed())
+	}
+
+	lines := func() []string {
+		return strings.Split(strings.TrimSpace(output.String()), "\n")
+	}
+
+	It("will write entries as json objects with the fields as keys", func() {
+		configure(config.LoggingConfig{Format: logging.FormatJSON, Level: "info"})
+
+		log.WithField("request_id", "request-1").Info("user endpoint was invoked")
+
+		var entry map[string]interface{}
+		Expect(json.Unmarshal(output.Bytes(), &entry)).To(Succeed())
+		Expect(entry["level"]).To(Equal("info"))
+		Expect(entry["message"]).To(Equal("user endpoint was invoked"))
+		Expect(entry["request_id"]).To(Equal("request-1"))
+	})
+
+	It("will write entries as logfmt", func() {
+		configure(config.LoggingConfig{Format: logging.FormatLogfmt, Level: "info"})
+
+		log.WithField("request_id", "request-1").Info("user endpoint was invoked")
+
+		Expect(output.String()).To(ContainSubstring(`level=info message="user endpoint was invoked" request_id=request-1`))
+	})
+
+	It("will drop entries below the global level", func() {
+		configure(config.LoggingConfig{Format: logging.FormatLogfmt, Level: "warn"})
+
+		log.Info("dropped")
+		log.Warn("logged")
+
+		Expect(lines()).To(HaveLen(1))
+		Expect(output.String()).To(ContainSubstring("logged"))
+	})
+
+	It("will apply the level of the package that logged the entry", func() {
+		configure(config.LoggingConfig{Format: logging.FormatLogfmt, Level: "debug", Packages: map[string]string{
+			"logging_test":   "error",
+			"featuretoggles": "debug",
+		}})
+
+		log.Warn("dropped")
+		log.Error("logged")
+
+		Expect(lines()).To(HaveLen(1))
+		Expect(output.String()).To(ContainSubstring("logged"))
+	})
+
+	It("will redact configured fields as well as email addresses and phone numbers", func() {
+		configure(config.LoggingConfig{Format: logging.FormatLogfmt, Level: "info", RedactedFields: []string{"password"}})
+
+		log.WithField("password", "secret").WithField("user", "jenny@test.com").Infof("created user with phone number %s", "+4930123456")
+
+		Expect(output.String()).ToNot(ContainSubstring("secret"))
+		Expect(output.String()).ToNot(ContainSubstring("jenny@test.com"))
+		Expect(output.String()).ToNot(ContainSubstring("+4930123456"))
+		Expect(output.String()).To(ContainSubstring("password=[REDACTED] user=[REDACTED]"))
+	})
+
+	It("will reject unknown formats and levels", func() {
+		Expect(logging.Configure(config.LoggingConfig{Format: "xml", Level: "info"})).ToNot(Succeed())
+		Expect(logging.Configure(config.LoggingConfig{Format: logging.FormatJSON, Level: "verbose"})).ToNot(Succeed())
+		Expect(logging.Configure(config.LoggingConfig{Format: logging.FormatJSON, Level: "info", Packages: map[string]string{"api": "loud"}})).ToNot(Succeed())
+	})
+})
@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"fmt"
+	"github.com/go-playground/log"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// phone numbers are stored in E.164, other formats only appear in rejected input
+	phonePattern = regexp.MustCompile(`\+[1-9]\d{6,14}\b`)
+)
+
+// redact replaces the values of redacted fields and email addresses and phone numbers in the message and
+// all other string fields, it returns a copy such that other handlers still see the original entry
+func (s *settings) redact(e log.Entry) log.Entry {
+	e.Message = redactString(e.Message)
+
+	fields := make([]log.Field, len(e.Fields))
+	for i, field := range e.Fields {
+		switch {
+		case s.redactedFields[strings.ToLower(field.Key)]:
+			field.Value = redacted
+		case isString(field.Value):
+			field.Value = redactString(fmt.Sprint(field.Value))
+		}
+		fields[i] = field
+	}
+	e.Fields = fields
+	return e
+}
+
+func redactString(value string) string {
+	value = emailPattern.ReplaceAllString(value, redacted)
+	return phonePattern.ReplaceAllString(value, redacted)
+}
+
+func isString(value interface{}) bool {
+	switch value.(type) {
+	case string, error, fmt.Stringer:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/jenpaff/golang-microservices/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	namespace = "golang_service"
+
+	// unmatchedRoute labels requests which did not match any route, to keep the number of label values bounded
+	unmatchedRoute = "unmatched"
+)
+
+// Metrics records rate, errors and duration (RED) per route and exposes them in the Prometheus text format
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by route template, method and status code.",
+		}, []string{"route", "method", "status"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_errors_total",
+			Help:      "Number of HTTP requests that failed by route template, method and error id.",
+		}, []string{"route", "method", "error_id"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by route template and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+	}
+
+	m.registry.MustRegister(m.requests, m.errors, m.duration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return m
+}
+
+// RegisterDB exposes the connection pool stats of db as gauges
+func (m *Metrics) RegisterDB(db *sql.DB, dbName string) error {
+	err := m.registry.Register(collectors.NewDBStatsCollector(db, dbName))
+	if err != nil {
+		return fmt.Errorf("could not register database metrics: %w", err)
+	}
+	return nil
+}
+
+// Filter is a go-restful container filter recording every request
+func (m *Metrics) Filter(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	start := time.Now()
+
+	chain.ProcessFilter(req, resp)
+
+	route := req.SelectedRoutePath()
+	if route == "" {
+		route = unmatchedRoute
+	}
+	method := req.Request.Method
+
+	m.duration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+	m.requests.WithLabelValues(route, method, strconv.Itoa(resp.StatusCode())).Inc()
+	if errorID, ok := req.Attribute(errors.ErrorIDAttribute).(string); ok {
+		m.errors.WithLabelValues(route, method, errorID).Inc()
+	}
+}
+
+// Handler serves the recorded metrics in the Prometheus text exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,77 @@
+//+build unit
+
+package metrics_test
+
+import (
+	"fmt"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/jenpaff/golang-microservices/errors"
+	"github.com/jenpaff/golang-microservices/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Metrics", func() {
+
+	var serviceMetrics *metrics.Metrics
+	var container *restful.Container
+
+	BeforeEach(func() {
+		serviceMetrics = metrics.NewMetrics()
+
+		ws := new(restful.WebService)
+		ws.Route(ws.GET("/things/{id}").
+			Produces(restful.MIME_JSON).
+			To(errors.ErrorHandler(func(req *restful.Request, resp *restful.Response) error {
+				if req.PathParameter("id") == "unknown" {
+					return fmt.Errorf("thing does not exist: %w", errors.UserNotFound)
+				}
+				return resp.WriteEntity(map[string]string{"id": req.PathParameter("id")})
+			})))
+		container = restful.NewContainer()
+		container.Filter(serviceMetrics.Filter)
+		container.Add(ws)
+	})
+
+	get := func(path string) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		container.ServeHTTP(rr, req)
+	}
+
+	scrape := func() string {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+		serviceMetrics.Handler().ServeHTTP(rr, req)
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		return rr.Body.String()
+	}
+
+	It("records requests by route template instead of the raw path", func() {
+		get("/things/1")
+		get("/things/2")
+
+		body := scrape()
+
+		Expect(body).To(ContainSubstring(`golang_service_http_requests_total{method="GET",route="/things/{id}",status="200"} 2`))
+		Expect(body).To(ContainSubstring(`golang_service_http_request_duration_seconds_count{method="GET",route="/things/{id}"} 2`))
+		Expect(body).ToNot(ContainSubstring(`/things/1`))
+	})
+
+	It("records errors by error id", func() {
+		get("/things/unknown")
+
+		body := scrape()
+
+		Expect(body).To(ContainSubstring(`golang_service_http_requests_total{method="GET",route="/things/{id}",status="404"} 1`))
+		Expect(body).To(ContainSubstring(`golang_service_http_errors_total{error_id="USER_NOT_FOUND",method="GET",route="/things/{id}"} 1`))
+	})
+
+	It("records requests that do not match a route as unmatched", func() {
+		get("/unknown/path")
+
+		Expect(scrape()).To(ContainSubstring(`route="unmatched"`))
+	})
+})
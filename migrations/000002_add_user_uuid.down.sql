@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS users_uuid_key;
+ALTER TABLE users DROP COLUMN IF EXISTS uuid;
@@ -0,0 +1,7 @@
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+-- the default backfills existing users, new users get their uuid assigned by the service
+ALTER TABLE users ADD COLUMN uuid UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE users ALTER COLUMN uuid DROP DEFAULT;
+
+CREATE UNIQUE INDEX users_uuid_key ON users (uuid);
@@ -0,0 +1,16 @@
+-- a request reserves its key and request hash before it is processed, concurrent retries find the pending row,
+-- the response is stored once the request is completed
+CREATE TABLE IF NOT EXISTS idempotency_keys(
+   key VARCHAR (255) NOT NULL,
+   request_hash VARCHAR (64) NOT NULL,
+   state VARCHAR (16) NOT NULL,
+   status_code INTEGER,
+   content_type VARCHAR (255),
+   body BYTEA,
+   created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+   PRIMARY KEY (key, request_hash)
+);
+
+
+-- expired keys are purged by their creation time
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at_idx ON idempotency_keys (created_at);
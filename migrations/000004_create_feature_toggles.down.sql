@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS feature_toggle_changes;
+DROP TABLE IF EXISTS feature_toggles;
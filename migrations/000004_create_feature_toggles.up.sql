@@ -0,0 +1,19 @@
+-- toggles saved here replace the toggle of the same name in the config files
+CREATE TABLE IF NOT EXISTS feature_toggles(
+   name VARCHAR (255) PRIMARY KEY,
+   definition JSONB NOT NULL,
+   updated_by VARCHAR (255) NOT NULL,
+   updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- every change of a toggle, the audit log of the admin API
+CREATE TABLE IF NOT EXISTS feature_toggle_changes(
+   id BIGSERIAL PRIMARY KEY,
+   name VARCHAR (255) NOT NULL,
+   action VARCHAR (16) NOT NULL,
+   definition JSONB,
+   changed_by VARCHAR (255) NOT NULL,
+   changed_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX feature_toggle_changes_name_idx ON feature_toggle_changes (name);
@@ -3,32 +3,26 @@ package persistence
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
 )
 
-//FIXME: we ran into a bug where after 100 integration tests the database connection suddenly dropped bc it reached its max
-// there should be the possibility to not open a connection if there's already one established
-func EnsureConnected(ctx context.Context, connectionString string, pollingDelay time.Duration) error {
-	done := make(chan error, 1)
+// EnsureConnected pings the pool until the database answers or ctx is done, it reuses the connections
+// of db instead of opening a pool per attempt
+func EnsureConnected(ctx context.Context, db *sql.DB, pollingDelay time.Duration) error {
+	ticker := time.NewTicker(pollingDelay)
+	defer ticker.Stop()
 
-	go func() {
-		for {
-			db, err := sql.Open("postgres", connectionString)
-			if err == nil {
-				err := db.Ping()
-				if err == nil {
-					done <- nil
-				}
-			}
-
-			time.Sleep(pollingDelay)
+	for {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
 		}
-	}()
 
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case err := <-done:
-		return err
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database is not reachable: %s: %w", err.Error(), ctx.Err())
+		case <-ticker.C:
+		}
 	}
 }
@@ -28,6 +28,7 @@ type User struct {
 	Username    string      `boil:"username" json:"username" toml:"username" yaml:"username"`
 	Email       null.String `boil:"email" json:"email,omitempty" toml:"email" yaml:"email,omitempty"`
 	PhoneNumber null.String `boil:"phone_number" json:"phone_number,omitempty" toml:"phone_number" yaml:"phone_number,omitempty"`
+	UUID        string      `boil:"uuid" json:"uuid" toml:"uuid" yaml:"uuid"`
 
 	R *userR `boil:"-" json:"-" toml:"-" yaml:"-"`
 	L userL  `boil:"-" json:"-" toml:"-" yaml:"-"`
@@ -38,11 +39,13 @@ var UserColumns = struct {
 	Username    string
 	Email       string
 	PhoneNumber string
+	UUID        string
 }{
 	ID:          "id",
 	Username:    "username",
 	Email:       "email",
 	PhoneNumber: "phone_number",
+	UUID:        "uuid",
 }
 
 // Generated where
@@ -121,11 +124,13 @@ var UserWhere = struct {
 	Username    whereHelperstring
 	Email       whereHelpernull_String
 	PhoneNumber whereHelpernull_String
+	UUID        whereHelperstring
 }{
 	ID:          whereHelperint{field: "\"users\".\"id\""},
 	Username:    whereHelperstring{field: "\"users\".\"username\""},
 	Email:       whereHelpernull_String{field: "\"users\".\"email\""},
 	PhoneNumber: whereHelpernull_String{field: "\"users\".\"phone_number\""},
+	UUID:        whereHelperstring{field: "\"users\".\"uuid\""},
 }
 
 // UserRels is where relationship names are stored.
@@ -145,8 +150,8 @@ func (*userR) NewStruct() *userR {
 type userL struct{}
 
 var (
-	userAllColumns            = []string{"id", "username", "email", "phone_number"}
-	userColumnsWithoutDefault = []string{"username", "email", "phone_number"}
+	userAllColumns            = []string{"id", "username", "email", "phone_number", "uuid"}
+	userColumnsWithoutDefault = []string{"username", "email", "phone_number", "uuid"}
 	userColumnsWithDefault    = []string{"id"}
 	userPrimaryKeyColumns     = []string{"id"}
 )
@@ -7,8 +7,11 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jenpaff/golang-microservices/config"
 	_ "github.com/lib/pq"
+	"time"
 )
 
+// ConnectPostgres creates the pool of connections the service shares, sql.Open does not connect yet,
+// see EnsureConnected
 func ConnectPostgres(config config.PersistenceConfig) (*sql.DB, error) {
 	pgOptions := fmt.Sprintf("host=%s port=%d user=%s dbname=%s password=%s", config.DbHost, config.DbPort, config.DbUsername, config.DbName, config.DbPassword)
 	if !config.SslEnabled {
@@ -19,6 +22,10 @@ func ConnectPostgres(config config.PersistenceConfig) (*sql.DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(config.ConnMaxLifetime))
+	db.SetConnMaxIdleTime(time.Duration(config.ConnMaxIdleTime))
 
 	log.Infof("PostgreSQL storage: connected to host %s:%d database %s with user %s", config.DbHost, config.DbPort, config.DbName, config.DbUsername)
 	return db, nil
@@ -0,0 +1,47 @@
+package requestid
+
+import (
+	"context"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/go-playground/log"
+	"github.com/google/uuid"
+	"regexp"
+)
+
+const (
+	// Header carries the request id from the caller and back in the response
+	Header = "X-Request-ID"
+	// LogField is the name of the log field holding the request id
+	LogField = "request_id"
+)
+
+// validRequestID restricts incoming ids such that callers cannot inject arbitrary content into our logs
+var validRequestID = regexp.MustCompile(`^[\w\-.:]{1,128}$`)
+
+type contextKey struct{}
+
+// Filter accepts the X-Request-ID of the caller or generates a new one, stores it in the request context
+// together with a log entry carrying it, and echoes it in the response headers
+func Filter(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	requestID := req.HeaderParameter(Header)
+	if !validRequestID.MatchString(requestID) {
+		requestID = uuid.New().String()
+	}
+
+	req.Request = req.Request.WithContext(NewContext(req.Request.Context(), requestID))
+	resp.Header().Set(Header, requestID)
+
+	chain.ProcessFilter(req, resp)
+}
+
+// NewContext returns a context carrying the request id, log.GetContext of it returns an entry with the request id field
+func NewContext(ctx context.Context, requestID string) context.Context {
+	ctx = context.WithValue(ctx, contextKey{}, requestID)
+	return log.SetContext(ctx, log.WithField(LogField, requestID))
+}
+
+// FromContext returns the request id stored in ctx or an empty string if there is none
+func FromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(contextKey{}).(string)
+	return requestID
+}
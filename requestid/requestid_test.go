@@ -0,0 +1,70 @@
+//+build unit
+
+package requestid_test
+
+import (
+	"context"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/google/uuid"
+	"github.com/jenpaff/golang-microservices/requestid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("Request ID", func() {
+
+	var container *restful.Container
+	var seenRequestID string
+
+	BeforeEach(func() {
+		seenRequestID = ""
+
+		ws := new(restful.WebService)
+		ws.Route(ws.GET("/things").To(func(req *restful.Request, resp *restful.Response) {
+			seenRequestID = requestid.FromContext(req.Request.Context())
+			resp.WriteHeader(http.StatusNoContent)
+		}))
+		container = restful.NewContainer()
+		container.Filter(requestid.Filter)
+		container.Add(ws)
+	})
+
+	get := func(requestID string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/things", nil)
+		if requestID != "" {
+			req.Header.Set(requestid.Header, requestID)
+		}
+		container.ServeHTTP(rr, req)
+		return rr
+	}
+
+	It("will generate a request id if the caller did not send one", func() {
+		rr := get("")
+
+		_, err := uuid.Parse(seenRequestID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rr.Header().Get(requestid.Header)).To(Equal(seenRequestID))
+	})
+
+	It("will keep the request id sent by the caller", func() {
+		rr := get("abc-123")
+
+		Expect(seenRequestID).To(Equal("abc-123"))
+		Expect(rr.Header().Get(requestid.Header)).To(Equal("abc-123"))
+	})
+
+	It("will replace an invalid request id", func() {
+		rr := get(strings.Repeat("a", 129))
+
+		Expect(seenRequestID).ToNot(Equal(strings.Repeat("a", 129)))
+		Expect(rr.Header().Get(requestid.Header)).To(Equal(seenRequestID))
+	})
+
+	It("will return an empty request id for a context without one", func() {
+		Expect(requestid.FromContext(context.Background())).To(BeEmpty())
+	})
+})
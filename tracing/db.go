@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+)
+
+// DB starts a client span with the SQL statement for every statement executed through it.
+// It implements boil.ContextExecutor and can be used wherever a *sql.DB is passed to sqlboiler.
+// Query arguments are not recorded as they may contain personal data.
+// *sql.Rows cannot be wrapped, so the spans of queries end when the database answered and do not cover reading
+// the rows, their names end with "round-trip" to tell them apart from statements that are complete when they end.
+type DB struct {
+	db     *sql.DB
+	dbName string
+}
+
+func WrapDB(db *sql.DB, dbName string) *DB {
+	return &DB{db: db, dbName: dbName}
+}
+
+func (d *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.ExecContext(context.Background(), query, args...)
+}
+
+func (d *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.QueryContext(context.Background(), query, args...)
+}
+
+func (d *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return d.QueryRowContext(context.Background(), query, args...)
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := d.startSpan(ctx, query, "")
+	defer span.End()
+
+	result, err := d.db.ExecContext(ctx, query, args...)
+	recordError(span, err)
+	return result, err
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := d.startSpan(ctx, query, roundTripSuffix)
+	defer span.End()
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	recordError(span, err)
+	return rows, err
+}
+
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := d.startSpan(ctx, query, roundTripSuffix)
+	defer span.End()
+
+	row := d.db.QueryRowContext(ctx, query, args...)
+	recordError(span, row.Err())
+	return row
+}
+
+// roundTripSuffix names the spans of queries, they only cover sending the query until the first response
+const roundTripSuffix = " round-trip"
+
+func (d *DB) startSpan(ctx context.Context, query string, nameSuffix string) (context.Context, trace.Span) {
+	operation := "SQL"
+	if fields := strings.Fields(query); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+
+	return StartSpan(ctx, operation+" "+d.dbName+nameSuffix,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBNameKey.String(d.dbName),
+			semconv.DBOperationKey.String(operation),
+			semconv.DBStatementKey.String(query),
+		))
+}
+
+func recordError(span trace.Span, err error) {
+	// no rows is a regular result, e.g. for an unknown user
+	if err == nil || errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,39 @@
+package tracing
+
+import (
+	"github.com/emicklei/go-restful/v3"
+	"github.com/jenpaff/golang-microservices/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Filter is a go-restful container filter starting a server span for every request,
+// the span continues the trace of an incoming traceparent header
+func Filter(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	ctx := otel.GetTextMapPropagator().Extract(req.Request.Context(), propagation.HeaderCarrier(req.Request.Header))
+
+	// the route is selected before container filters run
+	route := req.SelectedRoutePath()
+	spanName := "HTTP " + req.Request.Method
+	if route != "" {
+		spanName = req.Request.Method + " " + route
+	}
+
+	ctx, span := StartSpan(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", route, req.Request)...))
+	defer span.End()
+
+	req.Request = req.Request.WithContext(ctx)
+
+	chain.ProcessFilter(req, resp)
+
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode())...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(resp.StatusCode()))
+	if errorID, ok := req.Attribute(errors.ErrorIDAttribute).(string); ok {
+		span.SetAttributes(attribute.String(errors.ErrorIDAttribute, errorID))
+	}
+}
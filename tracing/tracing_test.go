@@ -0,0 +1,111 @@
+//+build unit
+
+package tracing_test
+
+import (
+	"context"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/jenpaff/golang-microservices/config"
+	"github.com/jenpaff/golang-microservices/tracing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+)
+
+var _ = Describe("Tracing", func() {
+
+	Context("Filter", func() {
+
+		var recorder *tracetest.SpanRecorder
+		var container *restful.Container
+
+		BeforeEach(func() {
+			recorder = tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			otel.SetTextMapPropagator(propagation.TraceContext{})
+
+			ws := new(restful.WebService)
+			ws.Route(ws.GET("/users/{userName}").To(func(req *restful.Request, resp *restful.Response) {
+				_, span := tracing.StartSpan(req.Request.Context(), "users.Service.GetUser")
+				span.End()
+				if req.PathParameter("userName") == "broken" {
+					resp.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				resp.WriteHeader(http.StatusOK)
+			}))
+			container = restful.NewContainer()
+			container.Filter(tracing.Filter)
+			container.Add(ws)
+		})
+
+		get := func(path, traceparent string) {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, path, nil)
+			if traceparent != "" {
+				req.Header.Set("traceparent", traceparent)
+			}
+			container.ServeHTTP(rr, req)
+		}
+
+		It("will start a server span named after the route with the spans of the route as children", func() {
+			get("/users/jenny", "")
+
+			spans := recorder.Ended()
+			Expect(spans).To(HaveLen(2))
+			Expect(spans[0].Name()).To(Equal("users.Service.GetUser"))
+			Expect(spans[1].Name()).To(Equal("GET /users/{userName}"))
+			Expect(spans[0].Parent().SpanID()).To(Equal(spans[1].SpanContext().SpanID()))
+		})
+
+		It("will continue the trace of an incoming traceparent header", func() {
+			get("/users/jenny", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+			serverSpan := recorder.Ended()[1]
+			Expect(serverSpan.SpanContext().TraceID().String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+			Expect(serverSpan.Parent().SpanID().String()).To(Equal("00f067aa0ba902b7"))
+			Expect(serverSpan.Parent().IsRemote()).To(BeTrue())
+		})
+
+		It("will mark the span as failed for server errors", func() {
+			get("/users/broken", "")
+
+			Expect(recorder.Ended()[1].Status().Code).To(Equal(codes.Error))
+		})
+	})
+
+	Context("Init", func() {
+
+		It("will return an error for an unknown exporter", func() {
+			_, err := tracing.Init(context.Background(), "test", config.TracingConfig{Exporter: "carrier-pigeon", SampleRatio: 1})
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("will write spans to the file of the file exporter", func() {
+			dir, err := ioutil.TempDir("", "tracing")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+			filePath := filepath.Join(dir, "traces.json")
+			shutdown, err := tracing.Init(context.Background(), "test", config.TracingConfig{Exporter: tracing.ExporterFile, FilePath: filePath, SampleRatio: 1})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, span := tracing.StartSpan(context.Background(), "users.Service.GetUser")
+			span.End()
+			Expect(shutdown(context.Background())).To(Succeed())
+
+			content, err := ioutil.ReadFile(filePath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring("users.Service.GetUser"))
+		})
+	})
+})
@@ -4,24 +4,36 @@ package users
 
 import (
 	"context"
+	"fmt"
 	"github.com/jenpaff/golang-microservices/common"
+	"github.com/jenpaff/golang-microservices/errors"
+	"github.com/jenpaff/golang-microservices/tracing"
+	"github.com/jenpaff/golang-microservices/validation"
 )
 
 type Service interface {
 	GetUser(ctx context.Context, userName string) (*common.User, error)
+	GetUserByID(ctx context.Context, id string) (*common.User, error)
+	ListUsers(ctx context.Context, filter common.UserFilter) (*common.UserPage, error)
 	CreateUser(ctx context.Context, userName, email, phoneNumber string) (*common.User, error)
 	CreateUserWithNewFeature(ctx context.Context, userName, email, phoneNumber string) (*common.User, error)
+	UpdateUser(ctx context.Context, userName, email, phoneNumber string) (*common.User, error)
+	DeleteUser(ctx context.Context, userName string) error
 }
 
 type service struct {
-	storage Storage
+	storage            Storage
+	defaultPhoneRegion string
 }
 
-func NewService(storage Storage) Service {
-	return service{storage: storage}
+func NewService(storage Storage, defaultPhoneRegion string) Service {
+	return service{storage: storage, defaultPhoneRegion: defaultPhoneRegion}
 }
 
 func (s service) GetUser(ctx context.Context, userName string) (*common.User, error) {
+	ctx, span := tracing.StartSpan(ctx, "users.Service.GetUser")
+	defer span.End()
+
 	user, err := s.storage.FindByName(ctx, userName)
 	if err != nil {
 		return nil, err
@@ -29,8 +41,36 @@ func (s service) GetUser(ctx context.Context, userName string) (*common.User, er
 	return user, nil
 }
 
+func (s service) GetUserByID(ctx context.Context, id string) (*common.User, error) {
+	ctx, span := tracing.StartSpan(ctx, "users.Service.GetUserByID")
+	defer span.End()
+
+	user, err := s.storage.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s service) ListUsers(ctx context.Context, filter common.UserFilter) (*common.UserPage, error) {
+	ctx, span := tracing.StartSpan(ctx, "users.Service.ListUsers")
+	defer span.End()
+
+	page, err := s.storage.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
 func (s service) CreateUser(ctx context.Context, userName, email, phoneNumber string) (*common.User, error) {
-	// TODO: set uuid here
+	ctx, span := tracing.StartSpan(ctx, "users.Service.CreateUser")
+	defer span.End()
+
+	email, phoneNumber, err := s.normalize(email, phoneNumber)
+	if err != nil {
+		return nil, err
+	}
 	user, err := s.storage.Create(ctx, userName, email, phoneNumber)
 	if err != nil {
 		return nil, err
@@ -39,10 +79,47 @@ func (s service) CreateUser(ctx context.Context, userName, email, phoneNumber st
 }
 
 func (s service) CreateUserWithNewFeature(ctx context.Context, userName, email, phoneNumber string) (*common.User, error) {
-	// TODO: set uuid here
+	ctx, span := tracing.StartSpan(ctx, "users.Service.CreateUserWithNewFeature")
+	defer span.End()
+
+	email, phoneNumber, err := s.normalize(email, phoneNumber)
+	if err != nil {
+		return nil, err
+	}
 	user, err := s.storage.Create(ctx, userName, email, phoneNumber)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
+
+func (s service) UpdateUser(ctx context.Context, userName, email, phoneNumber string) (*common.User, error) {
+	ctx, span := tracing.StartSpan(ctx, "users.Service.UpdateUser")
+	defer span.End()
+
+	email, phoneNumber, err := s.normalize(email, phoneNumber)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.storage.Update(ctx, userName, email, phoneNumber)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s service) DeleteUser(ctx context.Context, userName string) error {
+	ctx, span := tracing.StartSpan(ctx, "users.Service.DeleteUser")
+	defer span.End()
+
+	return s.storage.Delete(ctx, userName)
+}
+
+// normalize brings email and phone number into their canonical form, such that the unique constraints detect duplicates
+func (s service) normalize(email, phoneNumber string) (string, string, error) {
+	normalizedPhoneNumber, err := validation.NormalizePhoneNumber(phoneNumber, s.defaultPhoneRegion)
+	if err != nil {
+		return "", "", fmt.Errorf("could not normalize phone number: %s: %w", err.Error(), errors.InvalidInput)
+	}
+	return validation.NormalizeEmail(email), normalizedPhoneNumber, nil
+}
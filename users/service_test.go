@@ -4,6 +4,7 @@ package users_test
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"github.com/golang/mock/gomock"
 	"github.com/jenpaff/golang-microservices/common"
@@ -25,7 +26,7 @@ var _ = Describe("Service", func() {
 	BeforeEach(func() {
 		mockCtrl = gomock.NewController(test_helper.GinkgoTestReporter{})
 		mockStorage = users.NewMockStorage(mockCtrl)
-		userService = users.NewService(mockStorage)
+		userService = users.NewService(mockStorage, "DE")
 	})
 
 	Context("Get user", func() {
@@ -58,15 +59,56 @@ var _ = Describe("Service", func() {
 
 	})
 
+	Context("Get user by id", func() {
+
+		It("will successfully retrieve a user by id", func() {
+			id := "2c0e5d8c-5b1b-4a47-9c7e-4f4f1c6b2e0a"
+			mockStorage.EXPECT().FindByID(gomock.Any(), id).Return(&common.User{
+				ID:       id,
+				UserName: "test",
+			}, nil)
+
+			returnedUser, err := userService.GetUserByID(ctx, id)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(returnedUser.ID).To(Equal(id))
+		})
+	})
+
+	Context("List users", func() {
+
+		It("will return the page returned by the storage", func() {
+			filter := common.UserFilter{Limit: 10}
+			mockStorage.EXPECT().List(gomock.Any(), filter).Return(&common.UserPage{
+				Users:      []common.User{{UserName: "test"}},
+				NextCursor: "cursor",
+			}, nil)
+
+			page, err := userService.ListUsers(ctx, filter)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(page.Users).To(HaveLen(1))
+			Expect(page.NextCursor).To(Equal("cursor"))
+		})
+
+		It("will return error if users can not be listed", func() {
+			mockStorage.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("error listing users %w", errors.DatabaseError))
+
+			_, err := userService.ListUsers(ctx, common.UserFilter{Limit: 10})
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
 	Context("Create user", func() {
 
 		It("will return error if user can not be found", func() {
 
 			username := "test"
 			email := "test@test.com"
-			phone := "1234"
+			phone := "+493012345678"
 
-			mockStorage.EXPECT().Create(gomock.Any(), username, "test@test.com", "1234").Return(nil, fmt.Errorf("error storing user %w", errors.DatabaseError))
+			mockStorage.EXPECT().Create(gomock.Any(), username, "test@test.com", "+493012345678").Return(nil, fmt.Errorf("error storing user %w", errors.DatabaseError))
 
 			_, err := userService.CreateUser(ctx, username, email, phone)
 
@@ -77,7 +119,7 @@ var _ = Describe("Service", func() {
 
 			username := "test"
 			email := "test@test.com"
-			phone := "1234"
+			phone := "+493012345678"
 
 			mockStorage.EXPECT().Create(gomock.Any(), username, email, phone).Return(&common.User{
 				UserName:    username,
@@ -91,5 +133,65 @@ var _ = Describe("Service", func() {
 			Expect(returnedUser.UserName).To(Equal(username))
 		})
 
+		It("will normalize email and phone number before storing the user", func() {
+
+			mockStorage.EXPECT().Create(gomock.Any(), "test", "Test@test.com", "+493012345").Return(&common.User{}, nil)
+
+			_, err := userService.CreateUser(ctx, "test", "Test@TEST.com", "030 / 12 34-5")
+
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("will return invalid input if the phone number can not be normalized", func() {
+
+			_, err := userService.CreateUser(ctx, "test", "test@test.com", "+49abc")
+
+			Expect(err).To(HaveOccurred())
+			Expect(stderrors.Is(err, errors.InvalidInput)).To(BeTrue())
+		})
+
+	})
+
+	Context("Update user", func() {
+
+		It("will return error if user can not be found", func() {
+			mockStorage.EXPECT().Update(gomock.Any(), "test", "test@test.com", "+493012345678").Return(nil, fmt.Errorf("user was not found %w", errors.UserNotFound))
+
+			_, err := userService.UpdateUser(ctx, "test", "test@test.com", "+493012345678")
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("will successfully update a user", func() {
+			mockStorage.EXPECT().Update(gomock.Any(), "test", "test@test.com", "+493012345678").Return(&common.User{
+				UserName:    "test",
+				Email:       "test@test.com",
+				PhoneNumber: "+493012345678",
+			}, nil)
+
+			returnedUser, err := userService.UpdateUser(ctx, "test", "test@test.com", "+493012345678")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(returnedUser.Email).To(Equal("test@test.com"))
+		})
+	})
+
+	Context("Delete user", func() {
+
+		It("will return error if user can not be found", func() {
+			mockStorage.EXPECT().Delete(gomock.Any(), "test").Return(fmt.Errorf("user was not found %w", errors.UserNotFound))
+
+			err := userService.DeleteUser(ctx, "test")
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("will successfully delete a user", func() {
+			mockStorage.EXPECT().Delete(gomock.Any(), "test").Return(nil)
+
+			err := userService.DeleteUser(ctx, "test")
+
+			Expect(err).ToNot(HaveOccurred())
+		})
 	})
 })
@@ -5,31 +5,56 @@ package users
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/jenpaff/golang-microservices/common"
 	custom_errors "github.com/jenpaff/golang-microservices/errors"
 	"github.com/jenpaff/golang-microservices/persistence/models"
+	"github.com/jenpaff/golang-microservices/tracing"
+	"github.com/lib/pq"
 	"github.com/volatiletech/null/v8"
 	"github.com/volatiletech/sqlboiler/v4/boil"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+	"strconv"
+	"strings"
 )
 
 type Storage interface {
 	Create(ctx context.Context, userName, email, phoneNumber string) (*common.User, error)
 	FindByName(ctx context.Context, userName string) (*common.User, error)
+	FindByID(ctx context.Context, id string) (*common.User, error)
+	List(ctx context.Context, filter common.UserFilter) (*common.UserPage, error)
+	Update(ctx context.Context, userName, email, phoneNumber string) (*common.User, error)
+	Delete(ctx context.Context, userName string) error
+}
+
+// uniqueViolation is the postgres error code for a violated unique constraint
+const uniqueViolation = "23505"
+
+// uniqueConstraintFields maps the unique constraints of the users table to the field they protect
+var uniqueConstraintFields = map[string]string{
+	"users_username_key":     models.UserColumns.Username,
+	"users_email_key":        models.UserColumns.Email,
+	"users_phone_number_key": models.UserColumns.PhoneNumber,
 }
 
 type storage struct {
-	db *sql.DB
+	db boil.ContextExecutor
 }
 
-func NewStorage(db *sql.DB) Storage {
+// NewStorage takes a *sql.DB or a tracing.DB
+func NewStorage(db boil.ContextExecutor) Storage {
 	return &storage{db: db}
 }
 
 func (p *storage) Create(ctx context.Context, userName, email, phoneNumber string) (*common.User, error) {
+	ctx, span := tracing.StartSpan(ctx, "users.Storage.Create")
+	defer span.End()
+
 	newUser := &models.User{
-		// TODO: userId should be a uuuid
+		UUID:        uuid.New().String(),
 		Username:    userName,
 		Email:       null.StringFrom(email),
 		PhoneNumber: null.StringFrom(phoneNumber),
@@ -37,17 +62,128 @@ func (p *storage) Create(ctx context.Context, userName, email, phoneNumber strin
 
 	err := newUser.Insert(ctx, p.db, boil.Infer())
 	if err != nil {
+		if conflictError := conflictFromError(err); conflictError != nil {
+			return nil, fmt.Errorf("could not save user with userName %s: %w", userName, conflictError)
+		}
 		return nil, fmt.Errorf("error saving user with userName %s: %s: %w", userName, err.Error(), custom_errors.DatabaseError)
 	}
 
-	return &common.User{
-		UserName:    userName,
-		PhoneNumber: phoneNumber,
-		Email:       email,
-	}, nil
+	return toUser(newUser), nil
 }
 
 func (p *storage) FindByName(ctx context.Context, userName string) (*common.User, error) {
+	ctx, span := tracing.StartSpan(ctx, "users.Storage.FindByName")
+	defer span.End()
+
+	returnedUser, err := p.findModelByName(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+	return toUser(returnedUser), nil
+}
+
+func (p *storage) FindByID(ctx context.Context, id string) (*common.User, error) {
+	ctx, span := tracing.StartSpan(ctx, "users.Storage.FindByID")
+	defer span.End()
+
+	returnedUser, err := models.Users(models.UserWhere.UUID.EQ(id)).One(ctx, p.db)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not find user with id %s: %s : %w", id, err.Error(), custom_errors.UserNotFound)
+		}
+		return nil, fmt.Errorf("error retrieving user with id %s: %s: %w", id, err.Error(), custom_errors.DatabaseError)
+	}
+	return toUser(returnedUser), nil
+}
+
+func (p *storage) List(ctx context.Context, filter common.UserFilter) (*common.UserPage, error) {
+	ctx, span := tracing.StartSpan(ctx, "users.Storage.List")
+	defer span.End()
+
+	mods := []qm.QueryMod{
+		qm.OrderBy(models.UserColumns.ID + " ASC"),
+		// fetch one more user than requested to find out whether there is a next page
+		qm.Limit(filter.Limit + 1),
+	}
+
+	if filter.Cursor != "" {
+		afterID, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor %s: %s: %w", filter.Cursor, err.Error(), custom_errors.BadRequest)
+		}
+		mods = append(mods, models.UserWhere.ID.GT(afterID))
+	}
+	if filter.UserNamePrefix != "" {
+		mods = append(mods, qm.Where(models.UserColumns.Username+" LIKE ?", escapeLike(filter.UserNamePrefix)+"%"))
+	}
+	if filter.EmailDomain != "" {
+		mods = append(mods, qm.Where("lower("+models.UserColumns.Email+") LIKE ?", "%@"+escapeLike(strings.ToLower(filter.EmailDomain))))
+	}
+	if filter.HasPhoneNumber != nil {
+		if *filter.HasPhoneNumber {
+			mods = append(mods, models.UserWhere.PhoneNumber.IsNotNull())
+		} else {
+			mods = append(mods, models.UserWhere.PhoneNumber.IsNull())
+		}
+	}
+
+	returnedUsers, err := models.Users(mods...).All(ctx, p.db)
+	if err != nil {
+		return nil, fmt.Errorf("error listing users: %s: %w", err.Error(), custom_errors.DatabaseError)
+	}
+
+	page := &common.UserPage{Users: make([]common.User, 0, len(returnedUsers))}
+	if len(returnedUsers) > filter.Limit {
+		returnedUsers = returnedUsers[:filter.Limit]
+		page.NextCursor = encodeCursor(returnedUsers[len(returnedUsers)-1].ID)
+	}
+	for _, returnedUser := range returnedUsers {
+		page.Users = append(page.Users, *toUser(returnedUser))
+	}
+	return page, nil
+}
+
+func (p *storage) Update(ctx context.Context, userName, email, phoneNumber string) (*common.User, error) {
+	ctx, span := tracing.StartSpan(ctx, "users.Storage.Update")
+	defer span.End()
+
+	storedUser, err := p.findModelByName(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	// empty values are stored as NULL, the same way FindByName maps NULL back to an empty string
+	storedUser.Email = null.NewString(email, email != "")
+	storedUser.PhoneNumber = null.NewString(phoneNumber, phoneNumber != "")
+
+	_, err = storedUser.Update(ctx, p.db, boil.Whitelist(models.UserColumns.Email, models.UserColumns.PhoneNumber))
+	if err != nil {
+		if conflictError := conflictFromError(err); conflictError != nil {
+			return nil, fmt.Errorf("could not update user with userName %s: %w", userName, conflictError)
+		}
+		return nil, fmt.Errorf("error updating user with userName %s: %s: %w", userName, err.Error(), custom_errors.DatabaseError)
+	}
+
+	return toUser(storedUser), nil
+}
+
+func (p *storage) Delete(ctx context.Context, userName string) error {
+	ctx, span := tracing.StartSpan(ctx, "users.Storage.Delete")
+	defer span.End()
+
+	storedUser, err := p.findModelByName(ctx, userName)
+	if err != nil {
+		return err
+	}
+
+	_, err = storedUser.Delete(ctx, p.db)
+	if err != nil {
+		return fmt.Errorf("error deleting user with userName %s: %s: %w", userName, err.Error(), custom_errors.DatabaseError)
+	}
+	return nil
+}
+
+func (p *storage) findModelByName(ctx context.Context, userName string) (*models.User, error) {
 	returnedUser, err := models.Users(models.UserWhere.Username.EQ(userName)).One(ctx, p.db)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -55,9 +191,45 @@ func (p *storage) FindByName(ctx context.Context, userName string) (*common.User
 		}
 		return nil, fmt.Errorf("error retrieving user with userName %s: %s: %w", userName, err.Error(), custom_errors.DatabaseError)
 	}
+	return returnedUser, nil
+}
+
+// conflictFromError returns a conflict error naming the colliding field if err is a unique constraint violation
+func conflictFromError(err error) error {
+	var pqError *pq.Error
+	if !errors.As(err, &pqError) || pqError.Code != uniqueViolation {
+		return nil
+	}
+	if field, ok := uniqueConstraintFields[pqError.Constraint]; ok {
+		return custom_errors.NewConflictError(field)
+	}
+	return fmt.Errorf("%s: %w", pqError.Message, custom_errors.Conflict)
+}
+
+func toUser(user *models.User) *common.User {
 	return &common.User{
-		UserName:    returnedUser.Username,
-		PhoneNumber: returnedUser.PhoneNumber.String,
-		Email:       returnedUser.Email.String,
-	}, nil
+		ID:          user.UUID,
+		UserName:    user.Username,
+		PhoneNumber: user.PhoneNumber.String,
+		Email:       user.Email.String,
+	}
+}
+
+// cursors are opaque to clients, they only encode the id of the last user of the previous page
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(decoded))
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func escapeLike(value string) string {
+	return likeEscaper.Replace(value)
 }
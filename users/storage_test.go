@@ -5,11 +5,19 @@ package users_test
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"github.com/jenpaff/golang-microservices/common"
 	"github.com/jenpaff/golang-microservices/config"
+	custom_errors "github.com/jenpaff/golang-microservices/errors"
 	"github.com/jenpaff/golang-microservices/persistence"
+	"github.com/jenpaff/golang-microservices/tracing"
 	"github.com/jenpaff/golang-microservices/users"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 )
 
 var _ = Describe("Storage", func() {
@@ -34,6 +42,7 @@ var _ = Describe("Storage", func() {
 	AfterEach(func() {
 		_, err = sqlDB.Exec("delete from users")
 		Expect(err).ToNot(HaveOccurred())
+		Expect(sqlDB.Close()).To(Succeed())
 	})
 
 	It("should create a user and retrieve it successfully", func() {
@@ -49,4 +58,114 @@ var _ = Describe("Storage", func() {
 		Expect(storedUser.PhoneNumber).To(Equal(phone))
 	})
 
+	It("should assign a uuid to a created user and retrieve it by id", func() {
+		createdUser, err := storage.Create(ctx, "User 1", "test@test.com", "1234567")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(createdUser.ID).ToNot(BeEmpty())
+		storedUser, err := storage.FindByID(ctx, createdUser.ID)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(storedUser.UserName).To(Equal("User 1"))
+	})
+
+	It("should return a conflict naming the field when the email is already taken", func() {
+		_, err := storage.Create(ctx, "User 1", "test@test.com", "1234567")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = storage.Create(ctx, "User 2", "test@test.com", "7654321")
+		Expect(errors.Is(err, custom_errors.Conflict)).To(BeTrue())
+		var conflictError *custom_errors.ConflictError
+		Expect(errors.As(err, &conflictError)).To(BeTrue())
+		Expect(conflictError.Field).To(Equal("email"))
+	})
+
+	It("should list users page by page", func() {
+		for _, name := range []string{"user1", "user2", "user3"} {
+			_, err := storage.Create(ctx, name, name+"@test.com", name)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		firstPage, err := storage.List(ctx, common.UserFilter{Limit: 2})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(firstPage.Users).To(HaveLen(2))
+		Expect(firstPage.Users[0].UserName).To(Equal("user1"))
+		Expect(firstPage.NextCursor).ToNot(BeEmpty())
+
+		secondPage, err := storage.List(ctx, common.UserFilter{Limit: 2, Cursor: firstPage.NextCursor})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(secondPage.Users).To(HaveLen(1))
+		Expect(secondPage.Users[0].UserName).To(Equal("user3"))
+		Expect(secondPage.NextCursor).To(BeEmpty())
+	})
+
+	It("should filter users by username prefix and email domain", func() {
+		_, err := storage.Create(ctx, "jen1", "jen1@test.com", "1")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = storage.Create(ctx, "jen2", "jen2@example.com", "2")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = storage.Create(ctx, "paff", "paff@test.com", "3")
+		Expect(err).ToNot(HaveOccurred())
+
+		page, err := storage.List(ctx, common.UserFilter{Limit: 10, UserNamePrefix: "jen", EmailDomain: "TEST.com"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(page.Users).To(HaveLen(1))
+		Expect(page.Users[0].UserName).To(Equal("jen1"))
+	})
+
+	It("should return bad request for an invalid cursor", func() {
+		_, err := storage.List(ctx, common.UserFilter{Limit: 10, Cursor: "not a cursor"})
+		Expect(errors.Is(err, custom_errors.BadRequest)).To(BeTrue())
+	})
+
+	It("should update a user and retrieve it successfully", func() {
+		name := "User 1"
+		_, err := storage.Create(ctx, name, "test@test.com", "1234567")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = storage.Update(ctx, name, "new@test.com", "7654321")
+		Expect(err).ToNot(HaveOccurred())
+		storedUser, err := storage.FindByName(ctx, name)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(storedUser.Email).To(Equal("new@test.com"))
+		Expect(storedUser.PhoneNumber).To(Equal("7654321"))
+	})
+
+	It("should return user not found when updating an unknown user", func() {
+		_, err := storage.Update(ctx, "unknown", "new@test.com", "7654321")
+		Expect(errors.Is(err, custom_errors.UserNotFound)).To(BeTrue())
+	})
+
+	It("should delete a user", func() {
+		name := "User 1"
+		_, err := storage.Create(ctx, name, "test@test.com", "1234567")
+		Expect(err).ToNot(HaveOccurred())
+		err = storage.Delete(ctx, name)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = storage.FindByName(ctx, name)
+		Expect(errors.Is(err, custom_errors.UserNotFound)).To(BeTrue())
+	})
+
+	It("should return user not found when deleting an unknown user", func() {
+		err := storage.Delete(ctx, "unknown")
+		Expect(errors.Is(err, custom_errors.UserNotFound)).To(BeTrue())
+	})
+
+
+	It("should record a database span with the sql statement when using a traced database", func() {
+		recorder := tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		tracedStorage := users.NewStorage(tracing.WrapDB(sqlDB, cfg.Persistence.DbName))
+
+		_, err := tracedStorage.FindByName(ctx, "unknown")
+		Expect(errors.Is(err, custom_errors.UserNotFound)).To(BeTrue())
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[0].Name()).To(Equal("SELECT " + cfg.Persistence.DbName + " round-trip"))
+		var statement string
+		for _, attribute := range spans[0].Attributes() {
+			if attribute.Key == semconv.DBStatementKey {
+				statement = attribute.Value.AsString()
+			}
+		}
+		Expect(statement).To(HavePrefix(`SELECT "users".* FROM "users" WHERE`))
+		Expect(spans[1].Name()).To(Equal("users.Storage.FindByName"))
+	})
 })
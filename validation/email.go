@@ -0,0 +1,31 @@
+package validation
+
+import (
+	"github.com/go-playground/validator/v10"
+	"net/mail"
+	"reflect"
+	"strings"
+)
+
+// isEmailValid accepts plain RFC 5322 addresses, display names such as "Jen <jen@test.com>" are rejected
+func isEmailValid(fl validator.FieldLevel) bool {
+	field := fl.Field()
+	if field.Kind() != reflect.String {
+		return false
+	}
+	address, err := mail.ParseAddress(field.String())
+	if err != nil {
+		return false
+	}
+	return address.Name == "" && address.Address == field.String()
+}
+
+// NormalizeEmail lower-cases the domain of an email address, the local part is case sensitive and kept as is
+func NormalizeEmail(email string) string {
+	email = strings.TrimSpace(email)
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	return email[:at+1] + strings.ToLower(email[at+1:])
+}
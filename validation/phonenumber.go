@@ -0,0 +1,46 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/nyaruka/phonenumbers"
+	"reflect"
+	"regexp"
+)
+
+// phoneNumberCharacters are the digits and separators accepted, libphonenumber would turn letters of vanity numbers
+// such as "0800 FLOWERS" into digits
+var phoneNumberCharacters = regexp.MustCompile(`^[+0-9 ()./-]+$`)
+
+func newPhoneNumberValidation(defaultRegion string) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		field := fl.Field()
+		if field.Kind() != reflect.String {
+			return false
+		}
+		_, err := NormalizePhoneNumber(field.String(), defaultRegion)
+		return err == nil
+	}
+}
+
+// isSupportedRegion tells whether libphonenumber has the numbering plan of the ISO 3166 country code
+func isSupportedRegion(region string) bool {
+	return phonenumbers.GetCountryCodeForRegion(region) != 0
+}
+
+// NormalizePhoneNumber returns the E.164 representation of a phone number such as "+49 30 1234567",
+// "+49 (0)30 1234567" or "030 1234567", national numbers are interpreted as numbers of the default region.
+// The number must be valid in its region, e.g. it must have one of the lengths of the region's numbering plan
+func NormalizePhoneNumber(phoneNumber, defaultRegion string) (string, error) {
+	if !phoneNumberCharacters.MatchString(phoneNumber) {
+		return "", fmt.Errorf("%s must only contain digits, spaces and the separators +()./-", phoneNumber)
+	}
+	number, err := phonenumbers.Parse(phoneNumber, defaultRegion)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a phone number: %s", phoneNumber, err.Error())
+	}
+	if !phonenumbers.IsValidNumber(number) {
+		return "", fmt.Errorf("%s is not a valid phone number", phoneNumber)
+	}
+	return phonenumbers.Format(number, phonenumbers.E164), nil
+}
@@ -11,9 +11,16 @@ import (
 const (
 	validRegexInput = "validRegexInput"
 	required        = "required"
+	emailAddress    = "emailAddress"
+	phoneNumber     = "phoneNumber"
 )
 
-func NewValidate() (*validator.Validate, error) {
+// NewValidate creates the validator for request bodies, national phone numbers are validated for the defaultPhoneRegion
+func NewValidate(defaultPhoneRegion string) (*validator.Validate, error) {
+	if !isSupportedRegion(defaultPhoneRegion) {
+		return nil, fmt.Errorf("unknown default phone region %s", defaultPhoneRegion)
+	}
+
 	validate := validator.New()
 	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
 		// register name defined with the `json` tag, such that we can return the json name with the validation message
@@ -28,6 +35,14 @@ func NewValidate() (*validator.Validate, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = registerValidation(validate, emailAddress, isEmailValid)
+	if err != nil {
+		return nil, err
+	}
+	err = registerValidation(validate, phoneNumber, newPhoneNumberValidation(defaultPhoneRegion))
+	if err != nil {
+		return nil, err
+	}
 	return validate, nil
 }
 
@@ -41,12 +56,16 @@ func registerValidation(validate *validator.Validate, tag string, fn validator.F
 
 func GetValidationError(err error) error {
 	if e, ok := err.(validator.ValidationErrors); ok {
-		var errorMessages = make([]string, len(e))
+		var fieldErrors = make([]errors.FieldError, len(e))
 		for i, fieldError := range e {
-			errorMessages[i] = getMessage(fieldError)
+			fieldErrors[i] = errors.FieldError{
+				Field:   fieldError.Field(),
+				Tag:     fieldError.Tag(),
+				Param:   fieldError.Param(),
+				Message: getMessage(fieldError),
+			}
 		}
-		error := strings.Join(errorMessages, ";")
-		return fmt.Errorf("Error when validating request body: %s: %w", error, errors.InvalidInput)
+		return fmt.Errorf("Error when validating request body: %w", errors.NewValidationError(fieldErrors))
 	} else {
 		return fmt.Errorf("Error validating request %s : %w", err.Error(), errors.InvalidInput)
 	}
@@ -60,6 +79,10 @@ func getMessage(fieldError validator.FieldError) string {
 		return fmt.Sprintf("The field %s contains invalid characters- only the following characters are allowed: a-zA-Z0-9", field)
 	case required:
 		return fmt.Sprintf("The field %s is required.", field)
+	case emailAddress:
+		return fmt.Sprintf("The field %s must be a valid email address such as jen@example.com.", field)
+	case phoneNumber:
+		return fmt.Sprintf("The field %s must be a valid phone number such as +4930123456.", field)
 	default:
 		return fmt.Sprintf("Could not resolve validation tag %s for %s", tag, field)
 	}
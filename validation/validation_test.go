@@ -1,9 +1,13 @@
 package validation
 
 import (
+	stderrors "errors"
+	"fmt"
+	"github.com/jenpaff/golang-microservices/errors"
 	"github.com/golang/mock/gomock"
 	test_helper "github.com/jenpaff/golang-microservices/test-helper"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 )
 
@@ -24,7 +28,7 @@ var _ = Describe("Validation", func() {
 
 	It("field contains invalid characters", func() {
 		s := Example{Name: "invalidUserName$$$$"}
-		v, err := NewValidate()
+		v, err := NewValidate("DE")
 		Expect(err).ToNot(HaveOccurred())
 		err = v.Struct(s)
 		Expect(err).To(HaveOccurred())
@@ -32,7 +36,7 @@ var _ = Describe("Validation", func() {
 
 	It("field contains only blank spaces", func() {
 		s := Example{Name: "    "}
-		v, err := NewValidate()
+		v, err := NewValidate("DE")
 		Expect(err).ToNot(HaveOccurred())
 		err = v.Struct(s)
 		Expect(err).To(HaveOccurred())
@@ -40,7 +44,7 @@ var _ = Describe("Validation", func() {
 
 	It("field contains name and blank spaces", func() {
 		s := Example{Name: "    test"}
-		v, err := NewValidate()
+		v, err := NewValidate("DE")
 		Expect(err).ToNot(HaveOccurred())
 		err = v.Struct(s)
 		Expect(err).To(HaveOccurred())
@@ -48,9 +52,80 @@ var _ = Describe("Validation", func() {
 
 	It("field contains valid username", func() {
 		s := Example{Name: "jenpaff123"}
-		v, err := NewValidate()
+		v, err := NewValidate("DE")
 		Expect(err).ToNot(HaveOccurred())
 		err = v.Struct(s)
 		Expect(err).ToNot(HaveOccurred())
 	})
+
+	It("returns a structured error per invalid field", func() {
+		type Request struct {
+			Name  string `json:"name" validate:"required,validRegexInput"`
+			Email string `json:"email" validate:"required"`
+		}
+		v, err := NewValidate("DE")
+		Expect(err).ToNot(HaveOccurred())
+
+		err = GetValidationError(v.Struct(Request{Name: "invalid$"}))
+		err = fmt.Errorf("wrapped: %w", err)
+
+		Expect(stderrors.Is(err, errors.InvalidInput)).To(BeTrue())
+		var validationError *errors.ValidationError
+		Expect(stderrors.As(err, &validationError)).To(BeTrue())
+		Expect(validationError.Errors).To(ConsistOf(
+			errors.FieldError{Field: "name", Tag: validRegexInput, Message: "The field name contains invalid characters- only the following characters are allowed: a-zA-Z0-9"},
+			errors.FieldError{Field: "email", Tag: required, Message: "The field email is required."},
+		))
+	})
+
+	Context("email and phone number", func() {
+
+		type Contact struct {
+			Email       string `json:"email" validate:"emailAddress"`
+			PhoneNumber string `json:"phone_number" validate:"phoneNumber"`
+		}
+
+		DescribeTable("validates contacts", func(email, phone string, valid bool) {
+			v, err := NewValidate("DE")
+			Expect(err).ToNot(HaveOccurred())
+			err = v.Struct(Contact{Email: email, PhoneNumber: phone})
+			if valid {
+				Expect(err).ToNot(HaveOccurred())
+			} else {
+				Expect(err).To(HaveOccurred())
+			}
+		},
+			Entry("valid international contact", "jen@test.com", "+49 30 123456", true),
+			Entry("valid national phone number", "jen@test.com", "030 123456", true),
+			Entry("email without domain", "jen", "+4930123456", false),
+			Entry("email with display name", "Jen <jen@test.com>", "+4930123456", false),
+			Entry("phone number with letters", "jen@test.com", "+49 30 CALL", false),
+			Entry("phone number that is too long", "jen@test.com", "+49 1234567890123456", false),
+			Entry("phone number with a length the region does not have", "jen@test.com", "+49 151 234", false),
+			Entry("phone number of another region", "jen@test.com", "+1 212 555 0100", true),
+		)
+
+		It("rejects an unknown default region", func() {
+			_, err := NewValidate("XX")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("normalizes equivalent phone numbers to the same E.164 number", func() {
+			for _, phone := range []string{"+49 30 123456", "+4930123456", "004930123456", "030 123456", "(030) 12-34 56", "+49 (0) 30 123456", "+49 (0)30/123456"} {
+				normalized, err := NormalizePhoneNumber(phone, "DE")
+				Expect(err).ToNot(HaveOccurred(), phone)
+				Expect(normalized).To(Equal("+4930123456"), phone)
+			}
+		})
+
+		It("removes the trunk prefix of the default region", func() {
+			normalized, err := NormalizePhoneNumber("020 7946 0958", "GB")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(normalized).To(Equal("+442079460958"))
+		})
+
+		It("lower-cases the domain of an email address", func() {
+			Expect(NormalizeEmail(" Jen@Example.COM ")).To(Equal("Jen@example.com"))
+		})
+	})
 })